@@ -0,0 +1,228 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/handlers"
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+// archivedImage is an image as listed in an account archive, with the path
+// of its file inside the archive.
+type archivedImage struct {
+	models.Image
+	File string `json:"file,omitempty"`
+}
+
+// exportAccount downloads the account archive, see writeAccountArchive.
+func (s *server) exportAccount(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	user, err := s.users.FindUserByID(userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	archive, err := s.createAccountArchive(user)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	sendAccountArchive(c, archive)
+}
+
+// removeAccount deletes the account after checking the password given as
+// {"password": "..."}. The response is the account archive, which is
+// created before anything is deleted.
+func (s *server) removeAccount(c *gin.Context) {
+	var body struct {
+		Password string `json:"password" binding:"required"`
+	}
+
+	userID := c.GetInt("user_id")
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	user, err := s.users.FindUserByID(userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+	if !handlers.CheckPassword(user, body.Password) {
+		c.JSON(http.StatusForbidden, gin.H{"code": "INVALID_CREDENTIALS", "error": "Password is wrong"})
+		return
+	}
+
+	archive, err := s.createAccountArchive(user)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	report, err := s.users.DeleteUser(userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	removeBlobs(s.blobs, &report)
+
+	fmt.Printf("User %d deleted their account, removed %d files\n", userID, len(report.Files))
+
+	sendAccountArchive(c, archive)
+}
+
+// createAccountArchive writes the account archive to a temporary file,
+// which the caller has to close and remove.
+func (s *server) createAccountArchive(user models.User) (*os.File, error) {
+	archive, err := os.CreateTemp("", "account-*.zip")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create account archive: %w", err)
+	}
+	if err := s.writeAccountArchive(archive, user); err != nil {
+		archive.Close()
+		os.Remove(archive.Name())
+		return nil, err
+	}
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		archive.Close()
+		os.Remove(archive.Name())
+		return nil, fmt.Errorf("failed to rewind account archive: %w", err)
+	}
+	return archive, nil
+}
+
+// writeAccountArchive zips the user's profile as account.json and every
+// meadow deleting the account deletes for good under meadows/<id>/: the
+// meadow with all its trees as meadow.geojson and trees.csv, the image list
+// as images.json and the image files under images/<treeId>/. What of it is
+// in the trash is listed again in trash.json.
+func (s *server) writeAccountArchive(w io.Writer, user models.User) error {
+	zw := zip.NewWriter(w)
+
+	if err := writeArchiveJSON(zw, "account.json", user.Profile()); err != nil {
+		return err
+	}
+
+	accountMeadows, err := s.users.FindAccountMeadows(user.ID)
+	if err != nil {
+		return err
+	}
+	for _, accountMeadow := range accountMeadows {
+		if err := s.writeArchivedMeadow(zw, accountMeadow); err != nil {
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finish account archive: %w", err)
+	}
+	return nil
+}
+
+func (s *server) writeArchivedMeadow(zw *zip.Writer, accountMeadow models.AccountMeadow) error {
+	meadow, trees := accountMeadow.Meadow, accountMeadow.Trees
+	dir := fmt.Sprintf("meadows/%d/", meadow.ID)
+
+	fc, err := models.MeadowFeatureCollection(meadow, trees)
+	if err != nil {
+		return err
+	}
+	if err := writeArchiveJSON(zw, dir+"meadow.geojson", fc); err != nil {
+		return err
+	}
+
+	csvFile, err := zw.Create(dir + "trees.csv")
+	if err != nil {
+		return fmt.Errorf("failed to add trees of meadow %d to archive: %w", meadow.ID, err)
+	}
+	if err := models.WriteTreesCSV(csvFile, trees); err != nil {
+		return fmt.Errorf("failed to write trees of meadow %d to archive: %w", meadow.ID, err)
+	}
+
+	trash := models.Trash{Meadows: []models.Meadow{}, Trees: []models.Tree{}, Images: []models.Image{}}
+	if meadow.DeletedAt != nil {
+		trash.Meadows = append(trash.Meadows, meadow)
+	}
+	for _, tree := range trees {
+		if tree.DeletedAt != nil {
+			trash.Trees = append(trash.Trees, tree)
+		}
+	}
+
+	images := []archivedImage{}
+	for _, image := range accountMeadow.Images {
+		file := fmt.Sprintf("%simages/%d/%s", dir, image.TreeId, path.Base(image.Path))
+		if err := s.copyBlobToArchive(zw, image.Path, file); err != nil {
+			// The list still names the image, just without a file
+			fmt.Printf("Warning: image %d left out of archive: %v\n", image.ID, err)
+			file = ""
+		}
+		images = append(images, archivedImage{Image: image, File: file})
+		if image.DeletedAt != nil {
+			trash.Images = append(trash.Images, image)
+		}
+	}
+	if err := writeArchiveJSON(zw, dir+"images.json", images); err != nil {
+		return err
+	}
+
+	if len(trash.Meadows)+len(trash.Trees)+len(trash.Images) == 0 {
+		return nil
+	}
+	return writeArchiveJSON(zw, dir+"trash.json", trash)
+}
+
+func writeArchiveJSON(zw *zip.Writer, name string, v any) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", name, err)
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("failed to write %s to archive: %w", name, err)
+	}
+	return nil
+}
+
+func (s *server) copyBlobToArchive(zw *zip.Writer, key string, name string) error {
+	in, _, err := s.blobs.Get(key)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	return err
+}
+
+func sendAccountArchive(c *gin.Context, archive *os.File) {
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=treespotter-account-%s.zip", time.Now().Format("2006-01-02")))
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, archive); err != nil {
+		fmt.Printf("ERROR sending account archive: %v\n", err)
+	}
+}
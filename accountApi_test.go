@@ -0,0 +1,186 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPasswordReset(t *testing.T) {
+	ts := newTestServer(t)
+	token, refresh := ts.signUp("alice")
+	verification := ts.mailToken("verify-email")
+
+	// Unknown addresses get the same answer, but no mail
+	mails := ts.mails.Len()
+	ts.mustRequest("POST", "/password/forgot", `{"email": "nobody@example.com"}`, "", http.StatusAccepted)
+	if ts.mails.Len() != mails {
+		t.Error("a reset mail was sent to an unknown address")
+	}
+	ts.mustRequest("POST", "/password/forgot", `{"email": "alice@example.com"}`, "", http.StatusAccepted)
+	reset := ts.mailToken("reset-password")
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+	}{
+		{"verification token for a reset", "/password/reset", verification, http.StatusBadRequest},
+		{"reset token for a verification", "/email/verify", reset, http.StatusBadRequest},
+		{"garbage", "/password/reset", "not.a.token", http.StatusBadRequest},
+		{"reset", "/password/reset", reset, http.StatusOK},
+		{"reset again", "/password/reset", reset, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		body := fmt.Sprintf(`{"token": %q, "password": "new secret"}`, tt.token)
+		if status, response := ts.request("POST", tt.path, body, ""); status != tt.status {
+			t.Errorf("%s: POST %s = %d %v, want %d", tt.name, tt.path, status, response, tt.status)
+		}
+	}
+
+	// The new password works and every session has ended
+	ts.mustRequest("POST", "/login", `{"username": "alice", "password": "secret"}`, "", http.StatusUnauthorized)
+	ts.mustRequest("POST", "/login", `{"username": "alice", "password": "new secret"}`, "", http.StatusOK)
+	ts.mustRequest("GET", "/me", "", token, http.StatusUnauthorized)
+	ts.mustRequest("POST", "/token/refresh", fmt.Sprintf(`{"refreshToken": %q}`, refresh), "", http.StatusUnauthorized)
+}
+
+func TestEmailVerification(t *testing.T) {
+	ts := newTestServer(t)
+	token, _ := ts.signUp("alice")
+	first := ts.mailToken("verify-email")
+
+	if me := ts.mustRequest("GET", "/me", "", token, http.StatusOK); me["email_verified_at"] != nil {
+		t.Fatalf("a new address is verified: %v", me)
+	}
+
+	// A new address needs a new verification, and the link for the old
+	// one stops working
+	ts.mustRequest("PUT", "/me", `{"username": "alice", "email": "alice@example.org"}`, token, http.StatusOK)
+	second := ts.mailToken("verify-email")
+	ts.mustRequest("POST", "/email/verify", fmt.Sprintf(`{"token": %q}`, first), "", http.StatusBadRequest)
+
+	mails := ts.mails.Len()
+	ts.mustRequest("POST", "/email/verify/resend", `{"email": "alice@example.org"}`, "", http.StatusAccepted)
+	if ts.mails.Len() == mails {
+		t.Error("no new verification mail was sent")
+	}
+
+	ts.mustRequest("POST", "/email/verify", fmt.Sprintf(`{"token": %q}`, second), "", http.StatusOK)
+	if me := ts.mustRequest("GET", "/me", "", token, http.StatusOK); me["email_verified_at"] == nil {
+		t.Errorf("the address is not verified: %v", me)
+	}
+	ts.mustRequest("POST", "/email/verify", fmt.Sprintf(`{"token": %q}`, second), "", http.StatusBadRequest)
+
+	// Verified addresses get no more mails
+	mails = ts.mails.Len()
+	ts.mustRequest("POST", "/email/verify/resend", `{"email": "alice@example.org"}`, "", http.StatusAccepted)
+	if ts.mails.Len() != mails {
+		t.Error("a verification mail was sent to a verified address")
+	}
+}
+
+// archiveNames returns the names of the files in the account archive the
+// request responds with
+func (ts *testServer) archiveNames(method string, path string, body string, token string) []string {
+	ts.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := ts.serve(req, token)
+	if w.Code != http.StatusOK {
+		ts.t.Fatalf("%s %s = %d %s", method, path, w.Code, w.Body)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	names := []string{}
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestDeleteAccount(t *testing.T) {
+	ts := newTestServer(t)
+	alice, _ := ts.signUp("alice")
+	bob, _ := ts.signUp("bob")
+	ts.verifyEmail()
+
+	personal := ts.newMeadow(alice)
+	tree := ts.newTree(alice, personal)
+	ts.newImage(alice, tree, 10)
+	trashed := ts.newMeadow(alice)
+	ts.mustRequest("DELETE", fmt.Sprintf("/meadows/%d", trashed), "", alice, http.StatusOK)
+
+	// A meadow with another owner stays
+	shared := ts.newMeadow(alice)
+	invitation := id(ts.mustRequest("POST", fmt.Sprintf("/meadows/%d/invitations", shared), `{"email": "bob@example.com", "role": "owner"}`, alice, http.StatusCreated))
+	ts.mustRequest("POST", fmt.Sprintf("/invitations/%d/accept", invitation), "", bob, http.StatusOK)
+	organization := id(ts.mustRequest("POST", "/organizations", `{"name": "Obstbauverein"}`, alice, http.StatusCreated))
+
+	personalDir, trashedDir := fmt.Sprintf("meadows/%d/", personal), fmt.Sprintf("meadows/%d/", trashed)
+	want := []string{
+		"account.json",
+		personalDir + "images.json",
+		personalDir + "meadow.geojson",
+		personalDir + "trees.csv",
+		trashedDir + "images.json",
+		trashedDir + "meadow.geojson",
+		trashedDir + "trash.json",
+		trashedDir + "trees.csv",
+	}
+	// Image files are named after their key, so they are only counted
+	check := func(what string, names []string) {
+		t.Helper()
+		var rest []string
+		images := 0
+		for _, name := range names {
+			if strings.HasPrefix(name, fmt.Sprintf("%simages/%d/", personalDir, tree)) {
+				images++
+			} else {
+				rest = append(rest, name)
+			}
+		}
+		if images != 1 || !slices.Equal(rest, want) {
+			t.Errorf("%s = %v, want %v and the image", what, names, want)
+		}
+	}
+	check("exported files", ts.archiveNames("GET", "/me/export", "", alice))
+
+	ts.mustRequest("DELETE", "/me", `{"password": "guess"}`, alice, http.StatusForbidden)
+	ts.mustRequest("DELETE", "/me", `{}`, alice, http.StatusBadRequest)
+	// The last owner of an organization has to hand it over first
+	ts.mustRequest("DELETE", "/me", `{"password": "secret"}`, alice, http.StatusConflict)
+	ts.mustRequest("POST", fmt.Sprintf("/organizations/%d/members", organization), `{"username": "bob", "role": "owner"}`, alice, http.StatusCreated)
+
+	check("files of the deleted account", ts.archiveNames("DELETE", "/me", `{"password": "secret"}`, alice))
+
+	ts.mustRequest("POST", "/login", `{"username": "alice", "password": "secret"}`, "", http.StatusUnauthorized)
+	ts.mustRequest("GET", fmt.Sprintf("/meadows/%d", shared), "", bob, http.StatusOK)
+	ts.mustRequest("GET", fmt.Sprintf("/organizations/%d", organization), "", bob, http.StatusOK)
+	if members := ts.list(fmt.Sprintf("/meadows/%d/members", shared), bob); len(members) != 1 {
+		t.Errorf("the shared meadow has %d members, want only bob", len(members))
+	}
+
+	// The image files are gone with the meadow
+	err := filepath.WalkDir(ts.blobs, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			t.Errorf("file %s was left behind", path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,169 @@
+// Package catalog provides the catalog of fruit species and varieties that
+// trees refer to. It is read from varieties.json, which is embedded into the
+// binary. Trees store variety IDs, so entries of the file must never be
+// renumbered or removed.
+package catalog
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+//go:embed varieties.json
+var varietiesJSON []byte
+
+// Catalog is the read-only species and variety catalog.
+type Catalog struct {
+	species   []models.Species
+	byID      map[int]models.Variety
+	speciesOf map[int]models.Species
+	names     []name
+}
+
+// name is one of the names a variety can be found by, with its search key.
+type name struct {
+	varietyId int
+	text      string
+	key       string
+}
+
+// Load reads the embedded catalog.
+func Load() (*Catalog, error) {
+	return Parse(varietiesJSON)
+}
+
+// Parse reads a catalog in the format of varieties.json and checks that
+// IDs are unique and that no name belongs to two varieties.
+func Parse(data []byte) (*Catalog, error) {
+	var file struct {
+		Species []models.Species `json:"species"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse variety catalog: %w", err)
+	}
+
+	c := &Catalog{
+		byID:      make(map[int]models.Variety),
+		speciesOf: make(map[int]models.Species),
+	}
+	owners := map[string]int{}
+
+	for _, species := range file.Species {
+		if err := species.Validate(); err != nil {
+			return nil, err
+		}
+		generic := 0
+		for i, variety := range species.Varieties {
+			if err := variety.Validate(); err != nil {
+				return nil, err
+			}
+			if _, ok := c.byID[variety.ID]; ok {
+				return nil, fmt.Errorf("variety ID %d is used twice", variety.ID)
+			}
+			variety.SpeciesId = species.ID
+			species.Varieties[i] = variety
+			if variety.Generic {
+				generic++
+			}
+		}
+		if generic != 1 {
+			return nil, fmt.Errorf("species %d needs exactly one generic variety, found %d", species.ID, generic)
+		}
+
+		summary := species
+		summary.Varieties = nil
+		for _, variety := range species.Varieties {
+			c.byID[variety.ID] = variety
+			c.speciesOf[variety.ID] = summary
+
+			for _, text := range varietyNames(variety, summary) {
+				key := normalize(text)
+				if owner, ok := owners[key]; ok && owner != variety.ID {
+					return nil, fmt.Errorf("the name %q belongs to varieties %d and %d", text, owner, variety.ID)
+				}
+				if _, ok := owners[key]; !ok {
+					owners[key] = variety.ID
+					c.names = append(c.names, name{varietyId: variety.ID, text: text, key: key})
+				}
+			}
+		}
+		c.species = append(c.species, species)
+	}
+
+	sort.Slice(c.species, func(i, j int) bool { return c.species[i].ID < c.species[j].ID })
+	return c, nil
+}
+
+// varietyNames lists the names a variety is known by. The generic variety
+// of a species also goes by the species' scientific and common names.
+func varietyNames(variety models.Variety, species models.Species) []string {
+	names := append([]string{variety.Name}, variety.Synonyms...)
+	if variety.Generic {
+		names = append(names, species.ScientificName)
+		languages := make([]string, 0, len(species.CommonNames))
+		for language := range species.CommonNames {
+			languages = append(languages, language)
+		}
+		sort.Strings(languages)
+		for _, language := range languages {
+			names = append(names, species.CommonNames[language])
+		}
+	}
+	return names
+}
+
+// Species lists all species with their varieties, ordered by ID.
+func (c *Catalog) Species() []models.Species {
+	return c.species
+}
+
+// Variety returns the variety with the given ID together with its species,
+// which is returned without its varieties.
+func (c *Catalog) Variety(id int) (models.Variety, models.Species, bool) {
+	variety, ok := c.byID[id]
+	return variety, c.speciesOf[id], ok
+}
+
+// Match finds the variety that has the given name, synonym or, for generic
+// varieties, species name. Case, surrounding spaces, umlauts and
+// punctuation are ignored, but the name must otherwise match exactly.
+func (c *Catalog) Match(text string) (models.Variety, bool) {
+	key := normalize(text)
+	if key == "" {
+		return models.Variety{}, false
+	}
+	for _, n := range c.names {
+		if n.key == key {
+			return c.byID[n.varietyId], true
+		}
+	}
+	return models.Variety{}, false
+}
+
+// ResolveTree ties the tree to the catalog. A tree with a variety ID gets
+// the variety's name as its type, and a tree whose type matches a catalog
+// name is given that variety. Other types are left as they are.
+func (c *Catalog) ResolveTree(tree *models.Tree) error {
+	if tree.VarietyId != nil {
+		variety, ok := c.byID[*tree.VarietyId]
+		if !ok {
+			return fmt.Errorf("unknown variety %d", *tree.VarietyId)
+		}
+		tree.Type = variety.Name
+		return nil
+	}
+
+	if variety, ok := c.Match(tree.Type); ok {
+		id := variety.ID
+		tree.VarietyId = &id
+		tree.Type = variety.Name
+	} else {
+		tree.Type = strings.TrimSpace(tree.Type)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package catalog
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+// minSimilarity is how similar a query has to be to a name to count as a
+// misspelling of it, from 0 to 1.
+const minSimilarity = 0.7
+
+// Scores of the kinds of matches, so that exact matches rank before prefix
+// matches, which rank before substrings and misspellings.
+const (
+	scoreExact     = 1.0
+	scorePrefix    = 0.9
+	scoreWordStart = 0.85
+	scoreSubstring = 0.75
+	scoreFuzzy     = 0.7
+)
+
+var folding = strings.NewReplacer("ä", "a", "ö", "o", "ü", "u", "ß", "ss", "é", "e", "è", "e", "ê", "e", "à", "a", "ç", "c")
+
+// normalize turns a name into a search key: lower case, umlauts and accents
+// folded, punctuation dropped and spaces collapsed.
+func normalize(text string) string {
+	text = folding.Replace(strings.ToLower(text))
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// Search finds the varieties whose names match the query, tolerating
+// misspellings, and returns at most limit of them, best first. A variety
+// shows up only once, with the name that matched best.
+func (c *Catalog) Search(query string, limit int) []models.VarietyMatch {
+	matches := []models.VarietyMatch{}
+
+	key := normalize(query)
+	if key == "" {
+		return matches
+	}
+
+	best := map[int]int{}
+	for _, n := range c.names {
+		score := similarity(key, n.key)
+		if score == 0 {
+			continue
+		}
+		if i, ok := best[n.varietyId]; ok {
+			if score > matches[i].Score {
+				matches[i].Score = score
+				matches[i].MatchedName = n.text
+			}
+			continue
+		}
+		best[n.varietyId] = len(matches)
+		matches = append(matches, models.VarietyMatch{
+			Variety:     c.byID[n.varietyId],
+			Species:     c.speciesOf[n.varietyId],
+			MatchedName: n.text,
+			Score:       score,
+		})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Variety.ID < matches[j].Variety.ID
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// similarity scores how well the query key matches the name key, or
+// returns 0 if it does not match at all.
+func similarity(query string, name string) float64 {
+	switch {
+	case query == name:
+		return scoreExact
+	case strings.HasPrefix(name, query):
+		return scorePrefix
+	case strings.Contains(name, " "+query):
+		return scoreWordStart
+	case len(query) >= 3 && strings.Contains(name, query):
+		return scoreSubstring
+	}
+
+	// Misspellings are compared against the whole name as well as each of
+	// its words, so "boskop" still finds "Schöner aus Boskoop"
+	if len([]rune(query)) < 3 {
+		return 0
+	}
+	best := editSimilarity(query, name)
+	for _, word := range strings.Fields(name) {
+		best = max(best, editSimilarity(query, word))
+	}
+	if best < minSimilarity {
+		return 0
+	}
+	return scoreFuzzy * best
+}
+
+// editSimilarity is 1 minus the edit distance relative to the longer of
+// the two strings.
+func editSimilarity(a string, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a []rune, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/mail"
+	"github.com/Johnhi19/TreeSpotter_backend/signing"
+	"github.com/Johnhi19/TreeSpotter_backend/storage"
+)
+
+const (
+	defaultAppURL      = "http://localhost:8080"
+	defaultMailFrom    = "TreeSpotter <noreply@localhost>"
+	defaultImageURLTTL = 15 * time.Minute
+)
+
+// envDuration reads a duration like "720h" from the environment, falling
+// back to def if the variable is unset or invalid.
+func envDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		fmt.Printf("Warning: invalid %s %q, using %s\n", key, value, def)
+		return def
+	}
+	return d
+}
+
+// envString reads a variable from the environment, falling back to def if
+// it is unset.
+func envString(key string, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// newMailer creates the mailer MAIL_TRANSPORT selects: "smtp" sends through
+// SMTP_HOST and SMTP_PORT, optionally authenticating with SMTP_USERNAME and
+// SMTP_PASSWORD, while "log", the default, appends the mails to
+// MAIL_LOG_FILE or prints them if that is unset. Mails are sent as
+// MAIL_FROM.
+func newMailer() (mail.Mailer, error) {
+	from := envString("MAIL_FROM", defaultMailFrom)
+
+	switch transport := envString("MAIL_TRANSPORT", "log"); transport {
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return nil, fmt.Errorf("SMTP_HOST is required for the smtp mail transport")
+		}
+		port, err := strconv.Atoi(envString("SMTP_PORT", "587"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid SMTP_PORT: %w", err)
+		}
+		return mail.NewSMTPMailer(host, port, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from), nil
+	case "log":
+		path := os.Getenv("MAIL_LOG_FILE")
+		if path == "" {
+			return mail.NewLogMailer(os.Stdout, from), nil
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open mail log: %w", err)
+		}
+		return mail.NewLogMailer(file, from), nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_TRANSPORT %q, use smtp or log", transport)
+	}
+}
+
+// newImageSigner creates the signer of image URLs, keyed with
+// IMAGE_URL_SECRET or else JWT_SECRET. Without either, anyone could sign
+// image URLs, so it fails.
+func newImageSigner() (*signing.Signer, error) {
+	secret := envString("IMAGE_URL_SECRET", os.Getenv("JWT_SECRET"))
+	if secret == "" {
+		return nil, fmt.Errorf("IMAGE_URL_SECRET or JWT_SECRET is required to sign image URLs")
+	}
+	return signing.NewSigner([]byte(secret)), nil
+}
+
+// newBlobStore creates the store for uploaded files STORAGE_BACKEND selects:
+// "local", the default, keeps them below STORAGE_DIR (default ".", so that
+// images end up in ./uploads), while "s3" uses the bucket S3_BUCKET
+// (default "treespotter") at S3_ENDPOINT with S3_ACCESS_KEY and
+// S3_SECRET_KEY. S3_USE_SSL, S3_REGION and S3_PUBLIC_ENDPOINT are optional.
+func newBlobStore(signer *signing.Signer) (storage.BlobStore, error) {
+	switch backend := envString("STORAGE_BACKEND", "local"); backend {
+	case "local":
+		return storage.NewLocalStore(envString("STORAGE_DIR", "."), signer), nil
+	case "s3":
+		endpoint := os.Getenv("S3_ENDPOINT")
+		if endpoint == "" {
+			return nil, fmt.Errorf("S3_ENDPOINT is required for the s3 storage backend")
+		}
+		return storage.NewS3Store(storage.S3Options{
+			Endpoint:       endpoint,
+			PublicEndpoint: os.Getenv("S3_PUBLIC_ENDPOINT"),
+			Region:         envString("S3_REGION", "us-east-1"),
+			Bucket:         envString("S3_BUCKET", "treespotter"),
+			AccessKey:      os.Getenv("S3_ACCESS_KEY"),
+			SecretKey:      os.Getenv("S3_SECRET_KEY"),
+			UseSSL:         os.Getenv("S3_USE_SSL") == "true",
+		})
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q, use local or s3", backend)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Johnhi19/TreeSpotter_backend/db"
+	"github.com/Johnhi19/TreeSpotter_backend/handlers"
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+// maxCSVSize limits the size of uploaded tree CSV files.
+const maxCSVSize = 10 << 20
+
+func (s *server) exportTreesCSV(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intMeadowID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	trees, err := s.trees.FindAllTreesForMeadow(intMeadowID, userID, db.TreeFilter{})
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=meadow-%d-trees.csv", intMeadowID))
+	c.Status(http.StatusOK)
+	if err := models.WriteTreesCSV(c.Writer, trees); err != nil {
+		fmt.Printf("ERROR writing trees CSV of meadow %d: %v\n", intMeadowID, err)
+	}
+}
+
+// importTreesCSV adds the trees of a CSV file, sent as the body or as the
+// multipart field "file", to the meadow in one transaction. With dryRun=true
+// the file is only checked and nothing is inserted.
+func (s *server) importTreesCSV(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intMeadowID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+
+	meadow, err := s.meadows.FindOneMeadowByIdForUser(intMeadowID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	// Limit the body before either branch reads it, as the multipart form
+	// is parsed from the request body as well
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCSVSize)
+	tooLarge := &handlers.UploadError{Status: http.StatusRequestEntityTooLarge, Code: "CSV_TOO_LARGE",
+		Message: fmt.Sprintf("CSV file is larger than %d MB", maxCSVSize>>20)}
+	var maxBytesErr *http.MaxBytesError
+
+	body := io.Reader(c.Request.Body)
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if errors.As(err, &maxBytesErr) {
+			handlers.RespondError(c, tooLarge)
+			return
+		}
+		if err != nil {
+			handlers.RespondInvalidInput(c, "Missing CSV file in field \"file\"")
+			return
+		}
+		opened, err := file.Open()
+		if err != nil {
+			handlers.RespondInvalidInput(c, "Failed to read the CSV file")
+			return
+		}
+		defer opened.Close()
+		body = opened
+	}
+
+	trees, rowErrors, ignored, err := models.ParseTreesCSV(body, meadow, s.varieties.ResolveTree)
+	if errors.As(err, &maxBytesErr) {
+		handlers.RespondError(c, tooLarge)
+		return
+	}
+	if err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	if dryRun {
+		c.JSON(http.StatusOK, gin.H{
+			"message":        fmt.Sprintf("%d valid and %d invalid rows", len(trees), len(rowErrors)),
+			"dryRun":         true,
+			"validRows":      len(trees),
+			"errors":         rowErrors,
+			"ignoredColumns": ignored,
+		})
+		return
+	}
+	if len(rowErrors) > 0 {
+		handlers.RespondValidationErrors(c, fmt.Sprintf("%d invalid rows", len(rowErrors)), rowErrors)
+		return
+	}
+
+	treeIDs, err := s.trees.InsertTreesForUser(intMeadowID, trees, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":        fmt.Sprintf("%d trees imported successfully", len(treeIDs)),
+		"treeIds":        treeIDs,
+		"ignoredColumns": ignored,
+	})
+}
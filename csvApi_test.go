@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestImportTreesCSV(t *testing.T) {
+	ts := newTestServer(t)
+	owner, _ := ts.signUp("owner")
+	meadow := id(ts.mustRequest("POST", "/meadows", `{"name": "Orchard", "location": "Hill", "size": [10, 10]}`, owner, http.StatusCreated))
+	path := fmt.Sprintf("/meadows/%d/trees/import", meadow)
+
+	file := "type,plantDate,x,y\nApple,2020-03-01,1,1\nPear,2021-04-02,2,2\n"
+	tooLarge := []byte("type,plantDate,x,y\n" + strings.Repeat("Apple,2020-03-01,1,1\n", maxCSVSize/20))
+
+	send := func(body []byte) (int, map[string]any) {
+		req := httptest.NewRequest("POST", path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "text/csv")
+		return ts.send(req, owner)
+	}
+
+	if status, response := send([]byte(file)); status != http.StatusCreated {
+		t.Errorf("importing the request body = %d %v, want 201", status, response)
+	}
+	if status, response := ts.upload(path, "file", owner, []byte(file)); status != http.StatusCreated {
+		t.Errorf("importing a multipart form = %d %v, want 201", status, response)
+	}
+	if status, response := send(tooLarge); status != http.StatusRequestEntityTooLarge || response["code"] != "CSV_TOO_LARGE" {
+		t.Errorf("importing a request body that is too large = %d %v, want 413", status, response["code"])
+	}
+	if status, response := ts.upload(path, "file", owner, tooLarge); status != http.StatusRequestEntityTooLarge || response["code"] != "CSV_TOO_LARGE" {
+		t.Errorf("importing a multipart file that is too large = %d %v, want 413", status, response["code"])
+	}
+
+	if trees := ts.list(fmt.Sprintf("/meadows/%d/trees", meadow), owner); len(trees) != 4 {
+		t.Errorf("the meadow has %d trees, want the 4 from the files that were not too large", len(trees))
+	}
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+// Access to a meadow and everything on it is decided by the user's role in
+// the meadow_access view, which combines the roles from meadow_members with
+// those from organization_members for meadows owned by an organization.
+// Readers filter with memberOf, so users who are no members see nothing at
+// all. Writers call one of the authorize functions first,
+// which tell a missing item (ErrNotFound) from a role that does not allow
+// the change (ErrForbidden).
+
+// queryRower is implemented by *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// memberOf is a condition that the meadow ID in column belongs to a meadow
+// in which the user, bound to the next placeholder, holds at least role.
+func memberOf(column string, role models.Role) string {
+	roles := []string{}
+	for _, r := range models.RolesAtLeast(role) {
+		roles = append(roles, "'"+string(r)+"'")
+	}
+	return column + " IN (SELECT meadow_id FROM meadow_access WHERE user_id = ? AND role IN (" + strings.Join(roles, ", ") + "))"
+}
+
+// authorize runs a query that selects the user's role for the item and
+// checks it against need. what names the item in errors.
+func authorize(q queryRower, need models.Role, what string, query string, args ...any) error {
+	var role models.Role
+	err := q.QueryRow(query, args...).Scan(&role)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%s: %w", what, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check access to %s: %w", what, err)
+	}
+	if !role.AtLeast(need) {
+		return fmt.Errorf("%s needs the %s role, the user is %s: %w", what, need, role, ErrForbidden)
+	}
+	return nil
+}
+
+// authorizeMeadow checks the user's role in the meadow, which must not be in
+// the trash.
+func authorizeMeadow(q queryRower, meadowId int, userID int, need models.Role) error {
+	return authorize(q, need, fmt.Sprintf("meadow %d", meadowId),
+		`SELECT mm.role FROM meadows m JOIN meadow_access mm ON mm.meadow_id = m.ID AND mm.user_id = ?
+		WHERE m.ID = ? AND m.deleted_at IS NULL`, userID, meadowId)
+}
+
+// authorizeTree checks the user's role in the meadow of the tree, which must
+// not be in the trash.
+func authorizeTree(q queryRower, treeId int, userID int, need models.Role) error {
+	return authorize(q, need, fmt.Sprintf("tree %d", treeId),
+		`SELECT mm.role FROM trees t JOIN meadow_access mm ON mm.meadow_id = t.MeadowId AND mm.user_id = ?
+		WHERE t.ID = ? AND t.deleted_at IS NULL`, userID, treeId)
+}
+
+// authorizeImage checks the user's role in the meadow of the image's tree.
+// Neither the image nor its tree may be in the trash.
+func authorizeImage(q queryRower, imageID int, userID int, need models.Role) error {
+	return authorize(q, need, fmt.Sprintf("image %d", imageID),
+		`SELECT mm.role FROM images i JOIN trees t ON t.ID = i.tree_id
+		JOIN meadow_access mm ON mm.meadow_id = t.MeadowId AND mm.user_id = ?
+		WHERE i.id = ? AND i.deleted_at IS NULL AND t.deleted_at IS NULL`, userID, imageID)
+}
+
+// authorizeTask checks the user's role in the meadow of the task, which it
+// belongs to directly or through its tree. Neither may be in the trash.
+func authorizeTask(q queryRower, taskId int, userID int, need models.Role) error {
+	return authorize(q, need, fmt.Sprintf("task %d", taskId),
+		"SELECT mm.role FROM tasks k "+taskLiveJoin+" JOIN meadow_access mm ON mm.meadow_id = m.ID AND mm.user_id = ?"+
+			" WHERE k.id = ? AND "+taskLiveCondition, userID, taskId)
+}
+
+// authorizeOrganization checks the user's role in the organization.
+func authorizeOrganization(q queryRower, organizationId int, userID int, need models.Role) error {
+	return authorize(q, need, fmt.Sprintf("organization %d", organizationId),
+		"SELECT role FROM organization_members WHERE organization_id = ? AND user_id = ?", organizationId, userID)
+}
+
+// addOwner makes the user the owner of a newly inserted meadow.
+func addOwner(tx *sql.Tx, meadowId int64, userID int) error {
+	if _, err := tx.Exec("INSERT INTO meadow_members (meadow_id, user_id, role) VALUES (?, ?, ?)",
+		meadowId, userID, models.RoleOwner); err != nil {
+		return fmt.Errorf("failed to add owner of meadow %d: %w", meadowId, err)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+// trashTimestamp is the deleted_at value for one trash operation. Everything
+// trashed together shares it, which is how a restore finds what belongs
+// together. DATETIME columns only keep whole seconds.
+func trashTimestamp() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// Moves the meadow together with its trees and their images to the trash in
+// one transaction. Only owners may do so. Files are kept until the trash is
+// purged.
+func (s *MySQLStore) DeleteOneMeadowForUser(meadowId int, userID int) (models.DeletionReport, error) {
+	report := models.DeletionReport{MeadowIds: []int{}, TreeIds: []int{}, ImageIds: []int{}, Files: []string{}}
+	now := trashTimestamp()
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return report, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := authorizeMeadow(tx, meadowId, userID, models.RoleOwner); err != nil {
+		return report, err
+	}
+
+	var id int
+	err = tx.QueryRow("SELECT ID FROM meadows WHERE ID = ? AND deleted_at IS NULL FOR UPDATE", meadowId).Scan(&id)
+	if err == sql.ErrNoRows {
+		return report, fmt.Errorf("meadow %d: %w", meadowId, ErrNotFound)
+	}
+	if err != nil {
+		return report, fmt.Errorf("failed to lock meadow %d: %w", meadowId, err)
+	}
+
+	treeIds, err := queryIDs(tx, "SELECT ID FROM trees WHERE MeadowId = ? AND deleted_at IS NULL FOR UPDATE", meadowId)
+	if err != nil {
+		return report, err
+	}
+
+	imageIds, err := queryIDs(tx, `SELECT i.id FROM images i JOIN trees t ON t.ID = i.tree_id
+		WHERE t.MeadowId = ? AND t.deleted_at IS NULL AND i.deleted_at IS NULL FOR UPDATE`, meadowId)
+	if err != nil {
+		return report, err
+	}
+
+	if _, err := tx.Exec(`UPDATE images i JOIN trees t ON t.ID = i.tree_id SET i.deleted_at = ?
+		WHERE t.MeadowId = ? AND t.deleted_at IS NULL AND i.deleted_at IS NULL`, now, meadowId); err != nil {
+		return report, fmt.Errorf("failed to delete images of meadow %d: %w", meadowId, err)
+	}
+	if _, err := tx.Exec("UPDATE trees SET deleted_at = ? WHERE MeadowId = ? AND deleted_at IS NULL", now, meadowId); err != nil {
+		return report, fmt.Errorf("failed to delete trees of meadow %d: %w", meadowId, err)
+	}
+	if _, err := tx.Exec("UPDATE meadows SET deleted_at = ? WHERE ID = ?", now, meadowId); err != nil {
+		return report, fmt.Errorf("failed to delete meadow: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return report, fmt.Errorf("failed to commit meadow deletion: %w", err)
+	}
+
+	report.MeadowIds = append(report.MeadowIds, meadowId)
+	report.TreeIds = treeIds
+	report.ImageIds = imageIds
+
+	fmt.Printf("Moved meadow of user %d with ID %d to the trash (%d trees, %d images)\n", userID, meadowId, len(treeIds), len(imageIds))
+	return report, nil
+}
+
+// Moves the tree together with its images to the trash in one transaction.
+// Files are kept until the trash is purged.
+func (s *MySQLStore) DeleteOneTreeForUser(treeId int, userID int) (models.DeletionReport, error) {
+	report := models.DeletionReport{MeadowIds: []int{}, TreeIds: []int{}, ImageIds: []int{}, Files: []string{}}
+	now := trashTimestamp()
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return report, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := authorizeTree(tx, treeId, userID, models.RoleEditor); err != nil {
+		return report, err
+	}
+
+	var id int
+	err = tx.QueryRow("SELECT ID FROM trees WHERE ID = ? AND deleted_at IS NULL FOR UPDATE", treeId).Scan(&id)
+	if err == sql.ErrNoRows {
+		return report, fmt.Errorf("tree %d: %w", treeId, ErrNotFound)
+	}
+	if err != nil {
+		return report, fmt.Errorf("failed to lock tree %d: %w", treeId, err)
+	}
+
+	imageIds, err := queryIDs(tx, "SELECT id FROM images WHERE tree_id = ? AND deleted_at IS NULL FOR UPDATE", treeId)
+	if err != nil {
+		return report, err
+	}
+
+	if _, err := tx.Exec("UPDATE images SET deleted_at = ? WHERE tree_id = ? AND deleted_at IS NULL", now, treeId); err != nil {
+		return report, fmt.Errorf("failed to delete images of tree %d: %w", treeId, err)
+	}
+	if _, err := tx.Exec("UPDATE trees SET deleted_at = ? WHERE ID = ?", now, treeId); err != nil {
+		return report, fmt.Errorf("failed to delete tree: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return report, fmt.Errorf("failed to commit tree deletion: %w", err)
+	}
+
+	report.TreeIds = append(report.TreeIds, treeId)
+	report.ImageIds = imageIds
+
+	fmt.Printf("Moved tree of user %d with ID %d to the trash (%d images)\n", userID, treeId, len(imageIds))
+	return report, nil
+}
+
+// purgeMeadow deletes the meadow for good with all its trees and images,
+// including those in the trash, and adds them to the report.
+func purgeMeadow(tx *sql.Tx, meadowId int, report *models.DeletionReport) error {
+	treeIds, err := queryIDs(tx, "SELECT ID FROM trees WHERE MeadowId = ? FOR UPDATE", meadowId)
+	if err != nil {
+		return err
+	}
+	imageIds, imagePaths, err := queryImages(tx, `SELECT i.id, i.path, i.thumbnail_path, i.medium_path FROM images i JOIN trees t ON t.ID = i.tree_id
+		WHERE t.MeadowId = ? FOR UPDATE`, meadowId)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE i FROM images i JOIN trees t ON t.ID = i.tree_id WHERE t.MeadowId = ?", meadowId); err != nil {
+		return fmt.Errorf("failed to delete images of meadow %d: %w", meadowId, err)
+	}
+	if _, err := tx.Exec("DELETE FROM trees WHERE MeadowId = ?", meadowId); err != nil {
+		return fmt.Errorf("failed to delete trees of meadow %d: %w", meadowId, err)
+	}
+	if _, err := tx.Exec("DELETE FROM meadows WHERE ID = ?", meadowId); err != nil {
+		return fmt.Errorf("failed to delete meadow %d: %w", meadowId, err)
+	}
+
+	report.MeadowIds = append(report.MeadowIds, meadowId)
+	report.TreeIds = append(report.TreeIds, treeIds...)
+	report.ImageIds = append(report.ImageIds, imageIds...)
+	report.Files = append(report.Files, imagePaths...)
+	return nil
+}
+
+func queryIDs(tx *sql.Tx, query string, args ...any) ([]int, error) {
+	ids := []int{}
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query IDs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read IDs: %w", err)
+	}
+	return ids, nil
+}
+
+// queryImages expects a query selecting the id, path, thumbnail_path and
+// medium_path of images, and returns the IDs and the paths of all files.
+func queryImages(tx *sql.Tx, query string, args ...any) ([]int, []string, error) {
+	ids := []int{}
+	paths := []string{}
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query images: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var path string
+		var thumbnailPath, mediumPath sql.NullString
+		if err := rows.Scan(&id, &path, &thumbnailPath, &mediumPath); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan image: %w", err)
+		}
+		ids = append(ids, id)
+		paths = append(paths, path)
+		for _, variant := range []sql.NullString{thumbnailPath, mediumPath} {
+			if variant.Valid {
+				paths = append(paths, variant.String)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read images: %w", err)
+	}
+	return ids, paths, nil
+}
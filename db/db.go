@@ -2,416 +2,649 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/Johnhi19/TreeSpotter_backend/models"
 	_ "github.com/go-sql-driver/mysql"
 )
 
-var DB *sql.DB
+// MySQLStore implements the store interfaces on top of a MySQL connection.
+type MySQLStore struct {
+	conn *sql.DB
+}
 
-func Connect() {
+func NewMySQLStore(conn *sql.DB) *MySQLStore {
+	return &MySQLStore{conn: conn}
+}
 
-	// get connection properties for the mysql db
-	dsn := fmt.Sprintf(
-		"%s:%s@tcp(%s:%s)/%s?parseTime=true",
+// meadowTreeIdsColumn computes a meadow's TreeIds from the trees table. It
+// expects the meadows table to be aliased as m.
+const meadowTreeIdsColumn = "COALESCE((SELECT JSON_ARRAYAGG(t.ID) FROM trees t WHERE t.MeadowId = m.ID AND t.deleted_at IS NULL), JSON_ARRAY())"
+
+// meadowColumns selects a meadow aliased as m with the user's role and the
+// organization owning it, which meadowAccessJoin joins in as ma and o. The
+// join takes the user ID as its first argument.
+const meadowColumns = "m.ID, m.Location, m.Name, m.Size, " + meadowTreeIdsColumn + ", m.Boundary, m.Grid, ma.role, m.organization_id, o.name"
+
+const meadowAccessJoin = "JOIN meadow_access ma ON ma.meadow_id = m.ID AND ma.user_id = ? LEFT JOIN organizations o ON o.id = m.organization_id"
+
+// treeColumns selects a tree aliased as t together with the condition of
+// its latest inspection, which treeConditionJoin joins in as ti. Rows are
+// read with scanTree.
+const treeColumns = "t.ID, t.PlantDate, t.MeadowId, t.Position, t.Type, t.variety_id, t.Latitude, t.Longitude, t.deleted_at, ti.id, ti.date, ti.vitality, ti.diseases, ti.pests"
+const treeConditionJoin = "LEFT JOIN tree_inspections ti ON ti.id = " +
+	"(SELECT id FROM tree_inspections WHERE tree_id = t.ID ORDER BY date DESC, id DESC LIMIT 1)"
+
+// imageColumns selects an image aliased as i. Rows are read with scanImage.
+const imageColumns = "i.id, i.tree_id, i.path, i.thumbnail_path, i.medium_path, i.sha256, i.upload_sha256, i.description, i.datetime, i.deleted_at, " +
+	"i.Latitude, i.Longitude, i.camera_model, i.orientation"
+
+// dsn builds the connection string for the mysql db from the environment.
+// clientFoundRows makes UPDATE report matched instead of changed rows, so an
+// update that changes nothing is not mistaken for a missing row.
+func dsn() string {
+	return fmt.Sprintf(
+		"%s:%s@tcp(%s:%s)/%s?parseTime=true&clientFoundRows=true",
 		os.Getenv("DB_USER"),
 		os.Getenv("DB_PASSWORD"),
 		os.Getenv("DB_HOST"),
 		os.Getenv("DB_PORT"),
 		os.Getenv("DB_NAME"),
 	)
+}
 
+// Connect opens the database and, if autoMigrate is set, applies all
+// pending schema migrations before returning.
+func Connect(autoMigrate bool) *sql.DB {
+	var conn *sql.DB
 	var err error
 
 	// Try to connect multiple times with delays
 	for i := 1; i <= 30; i++ {
-		DB, err = sql.Open("mysql", dsn)
+		conn, err = sql.Open("mysql", dsn())
 		if err == nil {
-			err = DB.Ping()
+			err = conn.Ping()
 		}
 
 		if err == nil {
 			log.Println("Connected to MySQL!")
-			return
+			break
 		}
 
 		log.Printf("Waiting for DB... (%d/30): %v\n", i, err)
 		time.Sleep(2 * time.Second)
 	}
+
+	if err == nil && autoMigrate {
+		if err := MigrateUp(); err != nil {
+			log.Fatal("Error migrating database:", err)
+		}
+		log.Println("Database schema is up to date.")
+	}
+
+	return conn
 }
 
-func Disconnect() {
-	if err := DB.Close(); err != nil {
+func Disconnect(conn *sql.DB) {
+	if err := conn.Close(); err != nil {
 		log.Fatal("Error closing database connection:", err)
 	} else {
 		fmt.Println("Database connection closed.")
 	}
 }
 
-func DeleteOneMeadowForUser(meadowId int, userID int) error {
-	// First, get all tree IDs associated with the meadow
-	meadow := FindOneMeadowByIdForUser(meadowId, userID)
-	if meadow.ID == 0 {
-		return fmt.Errorf("meadow with ID %d not found", meadowId)
-	}
-
-	// Delete all associated trees
-	for _, treeId := range meadow.TreeIds {
-		if err := deleteTreeOnly(treeId, userID); err != nil {
-			fmt.Printf("Warning: Failed to delete tree ID %d: %v\n", treeId, err)
-		}
+// Moves the image to the trash; the file is kept until the trash is purged
+func (s *MySQLStore) DeleteTreeImage(imageID int, userID int) error {
+	if err := authorizeImage(s.conn, imageID, userID, models.RoleEditor); err != nil {
+		return err
 	}
 
-	// Now delete the meadow itself
-	result, err := DB.Exec("DELETE FROM meadows WHERE ID = ? and user_id = ?", meadowId, userID)
+	result, err := s.conn.Exec("UPDATE images SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL",
+		trashTimestamp(), imageID)
 	if err != nil {
-		return fmt.Errorf("failed to delete meadow: %w", err)
+		return fmt.Errorf("failed to delete image: %w", err)
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		return fmt.Errorf("no meadow found with ID %d and user ID %d", meadowId, userID)
+		return fmt.Errorf("image %d: %w", imageID, ErrNotFound)
 	}
 
-	fmt.Printf("Deleted meadow for user %d with ID: %d\n", userID, meadowId)
+	fmt.Printf("Moved image of user %d with ID %d to the trash\n", userID, imageID)
 	return nil
 }
 
-// Deletes the tree and updates the meadow's TreeIds accordingly
-func DeleteOneTreeForUser(treeId int, userID int) error {
-	// First, get the tree to know which meadow it belongs to
-	tree := FindOneTreeById(treeId, userID)
-	if tree.ID == 0 {
-		return fmt.Errorf("tree with ID %d not found", treeId)
-	}
-
-	meadowId := tree.MeadowId
-
-	// Delete the tree from the database
-	if err := deleteTreeOnly(treeId, userID); err != nil {
-		return err
-	}
+// Lists the meadows the user can access, personal ones as well as those of
+// the user's organizations, with the user's role and the meadow's scope
+func (s *MySQLStore) FindAllMeadowsForUser(userID int) ([]models.Meadow, error) {
+	return s.queryMeadows("WHERE m.deleted_at IS NULL ORDER BY m.ID", userID)
+}
 
-	// Remove tree ID from meadow's TreeIds
-	if err := UpdateMeadowTreeIdsForUser(meadowId, int64(treeId), true, userID); err != nil {
-		fmt.Printf("Warning: Tree deleted but failed to update meadow: %v\n", err)
-		return err
+// Lists the meadows of the organization the user can access, which are all
+// of them for members of the organization
+func (s *MySQLStore) FindAllMeadowsOfOrganization(organizationId int, userID int) ([]models.Meadow, error) {
+	if err := authorizeOrganization(s.conn, organizationId, userID, models.RoleViewer); err != nil {
+		return nil, err
 	}
-
-	return nil
+	return s.queryMeadows("WHERE m.organization_id = ? AND m.deleted_at IS NULL ORDER BY m.ID", userID, organizationId)
 }
 
-func DeleteTreeImage(imageID int, userID int) error {
-	var filePath string
-	err := DB.QueryRow("SELECT path FROM images WHERE id = ? AND user_id = ?", imageID, userID).Scan(&filePath)
+func (s *MySQLStore) queryMeadows(condition string, args ...any) ([]models.Meadow, error) {
+	meadows := []models.Meadow{}
+
+	rows, err := s.conn.Query("SELECT "+meadowColumns+" FROM meadows m "+meadowAccessJoin+" "+condition, args...)
 	if err != nil {
-		return fmt.Errorf("failed to retrieve image path: %w", err)
+		return nil, fmt.Errorf("failed to query meadows: %w", err)
 	}
+	defer rows.Close()
 
-	// Delete the image file from the filesystem
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return fmt.Errorf("file does not exist: %s", filePath)
+	for rows.Next() {
+		med, err := scanMeadow(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan meadow: %w", err)
+		}
+		meadows = append(meadows, med)
 	}
-
-	// Delete the file
-	if err := os.Remove(filePath); err != nil {
-		return fmt.Errorf("failed to delete file: %w", err)
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read meadows: %w", err)
 	}
+	return meadows, nil
+}
 
-	fmt.Printf("Successfully deleted file: %s\n", filePath)
+func (s *MySQLStore) FindAllTreesForMeadow(meadowId int, userID int, filter TreeFilter) ([]models.Tree, error) {
+	trees := []models.Tree{}
 
-	result, err := DB.Exec("DELETE FROM images WHERE id = ? AND user_id = ?", imageID, userID)
-	if err != nil {
-		return fmt.Errorf("failed to delete image: %w", err)
+	// Make sure the meadow exists, so an unknown meadow is not served as empty
+	if _, err := s.FindOneMeadowByIdForUser(meadowId, userID); err != nil {
+		return nil, err
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return fmt.Errorf("no image found with ID %d and user ID %d", imageID, userID)
-	}
+	query := "SELECT " + treeColumns + " FROM trees t " + treeConditionJoin +
+		" WHERE t.MeadowId = ? AND t.deleted_at IS NULL"
+	args := []any{meadowId}
 
-	fmt.Printf("Deleted image for user %d with ID: %d\n", userID, imageID)
-	return nil
-}
-
-func FindAllMeadowsForUser(userID int) []models.Meadow {
-	var meadows []models.Meadow
+	// Condition filters apply to the latest inspection
+	if filter.MinVitality != nil {
+		query += " AND ti.vitality >= ?"
+		args = append(args, *filter.MinVitality)
+	}
+	if filter.MaxVitality != nil {
+		query += " AND ti.vitality <= ?"
+		args = append(args, *filter.MaxVitality)
+	}
+	if filter.Disease != "" {
+		query += " AND JSON_CONTAINS(ti.diseases, JSON_QUOTE(?))"
+		args = append(args, filter.Disease)
+	}
+	if filter.Pest != "" {
+		query += " AND JSON_CONTAINS(ti.pests, JSON_QUOTE(?))"
+		args = append(args, filter.Pest)
+	}
+	query += " ORDER BY t.ID"
 
-	rows, err := DB.Query("SELECT ID, Location, Name, Size, TreeIds FROM meadows WHERE user_id = ?", userID)
+	rows, err := s.conn.Query(query, args...)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("failed to query trees: %w", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var med models.Meadow
-		if err := rows.Scan(&med.ID, &med.Location, &med.Name, &med.Size, &med.TreeIds); err != nil {
-			panic(err)
+		tree, err := scanTree(rows)
+		if err != nil {
+			return nil, err
 		}
-		meadows = append(meadows, med)
+		trees = append(trees, tree)
 	}
 	if err := rows.Err(); err != nil {
-		panic(err)
+		return nil, fmt.Errorf("failed to read trees: %w", err)
 	}
-	return meadows
+	return trees, nil
 }
 
-func FindAllTreesForMeadow(meadowId int, userID int) []models.Tree {
-	var trees []models.Tree
-
-	meadow := FindOneMeadowByIdForUser(meadowId, userID)
-
-	if len(meadow.TreeIds) == 0 {
-		return []models.Tree{}
+func (s *MySQLStore) FindOneMeadowByIdForUser(meadowId int, userID int) (models.Meadow, error) {
+	row := s.conn.QueryRow("SELECT "+meadowColumns+" FROM meadows m "+meadowAccessJoin+
+		" WHERE m.ID = ? AND m.deleted_at IS NULL", userID, meadowId)
+	meadow, err := scanMeadow(row)
+	if err == sql.ErrNoRows {
+		return meadow, fmt.Errorf("meadow %d: %w", meadowId, ErrNotFound)
 	}
-
-	placeholders := make([]string, len(meadow.TreeIds))
-	args := make([]any, len(meadow.TreeIds))
-	for i, treeId := range meadow.TreeIds {
-		placeholders[i] = "?"
-		args[i] = treeId
+	if err != nil {
+		return meadow, fmt.Errorf("failed to find meadow %d: %w", meadowId, err)
 	}
+	return meadow, nil
+}
 
-	query := fmt.Sprintf("SELECT ID, PlantDate, MeadowId, Position, Type FROM trees WHERE ID IN (%s) AND user_id = ?",
-		strings.Join(placeholders, ","))
+func (s *MySQLStore) FindOneTreeById(treeId int, userID int) (models.Tree, error) {
+	row := s.conn.QueryRow("SELECT "+treeColumns+" FROM trees t "+treeConditionJoin+
+		" WHERE t.ID = ? AND "+memberOf("t.MeadowId", models.RoleViewer)+" AND t.deleted_at IS NULL", treeId, userID)
+	tree, err := scanTree(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return tree, fmt.Errorf("tree %d: %w", treeId, ErrNotFound)
+	}
+	if err != nil {
+		return tree, fmt.Errorf("failed to find tree %d: %w", treeId, err)
+	}
+	return tree, nil
+}
 
-	args = append(args, userID)
+func (s *MySQLStore) GetTreeImageDb(treeID int, userID int) ([]models.Image, error) {
+	if err := authorizeTree(s.conn, treeID, userID, models.RoleViewer); err != nil {
+		return nil, err
+	}
 
-	rows, err := DB.Query(query, args...)
+	images := []models.Image{}
+	rows, err := s.conn.Query("SELECT "+imageColumns+" FROM images i JOIN trees t ON t.ID = i.tree_id"+
+		" WHERE i.tree_id = ? AND "+memberOf("t.MeadowId", models.RoleViewer)+" AND i.deleted_at IS NULL", treeID, userID)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("failed to query images: %w", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var tree models.Tree
-		if err := rows.Scan(&tree.ID, &tree.PlantDate, &tree.MeadowId, &tree.Position, &tree.Type); err != nil {
-			panic(err)
+		img, err := scanImage(rows)
+		if err != nil {
+			return nil, err
 		}
-		trees = append(trees, tree)
+		// update path to include leading slash
+		img.Path = fmt.Sprintf("/%s", img.Path)
+		images = append(images, img)
 	}
+
 	if err := rows.Err(); err != nil {
-		panic(err)
+		return nil, fmt.Errorf("failed to read images: %w", err)
 	}
-	return trees
+
+	return images, nil
 }
 
-func FindOneMeadowByIdForUser(meadowId int, userID int) models.Meadow {
-	var meadow models.Meadow
+func (s *MySQLStore) FindImageByPathForUser(path string, userID int) (models.Image, error) {
+	img, err := scanImage(s.conn.QueryRow("SELECT "+imageColumns+" FROM images i JOIN trees t ON t.ID = i.tree_id"+
+		" WHERE ? IN (i.path, i.thumbnail_path, i.medium_path) AND "+memberOf("t.MeadowId", models.RoleViewer)+
+		" AND i.deleted_at IS NULL AND t.deleted_at IS NULL", path, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return img, fmt.Errorf("image %s: %w", path, ErrNotFound)
+	}
+	if err != nil {
+		return img, fmt.Errorf("failed to find image %s: %w", path, err)
+	}
+	return img, nil
+}
 
-	row := DB.QueryRow("SELECT ID, Location, Name, Size, TreeIds FROM meadows WHERE ID = ? AND user_id = ?", meadowId, userID)
-	if err := row.Scan(&meadow.ID, &meadow.Location, &meadow.Name, &meadow.Size, &meadow.TreeIds); err != nil {
-		if err == sql.ErrNoRows {
-			fmt.Println("No meadow found with ID:", meadowId)
-			return meadow
-		}
-		panic(err)
+// Checks that the user may add images to the tree, then finds its live
+// image that was uploaded with the given SHA-256. It returns false if there
+// is none.
+func (s *MySQLStore) FindTreeImageByHashForUser(treeID int, hash string, userID int) (models.Image, bool, error) {
+	if err := authorizeTree(s.conn, treeID, userID, models.RoleEditor); err != nil {
+		return models.Image{}, false, err
+	}
+
+	img, err := scanImage(s.conn.QueryRow("SELECT "+imageColumns+" FROM images i"+
+		" WHERE i.tree_id = ? AND i.live_upload_sha256 = ?", treeID, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return img, false, nil
 	}
-	fmt.Println("Found meadow:", meadow)
-	return meadow
+	if err != nil {
+		return img, false, fmt.Errorf("failed to find image of tree %d: %w", treeID, err)
+	}
+	return img, true, nil
 }
 
-func FindOneTreeById(treeId int, userID int) models.Tree {
-	var tree models.Tree
+// Lists the images of all users, including those in the trash, whose
+// files are kept until the trash is purged
+func (s *MySQLStore) FindAllImages() ([]models.Image, error) {
+	images := []models.Image{}
 
-	row := DB.QueryRow("SELECT ID, PlantDate, MeadowId, Position, Type FROM trees WHERE ID = ? AND user_id = ?", treeId, userID)
-	if err := row.Scan(&tree.ID, &tree.PlantDate, &tree.MeadowId, &tree.Position, &tree.Type); err != nil {
-		if err == sql.ErrNoRows {
-			fmt.Printf("No tree found with ID: %d and user ID: %d\n", treeId, userID)
-			return tree
+	rows, err := s.conn.Query("SELECT " + imageColumns + " FROM images i ORDER BY i.id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query images: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		img, err := scanImage(rows)
+		if err != nil {
+			return nil, err
 		}
-		fmt.Printf("Type of tree.PlantDate: %T\n", tree.PlantDate)
-		panic(err)
+		images = append(images, img)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read images: %w", err)
 	}
-	fmt.Println("Found tree:", tree)
-	return tree
+	return images, nil
 }
 
-func GetTreeImageDb(treeID int, userID int) []models.Image {
-	var images []models.Image
+// Lists the images whose tree no longer exists
+func (s *MySQLStore) FindImagesWithoutTree() ([]models.Image, error) {
+	images := []models.Image{}
 
-	rows, err := DB.Query("SELECT id, path, description, datetime FROM images WHERE tree_id = ? AND user_id = ?", treeID, userID)
+	rows, err := s.conn.Query("SELECT " + imageColumns + " FROM images i LEFT JOIN trees t ON t.ID = i.tree_id WHERE t.ID IS NULL ORDER BY i.id")
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("failed to query images without tree: %w", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var img models.Image
-		if err := rows.Scan(&img.ID, &img.Path, &img.Description, &img.Datetime); err != nil {
-			panic(err)
+		img, err := scanImage(rows)
+		if err != nil {
+			return nil, err
 		}
-		// update path to include leading slash
-		img.Path = fmt.Sprintf("/%s", img.Path)
 		images = append(images, img)
 	}
-
 	if err := rows.Err(); err != nil {
-		panic(err)
+		return nil, fmt.Errorf("failed to read images without tree: %w", err)
+	}
+	return images, nil
+}
+
+// Permanently deletes the image's row, whether it is in the trash or not.
+// Its files are left to the caller.
+func (s *MySQLStore) PurgeImage(imageID int) error {
+	result, err := s.conn.Exec("DELETE FROM images WHERE id = ?", imageID)
+	if err != nil {
+		return fmt.Errorf("failed to purge image %d: %w", imageID, err)
 	}
 
-	return images
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("image %d: %w", imageID, ErrNotFound)
+	}
+
+	fmt.Printf("Purged image with ID %d\n", imageID)
+	return nil
 }
 
-func InsertOneMeadowForUser(meadow models.Meadow, userID int) any {
-	result, err := DB.Exec("INSERT INTO meadows (Location, Name, Size, TreeIds, user_id) VALUES (?, ?, ?, ?, ?)",
-		meadow.Location, meadow.Name, meadow.Size, meadow.TreeIds, userID)
+// Inserts the meadow into its scope, see insertMeadow
+func (s *MySQLStore) InsertOneMeadowForUser(meadow models.Meadow, userID int) (int64, error) {
+	tx, err := s.conn.Begin()
 	if err != nil {
-		panic(err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	id, err := result.LastInsertId()
+	defer tx.Rollback()
+
+	id, err := insertMeadow(tx, meadow, userID)
 	if err != nil {
-		panic(err)
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit meadow: %w", err)
 	}
 	fmt.Printf("Inserted a meadow for the user %d with ID: %d\n", userID, id)
-	return id
+	return id, nil
 }
 
-func InsertOneTreeForUser(tree models.Tree, userID int) int64 {
-	result, err := DB.Exec("INSERT INTO trees (PlantDate, MeadowId, Position, Type, user_id) VALUES (?, ?, ?, ?, ?)",
-		tree.PlantDate, tree.MeadowId, tree.Position, tree.Type, userID)
+// insertMeadow inserts a personal meadow with the user as its owner, or a
+// meadow of the organization in the meadow's scope, in which the user must
+// be at least an editor. Meadows of an organization are managed by the
+// organization's owners and get no owning member.
+func insertMeadow(tx *sql.Tx, meadow models.Meadow, userID int) (int64, error) {
+	var organizationId *int
+	if id := meadow.Scope.Organization(); id != 0 {
+		if err := authorizeOrganization(tx, id, userID, models.RoleEditor); err != nil {
+			return 0, err
+		}
+		organizationId = &id
+	}
+
+	result, err := tx.Exec("INSERT INTO meadows (Location, Name, Size, Boundary, Grid, organization_id, user_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		meadow.Location, meadow.Name, meadow.Size, meadow.Boundary, meadow.Grid, organizationId, userID)
 	if err != nil {
-		panic(err)
+		return 0, fmt.Errorf("failed to insert meadow: %w", err)
 	}
 	id, err := result.LastInsertId()
 	if err != nil {
-		panic(err)
+		return 0, fmt.Errorf("failed to read inserted meadow ID: %w", err)
 	}
-	fmt.Printf("Inserted a tree for the user %d with ID: %d\n", userID, id)
-	return id
-}
-
-func UpdateMeadowTreeIdsForUser(meadowId int, treeId int64, shouldDelete bool, userID int) error {
-	// Get current meadow
-	meadow := FindOneMeadowByIdForUser(meadowId, userID)
-
-	fmt.Printf("Current TreeIds for meadow %d: %v\n", meadowId, meadow.TreeIds)
-
-	// Check whether to add or remove the tree ID
-	if shouldDelete {
-		newTreeIds := make([]int, 0, len(meadow.TreeIds))
-		for _, id := range meadow.TreeIds {
-			if id != int(treeId) {
-				newTreeIds = append(newTreeIds, id)
-			}
+	if organizationId == nil {
+		if err := addOwner(tx, id, userID); err != nil {
+			return 0, err
 		}
-		meadow.TreeIds = newTreeIds
-	} else {
-		meadow.TreeIds = append(meadow.TreeIds, int(treeId))
 	}
+	return id, nil
+}
 
-	fmt.Printf("New TreeIds for meadow %d: %v\n", meadowId, meadow.TreeIds)
+// Inserts the tree after checking that the user may edit its meadow and
+// that its coordinates lie inside the meadow's boundary
+func (s *MySQLStore) InsertOneTreeForUser(tree models.Tree, userID int) (int64, error) {
+	if err := authorizeMeadow(s.conn, tree.MeadowId, userID, models.RoleEditor); err != nil {
+		return 0, err
+	}
 
-	// Value() method will automatically be called for TreeIds
-	result, err := DB.Exec("UPDATE meadows SET TreeIds = ? WHERE ID = ? AND user_id = ?", meadow.TreeIds, meadowId, userID)
+	var boundary models.Polygon
+	err := s.conn.QueryRow("SELECT Boundary FROM meadows WHERE ID = ?", tree.MeadowId).Scan(&boundary)
 	if err != nil {
-		fmt.Printf("ERROR executing UPDATE: %v\n", err)
-		return err
+		return 0, fmt.Errorf("failed to find meadow %d: %w", tree.MeadowId, err)
 	}
-
-	rowsAffected, _ := result.RowsAffected()
-	fmt.Printf("UPDATE affected %d rows\n", rowsAffected)
-
-	if rowsAffected == 0 {
-		return fmt.Errorf("no meadow found with ID %d", meadowId)
+	if err := checkInsideBoundary(boundary, tree); err != nil {
+		return 0, err
 	}
 
-	fmt.Printf("Successfully updated meadow %d with tree ID: %d\n", meadowId, treeId)
-	return nil
+	lat, lon := treeCoordinates(tree)
+	result, err := s.conn.Exec("INSERT INTO trees (PlantDate, MeadowId, Position, Type, variety_id, Latitude, Longitude, user_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		tree.PlantDate, tree.MeadowId, tree.Position, tree.Type, tree.VarietyId, lat, lon, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert tree: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read inserted tree ID: %w", err)
+	}
+	fmt.Printf("Inserted a tree for the user %d with ID: %d\n", userID, id)
+	return id, nil
 }
 
-func UpdateMeadowForUser(meadow models.Meadow, userID int) {
+// Updates the meadow. A new boundary must still contain all of the
+// meadow's trees that have coordinates.
+func (s *MySQLStore) UpdateMeadowForUser(meadow models.Meadow, userID int) error {
+	if err := authorizeMeadow(s.conn, meadow.ID, userID, models.RoleEditor); err != nil {
+		return err
+	}
+	if meadow.Boundary != nil {
+		if err := s.checkTreesInsideBoundary(meadow.ID, meadow.Boundary); err != nil {
+			return err
+		}
+	}
 
-	result, err := DB.Exec("UPDATE meadows SET Location = ?, Name = ?, Size = ? WHERE ID = ? AND user_id = ?",
-		meadow.Location, meadow.Name, meadow.Size, meadow.ID, userID)
+	result, err := s.conn.Exec("UPDATE meadows SET Location = ?, Name = ?, Size = ?, Boundary = ?, Grid = ? WHERE ID = ? AND deleted_at IS NULL",
+		meadow.Location, meadow.Name, meadow.Size, meadow.Boundary, meadow.Grid, meadow.ID)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("failed to update meadow: %w", err)
 	}
 
 	rowsAffected, _ := result.RowsAffected()
-	fmt.Printf("UPDATE affected %d rows\n", rowsAffected)
+	if rowsAffected == 0 {
+		return fmt.Errorf("meadow %d: %w", meadow.ID, ErrNotFound)
+	}
 
 	fmt.Printf("Successfully updated meadow %d\n", meadow.ID)
+	return nil
 }
 
-func UpdateTreeForUser(tree models.Tree, userID int) {
+// Updates the tree. New coordinates must lie inside the meadow's boundary.
+func (s *MySQLStore) UpdateTreeForUser(tree models.Tree, userID int) error {
+	if err := authorizeTree(s.conn, tree.ID, userID, models.RoleEditor); err != nil {
+		return err
+	}
+	if tree.Coordinates != nil {
+		var boundary models.Polygon
+		err := s.conn.QueryRow(`SELECT m.ID, m.Boundary FROM trees t JOIN meadows m ON m.ID = t.MeadowId
+			WHERE t.ID = ?`, tree.ID).Scan(&tree.MeadowId, &boundary)
+		if err != nil {
+			return fmt.Errorf("failed to find meadow of tree %d: %w", tree.ID, err)
+		}
+		if err := checkInsideBoundary(boundary, tree); err != nil {
+			return err
+		}
+	}
 
-	result, err := DB.Exec("UPDATE trees SET PlantDate = ?, Position = ?, Type = ? WHERE ID = ? AND user_id = ?",
-		tree.PlantDate, tree.Position, tree.Type, tree.ID, userID)
+	lat, lon := treeCoordinates(tree)
+	result, err := s.conn.Exec("UPDATE trees SET PlantDate = ?, Position = ?, Type = ?, variety_id = ?, Latitude = ?, Longitude = ? WHERE ID = ? AND deleted_at IS NULL",
+		tree.PlantDate, tree.Position, tree.Type, tree.VarietyId, lat, lon, tree.ID)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("failed to update tree: %w", err)
 	}
 
 	rowsAffected, _ := result.RowsAffected()
-	fmt.Printf("UPDATE affected %d rows\n", rowsAffected)
+	if rowsAffected == 0 {
+		return fmt.Errorf("tree %d: %w", tree.ID, ErrNotFound)
+	}
 
 	fmt.Printf("Successfully updated tree %d\n", tree.ID)
+	return nil
 }
 
-func UpdateTreeImageDescriptionDb(imageID int, description string, userID int) error {
-	result, err := DB.Exec("UPDATE images SET description = ? WHERE id = ? AND user_id = ?",
-		description, imageID, userID)
+func (s *MySQLStore) UpdateTreeImageDescriptionDb(imageID int, description string, userID int) error {
+	if err := authorizeImage(s.conn, imageID, userID, models.RoleEditor); err != nil {
+		return err
+	}
+
+	result, err := s.conn.Exec("UPDATE images SET description = ? WHERE id = ? AND deleted_at IS NULL",
+		description, imageID)
 	if err != nil {
 		return fmt.Errorf("failed to update image description: %w", err)
 	}
 
-	fmt.Printf("Executing UPDATE for image %d with new description: %s\n", imageID, description)
-
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		return fmt.Errorf("no image found with ID %d and user ID %d", imageID, userID)
+		return fmt.Errorf("image %d: %w", imageID, ErrNotFound)
 	}
 
 	fmt.Printf("Updated image description for image %d\n", imageID)
 	return nil
 }
 
-func UpdateTreeImageDatetimeDb(imageID int, datetime time.Time, userID int) error {
-	result, err := DB.Exec("UPDATE images SET datetime = ? WHERE id = ? AND user_id = ?",
-		datetime, imageID, userID)
+func (s *MySQLStore) UpdateTreeImageDatetimeDb(imageID int, datetime time.Time, userID int) error {
+	if err := authorizeImage(s.conn, imageID, userID, models.RoleEditor); err != nil {
+		return err
+	}
+
+	result, err := s.conn.Exec("UPDATE images SET datetime = ? WHERE id = ? AND deleted_at IS NULL",
+		datetime, imageID)
 	if err != nil {
 		return fmt.Errorf("failed to update image datetime: %w", err)
 	}
 
-	fmt.Printf("Executing UPDATE for image %d with new datetime: %s\n", imageID, datetime.String())
-
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		return fmt.Errorf("no image found with ID %d and user ID %d", imageID, userID)
+		return fmt.Errorf("image %d: %w", imageID, ErrNotFound)
 	}
 
 	fmt.Printf("Updated image datetime for image %d\n", imageID)
 	return nil
 }
 
-func UploadImageDb(path string, description string, userID int, treeID int) error {
-	_, err := DB.Exec("INSERT INTO images (path, description, user_id, tree_id) VALUES (?, ?, ?, ?)",
-		path, description, userID, treeID)
+// Inserts the image with the paths of its files and its metadata and
+// returns its ID. Images without a Datetime are dated now. If the tree
+// already has a live image uploaded with the same SHA-256, it fails with
+// ErrConflict.
+func (s *MySQLStore) UploadImageDb(image models.Image, userID int) (int64, error) {
+	if err := authorizeTree(s.conn, image.TreeId, userID, models.RoleEditor); err != nil {
+		return 0, err
+	}
+
+	if image.Datetime.IsZero() {
+		image.Datetime = time.Now()
+	}
+	var metadata models.ImageMetadata
+	if image.Metadata != nil {
+		metadata = *image.Metadata
+	}
+	var lat, lon any
+	if metadata.Coordinates != nil {
+		lat, lon = metadata.Coordinates.Lat, metadata.Coordinates.Lon
+	}
+
+	result, err := s.conn.Exec("INSERT INTO images (path, thumbnail_path, medium_path, sha256, upload_sha256, description, datetime, user_id, tree_id,"+
+		" Latitude, Longitude, camera_model, orientation) VALUES (?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), ?, ?, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, 0))",
+		image.Path, image.ThumbnailPath, image.MediumPath, image.SHA256, image.UploadSHA256, image.Description, image.Datetime, userID, image.TreeId,
+		lat, lon, metadata.CameraModel, metadata.Orientation)
+	if isDuplicateEntry(err) {
+		return 0, fmt.Errorf("tree %d already has an image with the same content: %w", image.TreeId, ErrConflict)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to upload image to database: %w", err)
+		return 0, fmt.Errorf("failed to upload image to database: %w", err)
 	}
 
-	fmt.Printf("Uploaded image for user %d with path: %s\n", userID, path)
-	return nil
+	fmt.Printf("Uploaded image for user %d with path: %s\n", userID, image.Path)
+	return result.LastInsertId()
 }
 
-// Deletes only the tree from the database, does not update meadow's TreeIds
-func deleteTreeOnly(treeId int, userID int) error {
-	result, err := DB.Exec("DELETE FROM trees WHERE ID = ? AND user_id = ?", treeId, userID)
-	if err != nil {
-		return fmt.Errorf("failed to delete tree: %w", err)
+// scanImage reads a row selected with imageColumns
+func scanImage(row interface{ Scan(dest ...any) error }) (models.Image, error) {
+	var img models.Image
+	var thumbnailPath, mediumPath, sha256, uploadSHA256, cameraModel sql.NullString
+	var lat, lon sql.NullFloat64
+	var orientation sql.NullInt64
+
+	if err := row.Scan(&img.ID, &img.TreeId, &img.Path, &thumbnailPath, &mediumPath, &sha256, &uploadSHA256, &img.Description, &img.Datetime, &img.DeletedAt,
+		&lat, &lon, &cameraModel, &orientation); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return img, err
+		}
+		return img, fmt.Errorf("failed to scan image: %w", err)
 	}
+	img.ThumbnailPath = thumbnailPath.String
+	img.MediumPath = mediumPath.String
+	img.SHA256 = sha256.String
+	img.UploadSHA256 = uploadSHA256.String
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return fmt.Errorf("no tree found with ID %d and user ID %d", treeId, userID)
+	if lat.Valid || cameraModel.Valid || orientation.Valid {
+		img.Metadata = &models.ImageMetadata{CameraModel: cameraModel.String, Orientation: int(orientation.Int64)}
+		if lat.Valid && lon.Valid {
+			img.Metadata.Coordinates = &models.LatLon{Lat: lat.Float64, Lon: lon.Float64}
+		}
 	}
+	return img, nil
+}
 
-	fmt.Printf("Deleted tree for user %d with ID: %d\n", userID, treeId)
-	return nil
+// scanMeadow reads a row selected with meadowColumns
+func scanMeadow(row interface{ Scan(dest ...any) error }) (models.Meadow, error) {
+	var meadow models.Meadow
+	var organizationId sql.NullInt64
+	var organizationName sql.NullString
+
+	if err := row.Scan(&meadow.ID, &meadow.Location, &meadow.Name, &meadow.Size, &meadow.TreeIds, &meadow.Boundary, &meadow.Grid,
+		&meadow.Role, &organizationId, &organizationName); err != nil {
+		return meadow, err
+	}
+	meadow.Area = meadow.Boundary.Area()
+	meadow.Scope = models.NewMeadowScope(int(organizationId.Int64), organizationName.String)
+	return meadow, nil
+}
+
+// scanTree reads a row selected with treeColumns
+func scanTree(row interface{ Scan(dest ...any) error }) (models.Tree, error) {
+	var tree models.Tree
+	var lat, lon sql.NullFloat64
+	var inspectionID sql.NullInt64
+	var date sql.NullTime
+	var vitality sql.NullInt64
+	var diseases, pests []byte
+
+	if err := row.Scan(&tree.ID, &tree.PlantDate, &tree.MeadowId, &tree.Position, &tree.Type, &tree.VarietyId, &lat, &lon, &tree.DeletedAt,
+		&inspectionID, &date, &vitality, &diseases, &pests); err != nil {
+		return tree, fmt.Errorf("failed to scan tree: %w", err)
+	}
+
+	if lat.Valid && lon.Valid {
+		tree.Coordinates = &models.LatLon{Lat: lat.Float64, Lon: lon.Float64}
+	}
+	if inspectionID.Valid {
+		tree.Condition = &models.TreeCondition{
+			InspectionId: int(inspectionID.Int64),
+			Date:         date.Time,
+			Vitality:     int(vitality.Int64),
+		}
+		if err := tree.Condition.Diseases.Scan(diseases); err != nil {
+			return tree, fmt.Errorf("failed to scan tree condition: %w", err)
+		}
+		if err := tree.Condition.Pests.Scan(pests); err != nil {
+			return tree, fmt.Errorf("failed to scan tree condition: %w", err)
+		}
+	}
+	return tree, nil
 }
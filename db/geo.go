@@ -0,0 +1,61 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+// checkInsideBoundary rejects trees whose coordinates lie outside the
+// boundary. Trees without coordinates and meadows without a boundary are
+// not checked.
+func checkInsideBoundary(boundary models.Polygon, tree models.Tree) error {
+	if boundary == nil || tree.Coordinates == nil {
+		return nil
+	}
+	if !boundary.Contains(*tree.Coordinates) {
+		return fmt.Errorf("coordinates %v, %v lie outside the boundary of meadow %d: %w",
+			tree.Coordinates.Lat, tree.Coordinates.Lon, tree.MeadowId, ErrInvalidInput)
+	}
+	return nil
+}
+
+// treeCoordinates returns the latitude and longitude columns of the tree,
+// both NULL when it has no coordinates.
+func treeCoordinates(tree models.Tree) (any, any) {
+	if tree.Coordinates == nil {
+		return nil, nil
+	}
+	return tree.Coordinates.Lat, tree.Coordinates.Lon
+}
+
+// checkTreesInsideBoundary rejects a new boundary that would leave trees of
+// the meadow outside.
+func (s *MySQLStore) checkTreesInsideBoundary(meadowId int, boundary models.Polygon) error {
+	rows, err := s.conn.Query(`SELECT ID, Latitude, Longitude FROM trees
+		WHERE MeadowId = ? AND deleted_at IS NULL AND Latitude IS NOT NULL AND Longitude IS NOT NULL`, meadowId)
+	if err != nil {
+		return fmt.Errorf("failed to query tree coordinates: %w", err)
+	}
+	defer rows.Close()
+
+	outside := []int{}
+	for rows.Next() {
+		var id int
+		var point models.LatLon
+		if err := rows.Scan(&id, &point.Lat, &point.Lon); err != nil {
+			return fmt.Errorf("failed to scan tree coordinates: %w", err)
+		}
+		if !boundary.Contains(point) {
+			outside = append(outside, id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read tree coordinates: %w", err)
+	}
+
+	if len(outside) > 0 {
+		return fmt.Errorf("trees %v of meadow %d would lie outside the boundary: %w", outside, meadowId, ErrConflict)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+const harvestColumns = "h.id, h.tree_id, h.date, h.quantity, h.unit, h.grade, h.notes"
+
+// harvestKgColumn converts h.quantity to kilograms with models.UnitKilograms.
+var harvestKgColumn = func() string {
+	units := make([]string, 0, len(models.UnitKilograms))
+	for unit := range models.UnitKilograms {
+		units = append(units, unit)
+	}
+	slices.Sort(units)
+
+	var b strings.Builder
+	b.WriteString("CASE h.unit")
+	for _, unit := range units {
+		fmt.Fprintf(&b, " WHEN '%s' THEN h.quantity * %g", unit, models.UnitKilograms[unit])
+	}
+	b.WriteString(" END")
+	return b.String()
+}()
+
+func (s *MySQLStore) DeleteHarvestForUser(harvestId int, treeId int, userID int) error {
+	if err := authorizeTree(s.conn, treeId, userID, models.RoleEditor); err != nil {
+		return err
+	}
+
+	result, err := s.conn.Exec("DELETE FROM harvests WHERE id = ? AND tree_id = ?", harvestId, treeId)
+	if err != nil {
+		return fmt.Errorf("failed to delete harvest %d: %w", harvestId, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("harvest %d: %w", harvestId, ErrNotFound)
+	}
+
+	fmt.Printf("Deleted harvest of user %d with ID %d\n", userID, harvestId)
+	return nil
+}
+
+// Lists the harvests of the tree, latest first
+func (s *MySQLStore) FindHarvestsForTree(treeId int, userID int) ([]models.Harvest, error) {
+	harvests := []models.Harvest{}
+
+	if _, err := s.FindOneTreeById(treeId, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.conn.Query("SELECT "+harvestColumns+" FROM harvests h WHERE h.tree_id = ? ORDER BY h.date DESC, h.id DESC", treeId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query harvests: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		harvest, err := scanHarvest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan harvest: %w", err)
+		}
+		harvests = append(harvests, harvest)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read harvests: %w", err)
+	}
+	return harvests, nil
+}
+
+func (s *MySQLStore) FindOneHarvestForUser(harvestId int, treeId int, userID int) (models.Harvest, error) {
+	row := s.conn.QueryRow(`SELECT `+harvestColumns+` FROM harvests h JOIN trees t ON t.ID = h.tree_id
+		WHERE h.id = ? AND h.tree_id = ? AND `+memberOf("t.MeadowId", models.RoleViewer)+` AND t.deleted_at IS NULL`, harvestId, treeId, userID)
+	harvest, err := scanHarvest(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return harvest, fmt.Errorf("harvest %d: %w", harvestId, ErrNotFound)
+	}
+	if err != nil {
+		return harvest, fmt.Errorf("failed to find harvest %d: %w", harvestId, err)
+	}
+	return harvest, nil
+}
+
+// Sums up the harvests of the user's meadows per year and group, ordered by
+// year
+func (s *MySQLStore) FindYieldForUser(userID int, group YieldGroup, filter YieldFilter) ([]models.YieldStat, error) {
+	stats := []models.YieldStat{}
+
+	var columns, groupBy string
+	switch group {
+	case YieldByTree:
+		columns, groupBy = "t.ID, t.MeadowId, t.Type", "t.ID"
+	case YieldByVariety:
+		columns, groupBy = "0, 0, t.Type", "t.Type"
+	case YieldByMeadow:
+		columns, groupBy = "0, m.ID, NULL", "m.ID"
+	default:
+		return nil, fmt.Errorf("yield group %q: %w", group, ErrInvalidInput)
+	}
+
+	query := "SELECT YEAR(h.date), " + columns + ", COUNT(*), SUM(" + harvestKgColumn + ")" +
+		" FROM harvests h JOIN trees t ON t.ID = h.tree_id JOIN meadows m ON m.ID = t.MeadowId" +
+		" WHERE " + memberOf("m.ID", models.RoleViewer) + " AND t.deleted_at IS NULL AND m.deleted_at IS NULL"
+	args := []any{userID}
+
+	if filter.MeadowId != 0 {
+		query += " AND m.ID = ?"
+		args = append(args, filter.MeadowId)
+	}
+	if filter.FromYear != 0 {
+		query += " AND YEAR(h.date) >= ?"
+		args = append(args, filter.FromYear)
+	}
+	if filter.ToYear != 0 {
+		query += " AND YEAR(h.date) <= ?"
+		args = append(args, filter.ToYear)
+	}
+	query += " GROUP BY YEAR(h.date), " + groupBy + " ORDER BY YEAR(h.date), " + groupBy
+
+	rows, err := s.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query yield: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var stat models.YieldStat
+		var variety sql.NullString
+		if err := rows.Scan(&stat.Year, &stat.TreeId, &stat.MeadowId, &variety, &stat.Harvests, &stat.QuantityKg); err != nil {
+			return nil, fmt.Errorf("failed to scan yield: %w", err)
+		}
+		if variety.Valid {
+			stat.Variety = &variety.String
+		}
+		stats = append(stats, stat)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read yield: %w", err)
+	}
+	return stats, nil
+}
+
+func (s *MySQLStore) InsertHarvestForUser(harvest models.Harvest, userID int) (int64, error) {
+	if err := authorizeTree(s.conn, harvest.TreeId, userID, models.RoleEditor); err != nil {
+		return 0, err
+	}
+
+	result, err := s.conn.Exec(`INSERT INTO harvests (tree_id, user_id, date, quantity, unit, grade, notes)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		harvest.TreeId, userID, harvest.Date, harvest.Quantity, harvest.Unit, harvest.Grade, harvest.Notes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert harvest: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get inserted harvest ID: %w", err)
+	}
+
+	fmt.Printf("Inserted harvest of user %d with ID %d\n", userID, id)
+	return id, nil
+}
+
+func (s *MySQLStore) UpdateHarvestForUser(harvest models.Harvest, userID int) error {
+	if err := authorizeTree(s.conn, harvest.TreeId, userID, models.RoleEditor); err != nil {
+		return err
+	}
+
+	result, err := s.conn.Exec(`UPDATE harvests SET date = ?, quantity = ?, unit = ?, grade = ?, notes = ?
+		WHERE id = ? AND tree_id = ?`,
+		harvest.Date, harvest.Quantity, harvest.Unit, harvest.Grade, harvest.Notes,
+		harvest.ID, harvest.TreeId)
+	if err != nil {
+		return fmt.Errorf("failed to update harvest %d: %w", harvest.ID, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("harvest %d: %w", harvest.ID, ErrNotFound)
+	}
+
+	fmt.Printf("Updated harvest of user %d with ID %d\n", userID, harvest.ID)
+	return nil
+}
+
+// scanHarvest reads a row selected with harvestColumns
+func scanHarvest(row interface{ Scan(dest ...any) error }) (models.Harvest, error) {
+	var harvest models.Harvest
+	err := row.Scan(&harvest.ID, &harvest.TreeId, &harvest.Date, &harvest.Quantity, &harvest.Unit, &harvest.Grade, &harvest.Notes)
+	return harvest, err
+}
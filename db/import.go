@@ -0,0 +1,109 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+// Creates the meadow together with its trees in one transaction and returns
+// the new IDs. The meadow is inserted into its scope like by
+// InsertOneMeadowForUser, and the trees are checked against its boundary.
+func (s *MySQLStore) ImportMeadowForUser(meadow models.Meadow, trees []models.Tree, userID int) (int64, []int64, error) {
+	for i, tree := range trees {
+		if err := checkInsideBoundary(meadow.Boundary, tree); err != nil {
+			return 0, nil, fmt.Errorf("tree %d: %w", i, err)
+		}
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	meadowId, err := insertMeadow(tx, meadow, userID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	treeIds, err := insertTrees(tx, int(meadowId), trees, userID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, nil, fmt.Errorf("failed to commit meadow import: %w", err)
+	}
+
+	fmt.Printf("Imported a meadow for the user %d with ID %d and %d trees\n", userID, meadowId, len(treeIds))
+	return meadowId, treeIds, nil
+}
+
+// insertTrees inserts the trees into the meadow with one prepared statement
+// and returns their IDs in order.
+func insertTrees(tx *sql.Tx, meadowId int, trees []models.Tree, userID int) ([]int64, error) {
+	ids := []int64{}
+
+	stmt, err := tx.Prepare("INSERT INTO trees (PlantDate, MeadowId, Position, Type, variety_id, Latitude, Longitude, user_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare tree insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for i, tree := range trees {
+		lat, lon := treeCoordinates(tree)
+		result, err := stmt.Exec(tree.PlantDate, meadowId, tree.Position, tree.Type, tree.VarietyId, lat, lon, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert tree %d: %w", i, err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read inserted tree ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Inserts the trees into the meadow in one transaction, after checking that
+// the user may edit the meadow and that the trees lie inside its boundary
+func (s *MySQLStore) InsertTreesForUser(meadowId int, trees []models.Tree, userID int) ([]int64, error) {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := authorizeMeadow(tx, meadowId, userID, models.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	var boundary models.Polygon
+	err = tx.QueryRow("SELECT Boundary FROM meadows WHERE ID = ? AND deleted_at IS NULL FOR UPDATE", meadowId).Scan(&boundary)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("meadow %d: %w", meadowId, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock meadow %d: %w", meadowId, err)
+	}
+	for i, tree := range trees {
+		tree.MeadowId = meadowId
+		if err := checkInsideBoundary(boundary, tree); err != nil {
+			return nil, fmt.Errorf("tree %d: %w", i, err)
+		}
+	}
+
+	ids, err := insertTrees(tx, meadowId, trees, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tree import: %w", err)
+	}
+
+	fmt.Printf("Imported %d trees for the user %d into meadow %d\n", len(ids), userID, meadowId)
+	return ids, nil
+}
@@ -0,0 +1,180 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+// inspectionColumns selects an inspection aliased as ti together with the IDs
+// of its linked images that are not in the trash.
+const inspectionColumns = "ti.id, ti.tree_id, ti.date, ti.inspector, ti.vitality, ti.diseases, ti.pests, ti.notes, " +
+	"COALESCE((SELECT JSON_ARRAYAGG(ii.image_id) FROM inspection_images ii JOIN images i ON i.id = ii.image_id " +
+	"WHERE ii.inspection_id = ti.id AND i.deleted_at IS NULL), JSON_ARRAY())"
+
+func (s *MySQLStore) DeleteInspectionForUser(inspectionId int, treeId int, userID int) error {
+	if err := authorizeTree(s.conn, treeId, userID, models.RoleEditor); err != nil {
+		return err
+	}
+
+	result, err := s.conn.Exec("DELETE FROM tree_inspections WHERE id = ? AND tree_id = ?", inspectionId, treeId)
+	if err != nil {
+		return fmt.Errorf("failed to delete inspection %d: %w", inspectionId, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("inspection %d: %w", inspectionId, ErrNotFound)
+	}
+
+	fmt.Printf("Deleted inspection of user %d with ID %d\n", userID, inspectionId)
+	return nil
+}
+
+// Lists the inspections of the tree, latest first
+func (s *MySQLStore) FindInspectionsForTree(treeId int, userID int) ([]models.TreeInspection, error) {
+	inspections := []models.TreeInspection{}
+
+	if _, err := s.FindOneTreeById(treeId, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.conn.Query("SELECT "+inspectionColumns+" FROM tree_inspections ti WHERE ti.tree_id = ? ORDER BY ti.date DESC, ti.id DESC", treeId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query inspections: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		inspection, err := scanInspection(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan inspection: %w", err)
+		}
+		inspections = append(inspections, inspection)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read inspections: %w", err)
+	}
+	return inspections, nil
+}
+
+func (s *MySQLStore) FindOneInspectionForUser(inspectionId int, treeId int, userID int) (models.TreeInspection, error) {
+	row := s.conn.QueryRow(`SELECT `+inspectionColumns+` FROM tree_inspections ti JOIN trees t ON t.ID = ti.tree_id
+		WHERE ti.id = ? AND ti.tree_id = ? AND `+memberOf("t.MeadowId", models.RoleViewer)+` AND t.deleted_at IS NULL`, inspectionId, treeId, userID)
+	inspection, err := scanInspection(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return inspection, fmt.Errorf("inspection %d: %w", inspectionId, ErrNotFound)
+	}
+	if err != nil {
+		return inspection, fmt.Errorf("failed to find inspection %d: %w", inspectionId, err)
+	}
+	return inspection, nil
+}
+
+// Inserts the inspection and links its images in one transaction
+func (s *MySQLStore) InsertInspectionForUser(inspection models.TreeInspection, userID int) (int64, error) {
+	if err := authorizeTree(s.conn, inspection.TreeId, userID, models.RoleEditor); err != nil {
+		return 0, err
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO tree_inspections (tree_id, user_id, date, inspector, vitality, diseases, pests, notes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		inspection.TreeId, userID, inspection.Date, inspection.Inspector, inspection.Vitality, inspection.Diseases, inspection.Pests, inspection.Notes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert inspection: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get inserted inspection ID: %w", err)
+	}
+
+	if err := linkInspectionImages(tx, int(id), inspection); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit inspection: %w", err)
+	}
+
+	fmt.Printf("Inserted inspection of user %d with ID %d\n", userID, id)
+	return id, nil
+}
+
+// Updates the inspection and replaces its image links in one transaction
+func (s *MySQLStore) UpdateInspectionForUser(inspection models.TreeInspection, userID int) error {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := authorizeTree(tx, inspection.TreeId, userID, models.RoleEditor); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`UPDATE tree_inspections
+		SET date = ?, inspector = ?, vitality = ?, diseases = ?, pests = ?, notes = ?
+		WHERE id = ? AND tree_id = ?`,
+		inspection.Date, inspection.Inspector, inspection.Vitality, inspection.Diseases, inspection.Pests, inspection.Notes,
+		inspection.ID, inspection.TreeId)
+	if err != nil {
+		return fmt.Errorf("failed to update inspection %d: %w", inspection.ID, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("inspection %d: %w", inspection.ID, ErrNotFound)
+	}
+
+	if _, err := tx.Exec("DELETE FROM inspection_images WHERE inspection_id = ?", inspection.ID); err != nil {
+		return fmt.Errorf("failed to unlink images of inspection %d: %w", inspection.ID, err)
+	}
+	if err := linkInspectionImages(tx, inspection.ID, inspection); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit inspection update: %w", err)
+	}
+
+	fmt.Printf("Updated inspection of user %d with ID %d\n", userID, inspection.ID)
+	return nil
+}
+
+// linkInspectionImages links the inspection's images after checking that
+// they are live images of the inspected tree.
+func linkInspectionImages(tx *sql.Tx, inspectionId int, inspection models.TreeInspection) error {
+	for _, imageID := range inspection.ImageIds {
+		var id int
+		err := tx.QueryRow("SELECT id FROM images WHERE id = ? AND tree_id = ? AND deleted_at IS NULL",
+			imageID, inspection.TreeId).Scan(&id)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("image %d is not an image of tree %d: %w", imageID, inspection.TreeId, ErrInvalidInput)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check image %d: %w", imageID, err)
+		}
+
+		if _, err := tx.Exec("INSERT IGNORE INTO inspection_images (inspection_id, image_id) VALUES (?, ?)", inspectionId, imageID); err != nil {
+			return fmt.Errorf("failed to link image %d: %w", imageID, err)
+		}
+	}
+	return nil
+}
+
+// scanInspection reads a row selected with inspectionColumns
+func scanInspection(row interface{ Scan(dest ...any) error }) (models.TreeInspection, error) {
+	var inspection models.TreeInspection
+	err := row.Scan(&inspection.ID, &inspection.TreeId, &inspection.Date, &inspection.Inspector, &inspection.Vitality,
+		&inspection.Diseases, &inspection.Pests, &inspection.Notes, &inspection.ImageIds)
+	return inspection, err
+}
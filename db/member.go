@@ -0,0 +1,304 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+// Accepts the invitation addressed to the user's email address, which makes
+// the user a member of the meadow, and returns the meadow's ID.
+func (s *MySQLStore) AcceptInvitationForUser(invitationId int, userID int) (int, error) {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var meadowId int
+	var role models.Role
+	err = tx.QueryRow(`SELECT i.meadow_id, i.role FROM meadow_invitations i
+		JOIN users u ON u.email = i.email
+		JOIN meadows m ON m.ID = i.meadow_id
+		WHERE i.id = ? AND u.ID = ? AND u.email_verified_at IS NOT NULL AND m.deleted_at IS NULL FOR UPDATE`, invitationId, userID).Scan(&meadowId, &role)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("invitation %d: %w", invitationId, ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to query invitation %d: %w", invitationId, err)
+	}
+
+	_, err = tx.Exec("INSERT INTO meadow_members (meadow_id, user_id, role) VALUES (?, ?, ?)", meadowId, userID, role)
+	if isDuplicateEntry(err) {
+		return 0, fmt.Errorf("user %d is already a member of meadow %d: %w", userID, meadowId, ErrConflict)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to add member to meadow %d: %w", meadowId, err)
+	}
+	if _, err := tx.Exec("DELETE FROM meadow_invitations WHERE id = ?", invitationId); err != nil {
+		return 0, fmt.Errorf("failed to delete invitation %d: %w", invitationId, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit invitation %d: %w", invitationId, err)
+	}
+
+	fmt.Printf("User %d accepted invitation %d to meadow %d as %s\n", userID, invitationId, meadowId, role)
+	return meadowId, nil
+}
+
+// Deletes the invitation addressed to the user's email address
+func (s *MySQLStore) DeclineInvitationForUser(invitationId int, userID int) error {
+	result, err := s.conn.Exec(`DELETE i FROM meadow_invitations i
+		JOIN users u ON u.email = i.email
+		WHERE i.id = ? AND u.ID = ? AND u.email_verified_at IS NOT NULL`, invitationId, userID)
+	if err != nil {
+		return fmt.Errorf("failed to decline invitation %d: %w", invitationId, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("invitation %d: %w", invitationId, ErrNotFound)
+	}
+
+	fmt.Printf("User %d declined invitation %d\n", userID, invitationId)
+	return nil
+}
+
+// Revokes a pending invitation to the meadow. Only owners may do that.
+func (s *MySQLStore) DeleteInvitationForUser(invitationId int, meadowId int, userID int) error {
+	if err := authorizeMeadow(s.conn, meadowId, userID, models.RoleOwner); err != nil {
+		return err
+	}
+
+	result, err := s.conn.Exec("DELETE FROM meadow_invitations WHERE id = ? AND meadow_id = ?", invitationId, meadowId)
+	if err != nil {
+		return fmt.Errorf("failed to delete invitation %d: %w", invitationId, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("invitation %d: %w", invitationId, ErrNotFound)
+	}
+
+	fmt.Printf("User %d revoked invitation %d to meadow %d\n", userID, invitationId, meadowId)
+	return nil
+}
+
+// Removes a member from the meadow. Owners may remove anyone, other members
+// only themselves, and the last owner may not be removed at all.
+func (s *MySQLStore) DeleteMemberForUser(meadowId int, memberId int, userID int) error {
+	need := models.RoleOwner
+	if memberId == userID {
+		need = models.RoleViewer
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := authorizeMeadow(tx, meadowId, userID, need); err != nil {
+		return err
+	}
+	role, err := memberRole(tx, meadowId, memberId)
+	if err != nil {
+		return err
+	}
+	if role == models.RoleOwner {
+		if err := checkOtherOwners(tx, meadowId); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM meadow_members WHERE meadow_id = ? AND user_id = ?", meadowId, memberId); err != nil {
+		return fmt.Errorf("failed to remove member %d from meadow %d: %w", memberId, meadowId, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit member removal: %w", err)
+	}
+
+	fmt.Printf("User %d removed member %d from meadow %d\n", userID, memberId, meadowId)
+	return nil
+}
+
+// Lists the pending invitations to the meadow. Only owners may see them.
+func (s *MySQLStore) FindInvitationsForMeadow(meadowId int, userID int) ([]models.Invitation, error) {
+	if err := authorizeMeadow(s.conn, meadowId, userID, models.RoleOwner); err != nil {
+		return nil, err
+	}
+	return s.queryInvitations("WHERE i.meadow_id = ?", meadowId)
+}
+
+// Lists the pending invitations addressed to the user's email address
+func (s *MySQLStore) FindInvitationsForUser(userID int) ([]models.Invitation, error) {
+	return s.queryInvitations("JOIN users r ON r.email = i.email WHERE r.ID = ? AND r.email_verified_at IS NOT NULL AND m.deleted_at IS NULL", userID)
+}
+
+func (s *MySQLStore) queryInvitations(condition string, args ...any) ([]models.Invitation, error) {
+	rows, err := s.conn.Query(`SELECT i.id, i.meadow_id, m.Name, i.email, i.role, u.username, i.created_at
+		FROM meadow_invitations i
+		JOIN meadows m ON m.ID = i.meadow_id
+		JOIN users u ON u.ID = i.invited_by `+condition+` ORDER BY i.id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invitations: %w", err)
+	}
+	defer rows.Close()
+
+	invitations := []models.Invitation{}
+	for rows.Next() {
+		var invitation models.Invitation
+		if err := rows.Scan(&invitation.ID, &invitation.MeadowId, &invitation.MeadowName, &invitation.Email,
+			&invitation.Role, &invitation.InvitedBy, &invitation.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan invitation: %w", err)
+		}
+		invitations = append(invitations, invitation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read invitations: %w", err)
+	}
+	return invitations, nil
+}
+
+// Lists the members of the meadow, to any of its members
+func (s *MySQLStore) FindMembersForMeadow(meadowId int, userID int) ([]models.MeadowMember, error) {
+	if err := authorizeMeadow(s.conn, meadowId, userID, models.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.conn.Query(`SELECT mm.meadow_id, mm.user_id, u.username, mm.role, mm.created_at
+		FROM meadow_members mm JOIN users u ON u.ID = mm.user_id
+		WHERE mm.meadow_id = ? ORDER BY mm.created_at, mm.user_id`, meadowId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query members of meadow %d: %w", meadowId, err)
+	}
+	defer rows.Close()
+
+	members := []models.MeadowMember{}
+	for rows.Next() {
+		var member models.MeadowMember
+		if err := rows.Scan(&member.MeadowId, &member.UserId, &member.Username, &member.Role, &member.Since); err != nil {
+			return nil, fmt.Errorf("failed to scan member: %w", err)
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read members: %w", err)
+	}
+	return members, nil
+}
+
+// Invites the owner of the email address to the meadow. Only owners may
+// invite, and neither members nor addresses with a pending invitation can
+// be invited again.
+func (s *MySQLStore) InsertInvitationForUser(invitation models.Invitation, userID int) (int64, error) {
+	if err := authorizeMeadow(s.conn, invitation.MeadowId, userID, models.RoleOwner); err != nil {
+		return 0, err
+	}
+
+	var members int
+	err := s.conn.QueryRow(`SELECT COUNT(*) FROM meadow_members mm JOIN users u ON u.ID = mm.user_id
+		WHERE mm.meadow_id = ? AND u.email = ?`, invitation.MeadowId, invitation.Email).Scan(&members)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check members of meadow %d: %w", invitation.MeadowId, err)
+	}
+	if members > 0 {
+		return 0, fmt.Errorf("%s is already a member of meadow %d: %w", invitation.Email, invitation.MeadowId, ErrConflict)
+	}
+
+	result, err := s.conn.Exec("INSERT INTO meadow_invitations (meadow_id, email, role, invited_by) VALUES (?, ?, ?, ?)",
+		invitation.MeadowId, invitation.Email, invitation.Role, userID)
+	if isDuplicateEntry(err) {
+		return 0, fmt.Errorf("%s is already invited to meadow %d: %w", invitation.Email, invitation.MeadowId, ErrConflict)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert invitation: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read inserted invitation ID: %w", err)
+	}
+
+	fmt.Printf("User %d invited %s to meadow %d as %s\n", userID, invitation.Email, invitation.MeadowId, invitation.Role)
+	return id, nil
+}
+
+// Changes the role of a member of the meadow. Only owners may do that, and
+// the last owner may not be demoted.
+func (s *MySQLStore) UpdateMemberForUser(meadowId int, memberId int, role models.Role, userID int) error {
+	if err := role.Validate(); err != nil {
+		return fmt.Errorf("%v: %w", err, ErrInvalidInput)
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := authorizeMeadow(tx, meadowId, userID, models.RoleOwner); err != nil {
+		return err
+	}
+	current, err := memberRole(tx, meadowId, memberId)
+	if err != nil {
+		return err
+	}
+	if current == models.RoleOwner && role != models.RoleOwner {
+		if err := checkOtherOwners(tx, meadowId); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE meadow_members SET role = ? WHERE meadow_id = ? AND user_id = ?", role, meadowId, memberId); err != nil {
+		return fmt.Errorf("failed to update member %d of meadow %d: %w", memberId, meadowId, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit member update: %w", err)
+	}
+
+	fmt.Printf("User %d made member %d of meadow %d %s\n", userID, memberId, meadowId, role)
+	return nil
+}
+
+// memberRole reads and locks the member's role in the meadow.
+func memberRole(tx *sql.Tx, meadowId int, memberId int) (models.Role, error) {
+	var role models.Role
+	err := tx.QueryRow("SELECT role FROM meadow_members WHERE meadow_id = ? AND user_id = ? FOR UPDATE",
+		meadowId, memberId).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("member %d of meadow %d: %w", memberId, meadowId, ErrNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query member %d of meadow %d: %w", memberId, meadowId, err)
+	}
+	return role, nil
+}
+
+// checkOtherOwners fails with ErrConflict unless the meadow has more than
+// one owner, so that an owner can be removed or demoted. Meadows of an
+// organization are managed by its owners and need no owning member.
+func checkOtherOwners(tx *sql.Tx, meadowId int) error {
+	var organizationId sql.NullInt64
+	if err := tx.QueryRow("SELECT organization_id FROM meadows WHERE ID = ?", meadowId).Scan(&organizationId); err != nil {
+		return fmt.Errorf("failed to query meadow %d: %w", meadowId, err)
+	}
+	if organizationId.Valid {
+		return nil
+	}
+
+	var owners int
+	err := tx.QueryRow("SELECT COUNT(*) FROM meadow_members WHERE meadow_id = ? AND role = ? FOR UPDATE",
+		meadowId, models.RoleOwner).Scan(&owners)
+	if err != nil {
+		return fmt.Errorf("failed to count owners of meadow %d: %w", meadowId, err)
+	}
+	if owners <= 1 {
+		return fmt.Errorf("meadow %d needs at least one owner: %w", meadowId, ErrConflict)
+	}
+	return nil
+}
@@ -0,0 +1,882 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+type memoryMeadow struct {
+	userID int
+	meadow models.Meadow
+}
+
+type memoryTree struct {
+	userID int
+	tree   models.Tree
+}
+
+type memoryInspection struct {
+	userID     int
+	inspection models.TreeInspection
+}
+
+type memoryHarvest struct {
+	userID  int
+	harvest models.Harvest
+}
+
+type memoryTask struct {
+	userID int
+	task   models.Task
+}
+
+type memoryImage struct {
+	userID int
+	image  models.Image
+}
+
+// MemoryStore implements the store interfaces in memory. It is meant for
+// tests and local experiments; nothing is persisted and no files are touched.
+type MemoryStore struct {
+	mu          sync.Mutex
+	nextID      int
+	meadows     map[int]memoryMeadow
+	trees       map[int]memoryTree
+	inspections map[int]memoryInspection
+	harvests    map[int]memoryHarvest
+	tasks       map[int]memoryTask
+	images      map[int]memoryImage
+	users       map[int]models.User
+	members     map[int]map[int]memoryMember
+	invitations map[int]memoryInvitation
+	orgs        map[int]models.Organization
+	orgMembers  map[int]map[int]memoryMember
+	families    map[string]memoryTokenFamily
+	tokens      map[string]memoryRefreshToken
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		meadows:     make(map[int]memoryMeadow),
+		trees:       make(map[int]memoryTree),
+		inspections: make(map[int]memoryInspection),
+		harvests:    make(map[int]memoryHarvest),
+		tasks:       make(map[int]memoryTask),
+		images:      make(map[int]memoryImage),
+		users:       make(map[int]models.User),
+		members:     make(map[int]map[int]memoryMember),
+		invitations: make(map[int]memoryInvitation),
+		orgs:        make(map[int]models.Organization),
+		orgMembers:  make(map[int]map[int]memoryMember),
+		families:    make(map[string]memoryTokenFamily),
+		tokens:      make(map[string]memoryRefreshToken),
+	}
+}
+
+func (s *MemoryStore) newID() int {
+	s.nextID++
+	return s.nextID
+}
+
+func (s *MemoryStore) DeleteOneMeadowForUser(meadowId int, userID int) (models.DeletionReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := models.DeletionReport{MeadowIds: []int{}, TreeIds: []int{}, ImageIds: []int{}, Files: []string{}}
+	now := trashTimestamp()
+
+	m, err := s.authorizeMeadow(meadowId, userID, models.RoleOwner)
+	if err != nil {
+		return report, err
+	}
+
+	for treeId, t := range s.trees {
+		if t.tree.MeadowId == meadowId && t.tree.DeletedAt == nil {
+			report.ImageIds = append(report.ImageIds, s.trashImagesOfTree(treeId, now)...)
+			report.TreeIds = append(report.TreeIds, treeId)
+			t.tree.DeletedAt = &now
+			s.trees[treeId] = t
+		}
+	}
+	m.meadow.DeletedAt = &now
+	s.meadows[meadowId] = m
+	report.MeadowIds = append(report.MeadowIds, meadowId)
+	return report, nil
+}
+
+func (s *MemoryStore) DeleteOneTreeForUser(treeId int, userID int) (models.DeletionReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := models.DeletionReport{MeadowIds: []int{}, TreeIds: []int{}, ImageIds: []int{}, Files: []string{}}
+	now := trashTimestamp()
+
+	t, err := s.authorizeTree(treeId, userID, models.RoleEditor)
+	if err != nil {
+		return report, err
+	}
+
+	report.ImageIds = s.trashImagesOfTree(treeId, now)
+	t.tree.DeletedAt = &now
+	s.trees[treeId] = t
+	report.TreeIds = append(report.TreeIds, treeId)
+	return report, nil
+}
+
+func (s *MemoryStore) DeleteTreeImage(imageID int, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	img, err := s.authorizeImage(imageID, userID, models.RoleEditor)
+	if err != nil {
+		return err
+	}
+	now := trashTimestamp()
+	img.image.DeletedAt = &now
+	s.images[imageID] = img
+	return nil
+}
+
+func (s *MemoryStore) FindAllMeadowsForUser(userID int) ([]models.Meadow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.filterMeadows(userID, func(meadow models.Meadow) bool { return true }), nil
+}
+
+func (s *MemoryStore) FindAllMeadowsOfOrganization(organizationId int, userID int) ([]models.Meadow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.authorizeOrganization(organizationId, userID, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.filterMeadows(userID, func(meadow models.Meadow) bool {
+		return meadow.Scope.Organization() == organizationId
+	}), nil
+}
+
+func (s *MemoryStore) FindAllTreesForMeadow(meadowId int, userID int, filter TreeFilter) ([]models.Tree, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.liveMeadow(meadowId, userID); !ok {
+		return nil, fmt.Errorf("meadow %d: %w", meadowId, ErrNotFound)
+	}
+
+	trees := []models.Tree{}
+	for _, t := range s.trees {
+		if t.tree.MeadowId != meadowId || t.tree.DeletedAt != nil {
+			continue
+		}
+		tree := s.withCondition(t.tree)
+		if filter.matches(tree.Condition) {
+			trees = append(trees, tree)
+		}
+	}
+	sort.Slice(trees, func(i, j int) bool { return trees[i].ID < trees[j].ID })
+	return trees, nil
+}
+
+func (s *MemoryStore) FindOneMeadowByIdForUser(meadowId int, userID int) (models.Meadow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.liveMeadow(meadowId, userID)
+	if !ok {
+		return models.Meadow{}, fmt.Errorf("meadow %d: %w", meadowId, ErrNotFound)
+	}
+	return s.readMeadow(m.meadow, userID), nil
+}
+
+func (s *MemoryStore) FindOneTreeById(treeId int, userID int) (models.Tree, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.liveTree(treeId, userID)
+	if !ok {
+		return models.Tree{}, fmt.Errorf("tree %d: %w", treeId, ErrNotFound)
+	}
+	return s.withCondition(t.tree), nil
+}
+
+func (s *MemoryStore) FindImageByPathForUser(path string, userID int) (models.Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, img := range s.images {
+		if !slices.Contains(imageFiles(img.image), path) {
+			continue
+		}
+		if _, ok := s.liveImage(id, userID); ok {
+			return img.image, nil
+		}
+	}
+	return models.Image{}, fmt.Errorf("image %s: %w", path, ErrNotFound)
+}
+
+func (s *MemoryStore) FindTreeImageByHashForUser(treeID int, hash string, userID int) (models.Image, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.authorizeTree(treeID, userID, models.RoleEditor); err != nil {
+		return models.Image{}, false, err
+	}
+	if img, ok := s.liveImageByHash(treeID, hash); ok {
+		return img, true, nil
+	}
+	return models.Image{}, false, nil
+}
+
+// liveImageByHash finds the live image of the tree that was uploaded with
+// the given SHA-256, which is unique like in MySQL.
+func (s *MemoryStore) liveImageByHash(treeID int, hash string) (models.Image, bool) {
+	if hash == "" {
+		return models.Image{}, false
+	}
+	for _, img := range s.images {
+		if img.image.TreeId == treeID && img.image.UploadSHA256 == hash && img.image.DeletedAt == nil {
+			return img.image, true
+		}
+	}
+	return models.Image{}, false
+}
+
+func (s *MemoryStore) FindAllImages() ([]models.Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	images := []models.Image{}
+	for _, img := range s.images {
+		images = append(images, img.image)
+	}
+	sort.Slice(images, func(a, b int) bool { return images[a].ID < images[b].ID })
+	return images, nil
+}
+
+func (s *MemoryStore) FindImagesWithoutTree() ([]models.Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	images := []models.Image{}
+	for _, img := range s.images {
+		if _, ok := s.trees[img.image.TreeId]; !ok {
+			images = append(images, img.image)
+		}
+	}
+	sort.Slice(images, func(a, b int) bool { return images[a].ID < images[b].ID })
+	return images, nil
+}
+
+func (s *MemoryStore) GetTreeImageDb(treeID int, userID int) ([]models.Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.authorizeTree(treeID, userID, models.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	images := []models.Image{}
+	for _, img := range s.images {
+		if img.image.TreeId == treeID && img.image.DeletedAt == nil {
+			image := img.image
+			image.Path = fmt.Sprintf("/%s", image.Path)
+			images = append(images, image)
+		}
+	}
+	return images, nil
+}
+
+func (s *MemoryStore) InsertOneMeadowForUser(meadow models.Meadow, userID int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, err := s.insertMeadow(meadow, userID)
+	if err != nil {
+		return 0, err
+	}
+	return int64(id), nil
+}
+
+func (s *MemoryStore) InsertOneTreeForUser(tree models.Tree, userID int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := s.authorizeMeadow(tree.MeadowId, userID, models.RoleEditor)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkInsideBoundary(m.meadow.Boundary, tree); err != nil {
+		return 0, err
+	}
+
+	tree.ID = s.newID()
+	tree.DeletedAt = nil
+	s.trees[tree.ID] = memoryTree{userID: userID, tree: tree}
+	return int64(tree.ID), nil
+}
+
+func (s *MemoryStore) UpdateMeadowForUser(meadow models.Meadow, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := s.authorizeMeadow(meadow.ID, userID, models.RoleEditor)
+	if err != nil {
+		return err
+	}
+	if meadow.Boundary != nil {
+		outside := []int{}
+		for _, t := range s.trees {
+			if t.tree.MeadowId == meadow.ID && t.tree.DeletedAt == nil && t.tree.Coordinates != nil &&
+				!meadow.Boundary.Contains(*t.tree.Coordinates) {
+				outside = append(outside, t.tree.ID)
+			}
+		}
+		if len(outside) > 0 {
+			sort.Ints(outside)
+			return fmt.Errorf("trees %v of meadow %d would lie outside the boundary: %w", outside, meadow.ID, ErrConflict)
+		}
+	}
+	m.meadow.Location = meadow.Location
+	m.meadow.Name = meadow.Name
+	m.meadow.Size = meadow.Size
+	m.meadow.Boundary = meadow.Boundary
+	m.meadow.Area = meadow.Boundary.Area()
+	m.meadow.Grid = meadow.Grid
+	s.meadows[meadow.ID] = m
+	return nil
+}
+
+func (s *MemoryStore) UpdateTreeForUser(tree models.Tree, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, err := s.authorizeTree(tree.ID, userID, models.RoleEditor)
+	if err != nil {
+		return err
+	}
+	tree.MeadowId = t.tree.MeadowId
+	if err := checkInsideBoundary(s.meadows[t.tree.MeadowId].meadow.Boundary, tree); err != nil {
+		return err
+	}
+	t.tree.PlantDate = tree.PlantDate
+	t.tree.Position = tree.Position
+	t.tree.Type = tree.Type
+	t.tree.VarietyId = tree.VarietyId
+	t.tree.Coordinates = tree.Coordinates
+	s.trees[tree.ID] = t
+	return nil
+}
+
+func (s *MemoryStore) UpdateTreeImageDescriptionDb(imageID int, description string, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	img, err := s.authorizeImage(imageID, userID, models.RoleEditor)
+	if err != nil {
+		return err
+	}
+	img.image.Description = description
+	s.images[imageID] = img
+	return nil
+}
+
+func (s *MemoryStore) UpdateTreeImageDatetimeDb(imageID int, datetime time.Time, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	img, err := s.authorizeImage(imageID, userID, models.RoleEditor)
+	if err != nil {
+		return err
+	}
+	img.image.Datetime = datetime
+	s.images[imageID] = img
+	return nil
+}
+
+func (s *MemoryStore) PurgeImage(imageID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.images[imageID]; !ok {
+		return fmt.Errorf("image %d: %w", imageID, ErrNotFound)
+	}
+	delete(s.images, imageID)
+	return nil
+}
+
+func (s *MemoryStore) UploadImageDb(image models.Image, userID int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.authorizeTree(image.TreeId, userID, models.RoleEditor); err != nil {
+		return 0, err
+	}
+	if _, ok := s.liveImageByHash(image.TreeId, image.UploadSHA256); ok {
+		return 0, fmt.Errorf("tree %d already has an image with the same content: %w", image.TreeId, ErrConflict)
+	}
+
+	image.ID = s.newID()
+	if image.Datetime.IsZero() {
+		image.Datetime = time.Now()
+	}
+	image.URL, image.URLs, image.DeletedAt = "", nil, nil
+	s.images[image.ID] = memoryImage{userID: userID, image: image}
+	return int64(image.ID), nil
+}
+
+// DeleteUser forgets the account and the personal meadows only the user
+// owns, and lists the paths of their images as files.
+func (s *MemoryStore) DeleteUser(userID int) (models.DeletionReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := models.DeletionReport{MeadowIds: []int{}, TreeIds: []int{}, ImageIds: []int{}, Files: []string{}}
+	if _, ok := s.users[userID]; !ok {
+		return report, fmt.Errorf("user %d: %w", userID, ErrNotFound)
+	}
+	for organizationId, members := range s.orgMembers {
+		if members[userID].role == models.RoleOwner && s.checkOtherOrganizationOwners(organizationId) != nil {
+			return report, fmt.Errorf("user %d is the last owner of organization %d: %w", userID, organizationId, ErrConflict)
+		}
+	}
+
+	for _, meadowId := range s.soleOwnedMeadows(userID) {
+		s.purgeMeadow(meadowId, &report)
+	}
+
+	for _, members := range s.members {
+		delete(members, userID)
+	}
+	for _, members := range s.orgMembers {
+		delete(members, userID)
+	}
+	for id, inv := range s.invitations {
+		if inv.invitedBy == userID {
+			delete(s.invitations, id)
+		}
+	}
+	for id, f := range s.families {
+		if f.userID == userID {
+			delete(s.families, id)
+		}
+	}
+	delete(s.users, userID)
+	return report, nil
+}
+
+// purgeMeadow deletes the meadow for good with all its trees and images,
+// including those in the trash, and adds them to the report.
+func (s *MemoryStore) purgeMeadow(meadowId int, report *models.DeletionReport) {
+	for imageId, img := range s.images {
+		if s.trees[img.image.TreeId].tree.MeadowId == meadowId {
+			delete(s.images, imageId)
+			report.ImageIds = append(report.ImageIds, imageId)
+			report.Files = append(report.Files, imageFiles(img.image)...)
+		}
+	}
+	for treeId, t := range s.trees {
+		if t.tree.MeadowId == meadowId {
+			s.deleteInspectionsOfTree(treeId)
+			s.deleteHarvestsOfTree(treeId)
+			s.deleteTasksOf(treeId, 0)
+			delete(s.trees, treeId)
+			report.TreeIds = append(report.TreeIds, treeId)
+		}
+	}
+	s.deleteTasksOf(0, meadowId)
+	s.deleteMembersOf(meadowId)
+	delete(s.meadows, meadowId)
+	report.MeadowIds = append(report.MeadowIds, meadowId)
+}
+
+func (s *MemoryStore) FindAccountMeadows(userID int) ([]models.AccountMeadow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accountMeadows := []models.AccountMeadow{}
+	for _, meadowId := range s.soleOwnedMeadows(userID) {
+		meadow := s.readMeadow(s.meadows[meadowId].meadow, userID)
+		accountMeadow := models.AccountMeadow{Meadow: meadow, Trees: []models.Tree{}, Images: []models.Image{}}
+		accountMeadow.Meadow.TreeIds = models.IntSlize{}
+		for _, t := range s.trees {
+			if t.tree.MeadowId == meadowId {
+				accountMeadow.Trees = append(accountMeadow.Trees, s.withCondition(t.tree))
+				accountMeadow.Meadow.TreeIds = append(accountMeadow.Meadow.TreeIds, t.tree.ID)
+			}
+		}
+		for _, img := range s.images {
+			if s.trees[img.image.TreeId].tree.MeadowId == meadowId {
+				accountMeadow.Images = append(accountMeadow.Images, img.image)
+			}
+		}
+		sort.Ints(accountMeadow.Meadow.TreeIds)
+		sort.Slice(accountMeadow.Trees, func(i, j int) bool { return accountMeadow.Trees[i].ID < accountMeadow.Trees[j].ID })
+		sort.Slice(accountMeadow.Images, func(i, j int) bool { return accountMeadow.Images[i].ID < accountMeadow.Images[j].ID })
+		accountMeadows = append(accountMeadows, accountMeadow)
+	}
+	return accountMeadows, nil
+}
+
+// soleOwnedMeadows returns the IDs of the personal meadows, trashed or not,
+// the user is the only owner of, in order.
+func (s *MemoryStore) soleOwnedMeadows(userID int) []int {
+	meadowIds := []int{}
+	for meadowId, members := range s.members {
+		// checkOtherOwners also passes for organization meadows
+		if members[userID].role == models.RoleOwner && s.checkOtherOwners(meadowId) != nil {
+			meadowIds = append(meadowIds, meadowId)
+		}
+	}
+	sort.Ints(meadowIds)
+	return meadowIds
+}
+
+func (s *MemoryStore) EmailExists(email string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Email == email {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *MemoryStore) FindUserByEmail(email string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return models.User{}, fmt.Errorf("user with email %s: %w", email, ErrNotFound)
+}
+
+func (s *MemoryStore) FindUserByID(userID int) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return models.User{}, fmt.Errorf("user %d: %w", userID, ErrNotFound)
+	}
+	return u, nil
+}
+
+func (s *MemoryStore) FindUserByUsername(username string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return models.User{}, fmt.Errorf("user %s: %w", username, ErrNotFound)
+}
+
+func (s *MemoryStore) InsertUser(user models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Username == user.Username || u.Email == user.Email {
+			return fmt.Errorf("user %s: %w", user.Username, ErrConflict)
+		}
+	}
+
+	user.ID = s.newID()
+	user.EmailVerifiedAt = nil
+	user.CreatedAt = time.Now()
+	user.ChangedAt = user.CreatedAt
+	s.users[user.ID] = user
+	return nil
+}
+
+func (s *MemoryStore) MarkEmailVerified(userID int, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok || u.Email != email || u.EmailVerifiedAt != nil {
+		return fmt.Errorf("unverified email %s of user %d: %w", email, userID, ErrNotFound)
+	}
+	now := time.Now()
+	u.EmailVerifiedAt = &now
+	s.users[userID] = u
+	return nil
+}
+
+func (s *MemoryStore) UpdatePassword(userID int, password string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return fmt.Errorf("user %d: %w", userID, ErrNotFound)
+	}
+	u.Password = password
+	u.ChangedAt = time.Now()
+	s.users[userID] = u
+	return nil
+}
+
+func (s *MemoryStore) UpdateUser(user models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.users[user.ID]
+	if !ok {
+		return fmt.Errorf("user %d: %w", user.ID, ErrNotFound)
+	}
+	for id, u := range s.users {
+		if id != user.ID && (u.Username == user.Username || u.Email == user.Email) {
+			return fmt.Errorf("username or email of user %d already taken: %w", user.ID, ErrConflict)
+		}
+	}
+
+	if existing.Email != user.Email {
+		existing.EmailVerifiedAt = nil
+	}
+	existing.Username = user.Username
+	existing.Email = user.Email
+	existing.ChangedAt = time.Now()
+	s.users[user.ID] = existing
+	return nil
+}
+
+func (s *MemoryStore) UsernameExists(username string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Username == username {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// The helpers below expect s.mu to be held by the caller.
+
+// role returns the user's role in the meadow, the better of the role as a
+// member of the meadow and the role as a member of its organization, and
+// whether the user is a member at all.
+func (s *MemoryStore) role(meadowId int, userID int) (models.Role, bool) {
+	member, direct := s.members[meadowId][userID]
+	organizationId := s.meadows[meadowId].meadow.Scope.Organization()
+	orgMember, viaOrg := s.orgMembers[organizationId][userID]
+	switch {
+	case direct && viaOrg && orgMember.role.AtLeast(member.role):
+		return orgMember.role, true
+	case direct:
+		return member.role, true
+	case viaOrg:
+		return orgMember.role, true
+	}
+	return "", false
+}
+
+// isMember reports whether the user is a member of the meadow, in any role.
+func (s *MemoryStore) isMember(meadowId int, userID int) bool {
+	_, ok := s.role(meadowId, userID)
+	return ok
+}
+
+// The live helpers find items that are not in the trash and that the user
+// may at least read.
+
+func (s *MemoryStore) liveMeadow(meadowId int, userID int) (memoryMeadow, bool) {
+	m, ok := s.meadows[meadowId]
+	return m, ok && s.isMember(meadowId, userID) && m.meadow.DeletedAt == nil
+}
+
+func (s *MemoryStore) liveTree(treeId int, userID int) (memoryTree, bool) {
+	t, ok := s.trees[treeId]
+	return t, ok && s.isMember(t.tree.MeadowId, userID) && t.tree.DeletedAt == nil
+}
+
+func (s *MemoryStore) liveImage(imageID int, userID int) (memoryImage, bool) {
+	img, ok := s.images[imageID]
+	if !ok || img.image.DeletedAt != nil {
+		return img, false
+	}
+	_, ok = s.liveTree(img.image.TreeId, userID)
+	return img, ok
+}
+
+// The authorize helpers find live items like the MySQLStore does and fail
+// with ErrForbidden if the user's role is below need.
+
+func (s *MemoryStore) authorizeMeadow(meadowId int, userID int, need models.Role) (memoryMeadow, error) {
+	m, ok := s.liveMeadow(meadowId, userID)
+	if !ok {
+		return m, fmt.Errorf("meadow %d: %w", meadowId, ErrNotFound)
+	}
+	return m, s.allow(meadowId, userID, need, fmt.Sprintf("meadow %d", meadowId))
+}
+
+func (s *MemoryStore) authorizeTree(treeId int, userID int, need models.Role) (memoryTree, error) {
+	t, ok := s.liveTree(treeId, userID)
+	if !ok {
+		return t, fmt.Errorf("tree %d: %w", treeId, ErrNotFound)
+	}
+	return t, s.allow(t.tree.MeadowId, userID, need, fmt.Sprintf("tree %d", treeId))
+}
+
+func (s *MemoryStore) authorizeImage(imageID int, userID int, need models.Role) (memoryImage, error) {
+	img, ok := s.liveImage(imageID, userID)
+	if !ok {
+		return img, fmt.Errorf("image %d: %w", imageID, ErrNotFound)
+	}
+	meadowId := s.trees[img.image.TreeId].tree.MeadowId
+	return img, s.allow(meadowId, userID, need, fmt.Sprintf("image %d", imageID))
+}
+
+// allow checks the role of a member of the meadow against need. what names
+// the item in errors.
+func (s *MemoryStore) allow(meadowId int, userID int, need models.Role, what string) error {
+	role, _ := s.role(meadowId, userID)
+	if !role.AtLeast(need) {
+		return fmt.Errorf("%s needs the %s role, the user is %s: %w", what, need, role, ErrForbidden)
+	}
+	return nil
+}
+
+// imageFiles lists the paths of the image's files
+func imageFiles(img models.Image) []string {
+	files := []string{img.Path}
+	for _, variant := range []string{img.ThumbnailPath, img.MediumPath} {
+		if variant != "" {
+			files = append(files, variant)
+		}
+	}
+	return files
+}
+
+// trashImagesOfTree moves the tree's live images to the trash and returns
+// their IDs.
+func (s *MemoryStore) trashImagesOfTree(treeId int, now time.Time) []int {
+	ids := []int{}
+	for id, img := range s.images {
+		if img.image.TreeId == treeId && img.image.DeletedAt == nil {
+			img.image.DeletedAt = &now
+			s.images[id] = img
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// insertMeadow inserts the meadow like the MySQLStore does and returns its
+// ID.
+func (s *MemoryStore) insertMeadow(meadow models.Meadow, userID int) (int, error) {
+	organizationId := meadow.Scope.Organization()
+	if organizationId != 0 {
+		if _, err := s.authorizeOrganization(organizationId, userID, models.RoleEditor); err != nil {
+			return 0, err
+		}
+	}
+
+	meadow.ID = s.newID()
+	meadow.TreeIds = nil
+	meadow.Area = meadow.Boundary.Area()
+	meadow.Role = ""
+	meadow.Scope = models.NewMeadowScope(organizationId, "")
+	meadow.DeletedAt = nil
+	s.meadows[meadow.ID] = memoryMeadow{userID: userID, meadow: meadow}
+	if organizationId == 0 {
+		s.addOwner(meadow.ID, userID)
+	}
+	return meadow.ID, nil
+}
+
+// readMeadow fills in what the MySQLStore derives when reading a meadow:
+// its TreeIds, the user's role and the name of its organization.
+func (s *MemoryStore) readMeadow(meadow models.Meadow, userID int) models.Meadow {
+	meadow = s.withTreeIds(meadow)
+	meadow.Role, _ = s.role(meadow.ID, userID)
+	organizationId := meadow.Scope.Organization()
+	meadow.Scope = models.NewMeadowScope(organizationId, s.orgs[organizationId].Name)
+	return meadow
+}
+
+// filterMeadows returns the user's live meadows the filter keeps, ordered by
+// ID.
+func (s *MemoryStore) filterMeadows(userID int, keep func(meadow models.Meadow) bool) []models.Meadow {
+	meadows := []models.Meadow{}
+	for meadowId := range s.meadows {
+		if m, ok := s.liveMeadow(meadowId, userID); ok && keep(m.meadow) {
+			meadows = append(meadows, s.readMeadow(m.meadow, userID))
+		}
+	}
+	sort.Slice(meadows, func(i, j int) bool { return meadows[i].ID < meadows[j].ID })
+	return meadows
+}
+
+// withTreeIds fills in the meadow's TreeIds from its live trees.
+func (s *MemoryStore) withTreeIds(meadow models.Meadow) models.Meadow {
+	meadow.TreeIds = models.IntSlize{}
+	for _, t := range s.trees {
+		if t.tree.MeadowId == meadow.ID && t.tree.DeletedAt == nil {
+			meadow.TreeIds = append(meadow.TreeIds, t.tree.ID)
+		}
+	}
+	sort.Ints(meadow.TreeIds)
+	return meadow
+}
+
+// withCondition sets the condition of the tree's latest inspection.
+func (s *MemoryStore) withCondition(tree models.Tree) models.Tree {
+	tree.Condition = nil
+	var latest *models.TreeInspection
+	for _, i := range s.inspections {
+		if i.inspection.TreeId != tree.ID {
+			continue
+		}
+		if latest == nil || i.inspection.Date.After(latest.Date) ||
+			(i.inspection.Date.Equal(latest.Date) && i.inspection.ID > latest.ID) {
+			inspection := i.inspection
+			latest = &inspection
+		}
+	}
+	if latest != nil {
+		tree.Condition = &models.TreeCondition{
+			InspectionId: latest.ID,
+			Date:         latest.Date,
+			Vitality:     latest.Vitality,
+			Diseases:     latest.Diseases,
+			Pests:        latest.Pests,
+		}
+	}
+	return tree
+}
+
+// matches applies the filter like the MySQLStore does.
+func (f TreeFilter) matches(condition *models.TreeCondition) bool {
+	if f.MinVitality == nil && f.MaxVitality == nil && f.Disease == "" && f.Pest == "" {
+		return true
+	}
+	if condition == nil {
+		return false
+	}
+	if f.MinVitality != nil && condition.Vitality < *f.MinVitality {
+		return false
+	}
+	if f.MaxVitality != nil && condition.Vitality > *f.MaxVitality {
+		return false
+	}
+	if f.Disease != "" && !slices.Contains(condition.Diseases, f.Disease) {
+		return false
+	}
+	return f.Pest == "" || slices.Contains(condition.Pests, f.Pest)
+}
@@ -0,0 +1,175 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+func (s *MemoryStore) DeleteHarvestForUser(harvestId int, treeId int, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.authorizeTree(treeId, userID, models.RoleEditor); err != nil {
+		return err
+	}
+	if _, ok := s.liveHarvest(harvestId, treeId, userID); !ok {
+		return fmt.Errorf("harvest %d: %w", harvestId, ErrNotFound)
+	}
+	delete(s.harvests, harvestId)
+	return nil
+}
+
+func (s *MemoryStore) FindHarvestsForTree(treeId int, userID int) ([]models.Harvest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.liveTree(treeId, userID); !ok {
+		return nil, fmt.Errorf("tree %d: %w", treeId, ErrNotFound)
+	}
+
+	harvests := []models.Harvest{}
+	for _, h := range s.harvests {
+		if h.harvest.TreeId == treeId {
+			harvests = append(harvests, h.harvest)
+		}
+	}
+	sort.Slice(harvests, func(a, b int) bool {
+		if !harvests[a].Date.Equal(harvests[b].Date) {
+			return harvests[a].Date.After(harvests[b].Date)
+		}
+		return harvests[a].ID > harvests[b].ID
+	})
+	return harvests, nil
+}
+
+func (s *MemoryStore) FindOneHarvestForUser(harvestId int, treeId int, userID int) (models.Harvest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.liveHarvest(harvestId, treeId, userID)
+	if !ok {
+		return models.Harvest{}, fmt.Errorf("harvest %d: %w", harvestId, ErrNotFound)
+	}
+	return h.harvest, nil
+}
+
+func (s *MemoryStore) FindYieldForUser(userID int, group YieldGroup, filter YieldFilter) ([]models.YieldStat, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if group != YieldByTree && group != YieldByVariety && group != YieldByMeadow {
+		return nil, fmt.Errorf("yield group %q: %w", group, ErrInvalidInput)
+	}
+
+	type yieldKey struct {
+		year     int
+		treeId   int
+		meadowId int
+		variety  string
+	}
+	sums := make(map[yieldKey]*models.YieldStat)
+
+	for _, h := range s.harvests {
+		t, ok := s.liveTree(h.harvest.TreeId, userID)
+		if !ok || s.meadows[t.tree.MeadowId].meadow.DeletedAt != nil {
+			continue
+		}
+		year := h.harvest.Date.Year()
+		if (filter.MeadowId != 0 && t.tree.MeadowId != filter.MeadowId) ||
+			(filter.FromYear != 0 && year < filter.FromYear) ||
+			(filter.ToYear != 0 && year > filter.ToYear) {
+			continue
+		}
+
+		key := yieldKey{year: year}
+		switch group {
+		case YieldByTree:
+			key.treeId, key.meadowId, key.variety = t.tree.ID, t.tree.MeadowId, t.tree.Type
+		case YieldByVariety:
+			key.variety = t.tree.Type
+		case YieldByMeadow:
+			key.meadowId = t.tree.MeadowId
+		}
+
+		stat, ok := sums[key]
+		if !ok {
+			stat = &models.YieldStat{Year: year, TreeId: key.treeId, MeadowId: key.meadowId}
+			if group != YieldByMeadow {
+				variety := key.variety
+				stat.Variety = &variety
+			}
+			sums[key] = stat
+		}
+		stat.Harvests++
+		stat.QuantityKg += h.harvest.QuantityKg()
+	}
+
+	stats := []models.YieldStat{}
+	for _, stat := range sums {
+		stats = append(stats, *stat)
+	}
+	sort.Slice(stats, func(a, b int) bool {
+		if stats[a].Year != stats[b].Year {
+			return stats[a].Year < stats[b].Year
+		}
+		switch group {
+		case YieldByTree:
+			return stats[a].TreeId < stats[b].TreeId
+		case YieldByVariety:
+			return *stats[a].Variety < *stats[b].Variety
+		default:
+			return stats[a].MeadowId < stats[b].MeadowId
+		}
+	})
+	return stats, nil
+}
+
+func (s *MemoryStore) InsertHarvestForUser(harvest models.Harvest, userID int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.authorizeTree(harvest.TreeId, userID, models.RoleEditor); err != nil {
+		return 0, err
+	}
+
+	harvest.ID = s.newID()
+	s.harvests[harvest.ID] = memoryHarvest{userID: userID, harvest: harvest}
+	return int64(harvest.ID), nil
+}
+
+func (s *MemoryStore) UpdateHarvestForUser(harvest models.Harvest, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.authorizeTree(harvest.TreeId, userID, models.RoleEditor); err != nil {
+		return err
+	}
+	h, ok := s.liveHarvest(harvest.ID, harvest.TreeId, userID)
+	if !ok {
+		return fmt.Errorf("harvest %d: %w", harvest.ID, ErrNotFound)
+	}
+	h.harvest = harvest
+	s.harvests[harvest.ID] = h
+	return nil
+}
+
+// The helpers below expect s.mu to be held by the caller.
+
+func (s *MemoryStore) liveHarvest(harvestId int, treeId int, userID int) (memoryHarvest, bool) {
+	h, ok := s.harvests[harvestId]
+	if !ok || h.harvest.TreeId != treeId {
+		return h, false
+	}
+	_, ok = s.liveTree(treeId, userID)
+	return h, ok
+}
+
+func (s *MemoryStore) deleteHarvestsOfTree(treeId int) {
+	for id, h := range s.harvests {
+		if h.harvest.TreeId == treeId {
+			delete(s.harvests, id)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+func (s *MemoryStore) ImportMeadowForUser(meadow models.Meadow, trees []models.Tree, userID int) (int64, []int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, tree := range trees {
+		if err := checkInsideBoundary(meadow.Boundary, tree); err != nil {
+			return 0, nil, fmt.Errorf("tree %d: %w", i, err)
+		}
+	}
+
+	meadowId, err := s.insertMeadow(meadow, userID)
+	if err != nil {
+		return 0, nil, err
+	}
+	return int64(meadowId), s.insertTrees(meadowId, trees, userID), nil
+}
+
+// insertTrees expects s.mu to be held by the caller.
+func (s *MemoryStore) insertTrees(meadowId int, trees []models.Tree, userID int) []int64 {
+	ids := []int64{}
+	for _, tree := range trees {
+		tree.ID = s.newID()
+		tree.MeadowId = meadowId
+		tree.Condition = nil
+		tree.DeletedAt = nil
+		s.trees[tree.ID] = memoryTree{userID: userID, tree: tree}
+		ids = append(ids, int64(tree.ID))
+	}
+	return ids
+}
+
+func (s *MemoryStore) InsertTreesForUser(meadowId int, trees []models.Tree, userID int) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := s.authorizeMeadow(meadowId, userID, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	for i, tree := range trees {
+		tree.MeadowId = meadowId
+		if err := checkInsideBoundary(m.meadow.Boundary, tree); err != nil {
+			return nil, fmt.Errorf("tree %d: %w", i, err)
+		}
+	}
+
+	return s.insertTrees(meadowId, trees, userID), nil
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+func (s *MemoryStore) DeleteInspectionForUser(inspectionId int, treeId int, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.authorizeTree(treeId, userID, models.RoleEditor); err != nil {
+		return err
+	}
+	if _, ok := s.liveInspection(inspectionId, treeId, userID); !ok {
+		return fmt.Errorf("inspection %d: %w", inspectionId, ErrNotFound)
+	}
+	delete(s.inspections, inspectionId)
+	return nil
+}
+
+func (s *MemoryStore) FindInspectionsForTree(treeId int, userID int) ([]models.TreeInspection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.liveTree(treeId, userID); !ok {
+		return nil, fmt.Errorf("tree %d: %w", treeId, ErrNotFound)
+	}
+
+	inspections := []models.TreeInspection{}
+	for _, i := range s.inspections {
+		if i.inspection.TreeId == treeId {
+			inspections = append(inspections, s.withLiveImages(i.inspection))
+		}
+	}
+	sort.Slice(inspections, func(a, b int) bool {
+		if !inspections[a].Date.Equal(inspections[b].Date) {
+			return inspections[a].Date.After(inspections[b].Date)
+		}
+		return inspections[a].ID > inspections[b].ID
+	})
+	return inspections, nil
+}
+
+func (s *MemoryStore) FindOneInspectionForUser(inspectionId int, treeId int, userID int) (models.TreeInspection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.liveInspection(inspectionId, treeId, userID)
+	if !ok {
+		return models.TreeInspection{}, fmt.Errorf("inspection %d: %w", inspectionId, ErrNotFound)
+	}
+	return s.withLiveImages(i.inspection), nil
+}
+
+func (s *MemoryStore) InsertInspectionForUser(inspection models.TreeInspection, userID int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.authorizeTree(inspection.TreeId, userID, models.RoleEditor); err != nil {
+		return 0, err
+	}
+	if err := s.checkInspectionImages(inspection, userID); err != nil {
+		return 0, err
+	}
+
+	inspection.ID = s.newID()
+	s.inspections[inspection.ID] = memoryInspection{userID: userID, inspection: inspection}
+	return int64(inspection.ID), nil
+}
+
+func (s *MemoryStore) UpdateInspectionForUser(inspection models.TreeInspection, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.authorizeTree(inspection.TreeId, userID, models.RoleEditor); err != nil {
+		return err
+	}
+	i, ok := s.liveInspection(inspection.ID, inspection.TreeId, userID)
+	if !ok {
+		return fmt.Errorf("inspection %d: %w", inspection.ID, ErrNotFound)
+	}
+	if err := s.checkInspectionImages(inspection, userID); err != nil {
+		return err
+	}
+
+	i.inspection = inspection
+	s.inspections[inspection.ID] = i
+	return nil
+}
+
+// The helpers below expect s.mu to be held by the caller.
+
+func (s *MemoryStore) liveInspection(inspectionId int, treeId int, userID int) (memoryInspection, bool) {
+	i, ok := s.inspections[inspectionId]
+	if !ok || i.inspection.TreeId != treeId {
+		return i, false
+	}
+	_, ok = s.liveTree(treeId, userID)
+	return i, ok
+}
+
+func (s *MemoryStore) checkInspectionImages(inspection models.TreeInspection, userID int) error {
+	for _, imageID := range inspection.ImageIds {
+		img, ok := s.liveImage(imageID, userID)
+		if !ok || img.image.TreeId != inspection.TreeId {
+			return fmt.Errorf("image %d is not an image of tree %d: %w", imageID, inspection.TreeId, ErrInvalidInput)
+		}
+	}
+	return nil
+}
+
+// withLiveImages leaves out linked images that are trashed or purged.
+func (s *MemoryStore) withLiveImages(inspection models.TreeInspection) models.TreeInspection {
+	imageIds := models.IntSlize{}
+	for _, id := range inspection.ImageIds {
+		if img, ok := s.images[id]; ok && img.image.DeletedAt == nil && !slices.Contains(imageIds, id) {
+			imageIds = append(imageIds, id)
+		}
+	}
+	sort.Ints(imageIds)
+	inspection.ImageIds = imageIds
+	return inspection
+}
+
+func (s *MemoryStore) deleteInspectionsOfTree(treeId int) {
+	for id, i := range s.inspections {
+		if i.inspection.TreeId == treeId {
+			delete(s.inspections, id)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+type memoryMember struct {
+	role  models.Role
+	since time.Time
+}
+
+type memoryInvitation struct {
+	invitedBy  int
+	invitation models.Invitation
+}
+
+func (s *MemoryStore) AcceptInvitationForUser(invitationId int, userID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inv, ok := s.invitationForUser(invitationId, userID)
+	if !ok {
+		return 0, fmt.Errorf("invitation %d: %w", invitationId, ErrNotFound)
+	}
+	meadowId := inv.invitation.MeadowId
+	if s.isMember(meadowId, userID) {
+		return 0, fmt.Errorf("user %d is already a member of meadow %d: %w", userID, meadowId, ErrConflict)
+	}
+
+	s.members[meadowId][userID] = memoryMember{role: inv.invitation.Role, since: time.Now()}
+	delete(s.invitations, invitationId)
+	return meadowId, nil
+}
+
+func (s *MemoryStore) DeclineInvitationForUser(invitationId int, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.invitationForUser(invitationId, userID); !ok {
+		return fmt.Errorf("invitation %d: %w", invitationId, ErrNotFound)
+	}
+	delete(s.invitations, invitationId)
+	return nil
+}
+
+func (s *MemoryStore) DeleteInvitationForUser(invitationId int, meadowId int, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.authorizeMeadow(meadowId, userID, models.RoleOwner); err != nil {
+		return err
+	}
+	inv, ok := s.invitations[invitationId]
+	if !ok || inv.invitation.MeadowId != meadowId {
+		return fmt.Errorf("invitation %d: %w", invitationId, ErrNotFound)
+	}
+	delete(s.invitations, invitationId)
+	return nil
+}
+
+func (s *MemoryStore) DeleteMemberForUser(meadowId int, memberId int, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	need := models.RoleOwner
+	if memberId == userID {
+		need = models.RoleViewer
+	}
+	if _, err := s.authorizeMeadow(meadowId, userID, need); err != nil {
+		return err
+	}
+	member, ok := s.members[meadowId][memberId]
+	if !ok {
+		return fmt.Errorf("member %d of meadow %d: %w", memberId, meadowId, ErrNotFound)
+	}
+	if member.role == models.RoleOwner {
+		if err := s.checkOtherOwners(meadowId); err != nil {
+			return err
+		}
+	}
+
+	delete(s.members[meadowId], memberId)
+	return nil
+}
+
+func (s *MemoryStore) FindInvitationsForMeadow(meadowId int, userID int) ([]models.Invitation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.authorizeMeadow(meadowId, userID, models.RoleOwner); err != nil {
+		return nil, err
+	}
+	return s.filterInvitations(func(inv memoryInvitation) bool {
+		return inv.invitation.MeadowId == meadowId
+	}), nil
+}
+
+func (s *MemoryStore) FindInvitationsForUser(userID int) ([]models.Invitation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.filterInvitations(func(inv memoryInvitation) bool {
+		_, ok := s.invitationForUser(inv.invitation.ID, userID)
+		return ok
+	}), nil
+}
+
+func (s *MemoryStore) FindMembersForMeadow(meadowId int, userID int) ([]models.MeadowMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.authorizeMeadow(meadowId, userID, models.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	members := []models.MeadowMember{}
+	for memberId, member := range s.members[meadowId] {
+		members = append(members, models.MeadowMember{
+			MeadowId: meadowId,
+			UserId:   memberId,
+			Username: s.users[memberId].Username,
+			Role:     member.role,
+			Since:    member.since,
+		})
+	}
+	sort.Slice(members, func(a, b int) bool {
+		if !members[a].Since.Equal(members[b].Since) {
+			return members[a].Since.Before(members[b].Since)
+		}
+		return members[a].UserId < members[b].UserId
+	})
+	return members, nil
+}
+
+func (s *MemoryStore) InsertInvitationForUser(invitation models.Invitation, userID int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.authorizeMeadow(invitation.MeadowId, userID, models.RoleOwner); err != nil {
+		return 0, err
+	}
+	for memberId := range s.members[invitation.MeadowId] {
+		if strings.EqualFold(s.users[memberId].Email, invitation.Email) {
+			return 0, fmt.Errorf("%s is already a member of meadow %d: %w", invitation.Email, invitation.MeadowId, ErrConflict)
+		}
+	}
+	for _, inv := range s.invitations {
+		if inv.invitation.MeadowId == invitation.MeadowId && strings.EqualFold(inv.invitation.Email, invitation.Email) {
+			return 0, fmt.Errorf("%s is already invited to meadow %d: %w", invitation.Email, invitation.MeadowId, ErrConflict)
+		}
+	}
+
+	invitation.ID = s.newID()
+	invitation.CreatedAt = time.Now()
+	s.invitations[invitation.ID] = memoryInvitation{invitedBy: userID, invitation: invitation}
+	return int64(invitation.ID), nil
+}
+
+func (s *MemoryStore) UpdateMemberForUser(meadowId int, memberId int, role models.Role, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := role.Validate(); err != nil {
+		return fmt.Errorf("%v: %w", err, ErrInvalidInput)
+	}
+	if _, err := s.authorizeMeadow(meadowId, userID, models.RoleOwner); err != nil {
+		return err
+	}
+	member, ok := s.members[meadowId][memberId]
+	if !ok {
+		return fmt.Errorf("member %d of meadow %d: %w", memberId, meadowId, ErrNotFound)
+	}
+	if member.role == models.RoleOwner && role != models.RoleOwner {
+		if err := s.checkOtherOwners(meadowId); err != nil {
+			return err
+		}
+	}
+
+	member.role = role
+	s.members[meadowId][memberId] = member
+	return nil
+}
+
+// The helpers below expect s.mu to be held by the caller.
+
+// addOwner makes the user the owner of a newly inserted meadow.
+func (s *MemoryStore) addOwner(meadowId int, userID int) {
+	s.members[meadowId] = map[int]memoryMember{
+		userID: {role: models.RoleOwner, since: time.Now()},
+	}
+}
+
+// hasRole reports whether the user holds at least need in the meadow.
+func (s *MemoryStore) hasRole(meadowId int, userID int, need models.Role) bool {
+	role, ok := s.role(meadowId, userID)
+	return ok && role.AtLeast(need)
+}
+
+// invitationForUser finds an invitation to a live meadow that is addressed
+// to the user's email address, which the user must have verified.
+func (s *MemoryStore) invitationForUser(invitationId int, userID int) (memoryInvitation, bool) {
+	inv, ok := s.invitations[invitationId]
+	user := s.users[userID]
+	if !ok || user.EmailVerifiedAt == nil || !strings.EqualFold(inv.invitation.Email, user.Email) {
+		return inv, false
+	}
+	m, ok := s.meadows[inv.invitation.MeadowId]
+	return inv, ok && m.meadow.DeletedAt == nil
+}
+
+// filterInvitations returns the invitations the filter keeps, with the
+// meadow's name and the inviting user's name filled in, ordered by ID.
+func (s *MemoryStore) filterInvitations(keep func(inv memoryInvitation) bool) []models.Invitation {
+	invitations := []models.Invitation{}
+	for _, inv := range s.invitations {
+		if !keep(inv) {
+			continue
+		}
+		invitation := inv.invitation
+		invitation.MeadowName = s.meadows[invitation.MeadowId].meadow.Name
+		invitation.InvitedBy = s.users[inv.invitedBy].Username
+		invitations = append(invitations, invitation)
+	}
+	sort.Slice(invitations, func(a, b int) bool { return invitations[a].ID < invitations[b].ID })
+	return invitations
+}
+
+// checkOtherOwners fails with ErrConflict unless the meadow has more than
+// one owner. Meadows of an organization need no owning member.
+func (s *MemoryStore) checkOtherOwners(meadowId int) error {
+	if s.meadows[meadowId].meadow.Scope.Organization() != 0 {
+		return nil
+	}
+	owners := 0
+	for _, member := range s.members[meadowId] {
+		if member.role == models.RoleOwner {
+			owners++
+		}
+	}
+	if owners <= 1 {
+		return fmt.Errorf("meadow %d needs at least one owner: %w", meadowId, ErrConflict)
+	}
+	return nil
+}
+
+func (s *MemoryStore) deleteMembersOf(meadowId int) {
+	delete(s.members, meadowId)
+	for id, inv := range s.invitations {
+		if inv.invitation.MeadowId == meadowId {
+			delete(s.invitations, id)
+		}
+	}
+}
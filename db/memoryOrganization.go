@@ -0,0 +1,225 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+func (s *MemoryStore) DeleteOrganizationForUser(organizationId int, userID int) (models.DeletionReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := models.DeletionReport{MeadowIds: []int{}, TreeIds: []int{}, ImageIds: []int{}, Files: []string{}}
+	if _, err := s.authorizeOrganization(organizationId, userID, models.RoleOwner); err != nil {
+		return report, err
+	}
+	meadows := 0
+	for _, m := range s.meadows {
+		if m.meadow.Scope.Organization() == organizationId && m.meadow.DeletedAt == nil {
+			meadows++
+		}
+	}
+	if meadows > 0 {
+		return report, fmt.Errorf("organization %d still owns %d meadows: %w", organizationId, meadows, ErrConflict)
+	}
+
+	for id, m := range s.meadows {
+		if m.meadow.Scope.Organization() == organizationId {
+			s.purgeMeadow(id, &report)
+		}
+	}
+	delete(s.orgMembers, organizationId)
+	delete(s.orgs, organizationId)
+	return report, nil
+}
+
+func (s *MemoryStore) DeleteOrganizationMemberForUser(organizationId int, memberId int, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	need := models.RoleOwner
+	if memberId == userID {
+		need = models.RoleViewer
+	}
+	if _, err := s.authorizeOrganization(organizationId, userID, need); err != nil {
+		return err
+	}
+	member, ok := s.orgMembers[organizationId][memberId]
+	if !ok {
+		return fmt.Errorf("member %d of organization %d: %w", memberId, organizationId, ErrNotFound)
+	}
+	if member.role == models.RoleOwner {
+		if err := s.checkOtherOrganizationOwners(organizationId); err != nil {
+			return err
+		}
+	}
+
+	delete(s.orgMembers[organizationId], memberId)
+	return nil
+}
+
+func (s *MemoryStore) FindAllOrganizationsForUser(userID int) ([]models.Organization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	organizations := []models.Organization{}
+	for id, organization := range s.orgs {
+		if member, ok := s.orgMembers[id][userID]; ok {
+			organization.Role = member.role
+			organizations = append(organizations, organization)
+		}
+	}
+	sort.Slice(organizations, func(a, b int) bool {
+		if organizations[a].Name != organizations[b].Name {
+			return organizations[a].Name < organizations[b].Name
+		}
+		return organizations[a].ID < organizations[b].ID
+	})
+	return organizations, nil
+}
+
+func (s *MemoryStore) FindOneOrganizationForUser(organizationId int, userID int) (models.Organization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	member, ok := s.orgMembers[organizationId][userID]
+	if !ok {
+		return models.Organization{}, fmt.Errorf("organization %d: %w", organizationId, ErrNotFound)
+	}
+	organization := s.orgs[organizationId]
+	organization.Role = member.role
+	return organization, nil
+}
+
+func (s *MemoryStore) FindOrganizationMembers(organizationId int, userID int) ([]models.OrganizationMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.authorizeOrganization(organizationId, userID, models.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	members := []models.OrganizationMember{}
+	for memberId, member := range s.orgMembers[organizationId] {
+		members = append(members, models.OrganizationMember{
+			OrganizationId: organizationId,
+			UserId:         memberId,
+			Username:       s.users[memberId].Username,
+			Role:           member.role,
+			Since:          member.since,
+		})
+	}
+	sort.Slice(members, func(a, b int) bool {
+		if !members[a].Since.Equal(members[b].Since) {
+			return members[a].Since.Before(members[b].Since)
+		}
+		return members[a].UserId < members[b].UserId
+	})
+	return members, nil
+}
+
+func (s *MemoryStore) InsertOrganizationForUser(organization models.Organization, userID int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	organization.ID = s.newID()
+	organization.Role = ""
+	organization.CreatedAt = time.Now()
+	s.orgs[organization.ID] = organization
+	s.orgMembers[organization.ID] = map[int]memoryMember{
+		userID: {role: models.RoleOwner, since: organization.CreatedAt},
+	}
+	return int64(organization.ID), nil
+}
+
+func (s *MemoryStore) InsertOrganizationMemberForUser(organizationId int, username string, role models.Role, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := role.Validate(); err != nil {
+		return fmt.Errorf("%v: %w", err, ErrInvalidInput)
+	}
+	if _, err := s.authorizeOrganization(organizationId, userID, models.RoleOwner); err != nil {
+		return err
+	}
+
+	for memberId, u := range s.users {
+		if u.Username != username {
+			continue
+		}
+		if _, ok := s.orgMembers[organizationId][memberId]; ok {
+			return fmt.Errorf("user %s is already a member of organization %d: %w", username, organizationId, ErrConflict)
+		}
+		s.orgMembers[organizationId][memberId] = memoryMember{role: role, since: time.Now()}
+		return nil
+	}
+	return fmt.Errorf("user %s: %w", username, ErrNotFound)
+}
+
+func (s *MemoryStore) UpdateOrganizationForUser(organization models.Organization, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, err := s.authorizeOrganization(organization.ID, userID, models.RoleOwner)
+	if err != nil {
+		return err
+	}
+	existing.Name = organization.Name
+	s.orgs[organization.ID] = existing
+	return nil
+}
+
+func (s *MemoryStore) UpdateOrganizationMemberForUser(organizationId int, memberId int, role models.Role, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := role.Validate(); err != nil {
+		return fmt.Errorf("%v: %w", err, ErrInvalidInput)
+	}
+	if _, err := s.authorizeOrganization(organizationId, userID, models.RoleOwner); err != nil {
+		return err
+	}
+	member, ok := s.orgMembers[organizationId][memberId]
+	if !ok {
+		return fmt.Errorf("member %d of organization %d: %w", memberId, organizationId, ErrNotFound)
+	}
+	if member.role == models.RoleOwner && role != models.RoleOwner {
+		if err := s.checkOtherOrganizationOwners(organizationId); err != nil {
+			return err
+		}
+	}
+
+	member.role = role
+	s.orgMembers[organizationId][memberId] = member
+	return nil
+}
+
+// The helpers below expect s.mu to be held by the caller.
+
+func (s *MemoryStore) authorizeOrganization(organizationId int, userID int, need models.Role) (models.Organization, error) {
+	member, ok := s.orgMembers[organizationId][userID]
+	if !ok {
+		return models.Organization{}, fmt.Errorf("organization %d: %w", organizationId, ErrNotFound)
+	}
+	if !member.role.AtLeast(need) {
+		return models.Organization{}, fmt.Errorf("organization %d needs the %s role, the user is %s: %w",
+			organizationId, need, member.role, ErrForbidden)
+	}
+	return s.orgs[organizationId], nil
+}
+
+func (s *MemoryStore) checkOtherOrganizationOwners(organizationId int) error {
+	owners := 0
+	for _, member := range s.orgMembers[organizationId] {
+		if member.role == models.RoleOwner {
+			owners++
+		}
+	}
+	if owners <= 1 {
+		return fmt.Errorf("organization %d needs at least one owner: %w", organizationId, ErrConflict)
+	}
+	return nil
+}
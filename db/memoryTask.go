@@ -0,0 +1,194 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+func (s *MemoryStore) CompleteTaskForUser(taskId int, userID int, completedAt time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, err := s.authorizeTask(taskId, userID, models.RoleEditor)
+	if err != nil {
+		return 0, err
+	}
+	if k.task.CompletedAt != nil {
+		return 0, fmt.Errorf("task %d is already completed: %w", taskId, ErrConflict)
+	}
+
+	completed := completedAt.UTC()
+	k.task.CompletedAt = &completed
+	s.tasks[taskId] = k
+
+	if k.task.Recurrence == nil {
+		return 0, nil
+	}
+	next := k.task
+	next.ID = s.newID()
+	next.DueDate = k.task.Recurrence.Next(k.task.DueDate, completedAt)
+	next.Recurrence = k.task.Recurrence.Anchored(k.task.DueDate)
+	next.CompletedAt = nil
+	s.tasks[next.ID] = memoryTask{userID: userID, task: next}
+	return int64(next.ID), nil
+}
+
+func (s *MemoryStore) DeleteTaskForUser(taskId int, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.authorizeTask(taskId, userID, models.RoleEditor); err != nil {
+		return err
+	}
+	delete(s.tasks, taskId)
+	return nil
+}
+
+func (s *MemoryStore) FindDueTasksForUser(userID int, before time.Time) ([]models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.filterTasks(userID, func(task models.Task, meadowId int) bool {
+		return task.CompletedAt == nil && task.DueDate.Before(before)
+	}), nil
+}
+
+func (s *MemoryStore) FindOneTaskForUser(taskId int, userID int) (models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.liveTask(taskId, userID)
+	if !ok {
+		return models.Task{}, fmt.Errorf("task %d: %w", taskId, ErrNotFound)
+	}
+	return k.task, nil
+}
+
+func (s *MemoryStore) FindTasksForMeadow(meadowId int, userID int) ([]models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.liveMeadow(meadowId, userID); !ok {
+		return nil, fmt.Errorf("meadow %d: %w", meadowId, ErrNotFound)
+	}
+
+	return s.filterTasks(userID, func(task models.Task, taskMeadowId int) bool {
+		return taskMeadowId == meadowId
+	}), nil
+}
+
+func (s *MemoryStore) FindTasksForTree(treeId int, userID int) ([]models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.liveTree(treeId, userID); !ok {
+		return nil, fmt.Errorf("tree %d: %w", treeId, ErrNotFound)
+	}
+
+	return s.filterTasks(userID, func(task models.Task, meadowId int) bool {
+		return task.TreeId != nil && *task.TreeId == treeId
+	}), nil
+}
+
+func (s *MemoryStore) InsertTaskForUser(task models.Task, userID int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if task.TreeId != nil {
+		if _, err := s.authorizeTree(*task.TreeId, userID, models.RoleEditor); err != nil {
+			return 0, err
+		}
+	} else if _, err := s.authorizeMeadow(*task.MeadowId, userID, models.RoleEditor); err != nil {
+		return 0, err
+	}
+
+	task.ID = s.newID()
+	task.CompletedAt = nil
+	s.tasks[task.ID] = memoryTask{userID: userID, task: task}
+	return int64(task.ID), nil
+}
+
+func (s *MemoryStore) UpdateTaskForUser(task models.Task, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, err := s.authorizeTask(task.ID, userID, models.RoleEditor)
+	if err != nil {
+		return err
+	}
+	k.task.Type = task.Type
+	k.task.Title = task.Title
+	k.task.Notes = task.Notes
+	k.task.DueDate = task.DueDate
+	k.task.Recurrence = task.Recurrence
+	k.task.Assignee = task.Assignee
+	s.tasks[task.ID] = k
+	return nil
+}
+
+// The helpers below expect s.mu to be held by the caller.
+
+// taskMeadow returns the meadow a task belongs to, directly or through its
+// tree, and whether that meadow and tree are live.
+func (s *MemoryStore) taskMeadow(task models.Task) (int, bool) {
+	meadowId := 0
+	if task.TreeId != nil {
+		t, ok := s.trees[*task.TreeId]
+		if !ok || t.tree.DeletedAt != nil {
+			return 0, false
+		}
+		meadowId = t.tree.MeadowId
+	} else {
+		meadowId = *task.MeadowId
+	}
+	m, ok := s.meadows[meadowId]
+	return meadowId, ok && m.meadow.DeletedAt == nil
+}
+
+func (s *MemoryStore) liveTask(taskId int, userID int) (memoryTask, bool) {
+	k, ok := s.tasks[taskId]
+	if !ok {
+		return k, false
+	}
+	meadowId, ok := s.taskMeadow(k.task)
+	return k, ok && s.isMember(meadowId, userID)
+}
+
+func (s *MemoryStore) authorizeTask(taskId int, userID int, need models.Role) (memoryTask, error) {
+	k, ok := s.liveTask(taskId, userID)
+	if !ok {
+		return k, fmt.Errorf("task %d: %w", taskId, ErrNotFound)
+	}
+	meadowId, _ := s.taskMeadow(k.task)
+	return k, s.allow(meadowId, userID, need, fmt.Sprintf("task %d", taskId))
+}
+
+// filterTasks returns the live tasks in the user's meadows the filter keeps,
+// ordered by due date.
+func (s *MemoryStore) filterTasks(userID int, keep func(task models.Task, meadowId int) bool) []models.Task {
+	tasks := []models.Task{}
+	for _, k := range s.tasks {
+		meadowId, ok := s.taskMeadow(k.task)
+		if ok && s.isMember(meadowId, userID) && keep(k.task, meadowId) {
+			tasks = append(tasks, k.task)
+		}
+	}
+	sort.Slice(tasks, func(a, b int) bool {
+		if !tasks[a].DueDate.Equal(tasks[b].DueDate) {
+			return tasks[a].DueDate.Before(tasks[b].DueDate)
+		}
+		return tasks[a].ID < tasks[b].ID
+	})
+	return tasks
+}
+
+func (s *MemoryStore) deleteTasksOf(treeId int, meadowId int) {
+	for id, k := range s.tasks {
+		if (k.task.TreeId != nil && *k.task.TreeId == treeId) || (k.task.MeadowId != nil && *k.task.MeadowId == meadowId) {
+			delete(s.tasks, id)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+type memoryTokenFamily struct {
+	userID    int
+	expiresAt time.Time
+	revoked   bool
+}
+
+type memoryRefreshToken struct {
+	familyId  string
+	expiresAt time.Time
+	used      bool
+}
+
+func (s *MemoryStore) InsertRefreshToken(token models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.families[token.FamilyId] = memoryTokenFamily{userID: token.UserId, expiresAt: token.ExpiresAt}
+	s.tokens[token.Hash] = memoryRefreshToken{familyId: token.FamilyId, expiresAt: token.ExpiresAt}
+	return nil
+}
+
+func (s *MemoryStore) IsTokenFamilyActive(familyId string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.families[familyId]
+	return ok && !f.revoked && f.expiresAt.After(time.Now()), nil
+}
+
+func (s *MemoryStore) PurgeExpiredTokens(before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := int64(0)
+	for id, f := range s.families {
+		if f.expiresAt.Before(before) {
+			delete(s.families, id)
+			purged++
+		}
+	}
+	for hash, t := range s.tokens {
+		if _, ok := s.families[t.familyId]; !ok {
+			delete(s.tokens, hash)
+		}
+	}
+	return purged, nil
+}
+
+func (s *MemoryStore) RevokeAllTokensForUser(userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, f := range s.families {
+		if f.userID == userID {
+			f.revoked = true
+			s.families[id] = f
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) RevokeTokenFamilyForUser(familyId string, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.families[familyId]
+	if !ok || f.userID != userID || f.revoked {
+		return fmt.Errorf("token family of user %d: %w", userID, ErrNotFound)
+	}
+	f.revoked = true
+	s.families[familyId] = f
+	return nil
+}
+
+func (s *MemoryStore) RotateRefreshToken(hash string, next models.RefreshToken) (models.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[hash]
+	if !ok {
+		return next, fmt.Errorf("refresh token: %w", ErrNotFound)
+	}
+	f := s.families[t.familyId]
+	next.FamilyId = t.familyId
+	next.UserId = f.userID
+
+	switch {
+	case f.revoked:
+		return next, fmt.Errorf("refresh token was revoked: %w", ErrForbidden)
+	case t.used:
+		f.revoked = true
+		s.families[t.familyId] = f
+		return next, fmt.Errorf("refresh token was already used, its session is revoked: %w", ErrForbidden)
+	case !t.expiresAt.After(time.Now()):
+		return next, fmt.Errorf("refresh token expired: %w", ErrForbidden)
+	}
+
+	t.used = true
+	s.tokens[hash] = t
+	s.tokens[next.Hash] = memoryRefreshToken{familyId: next.FamilyId, expiresAt: next.ExpiresAt}
+	f.expiresAt = next.ExpiresAt
+	s.families[next.FamilyId] = f
+	return next, nil
+}
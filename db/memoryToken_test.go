@@ -0,0 +1,81 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+	next := func(hash string) models.RefreshToken {
+		return models.RefreshToken{Hash: hash, ExpiresAt: later}
+	}
+
+	s := NewMemoryStore()
+	if err := s.InsertRefreshToken(models.RefreshToken{Hash: "first", FamilyId: "family", UserId: 7, ExpiresAt: later}); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := s.RotateRefreshToken("first", next("second"))
+	if err != nil {
+		t.Fatalf("RotateRefreshToken() = %v", err)
+	}
+	if second.FamilyId != "family" || second.UserId != 7 {
+		t.Errorf("rotated token = %+v, want family and user carried over", second)
+	}
+	if _, err := s.RotateRefreshToken("second", next("third")); err != nil {
+		t.Fatalf("RotateRefreshToken() of the rotated token = %v", err)
+	}
+
+	// Presenting a used token again means it was stolen, so the whole
+	// session ends, including the token issued last
+	if _, err := s.RotateRefreshToken("first", next("stolen")); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("reusing a token = %v, want ErrForbidden", err)
+	}
+	if active, _ := s.IsTokenFamilyActive("family"); active {
+		t.Error("the session is still active after a token was reused")
+	}
+	if _, err := s.RotateRefreshToken("third", next("fourth")); !errors.Is(err, ErrForbidden) {
+		t.Errorf("rotating the last token of a revoked session = %v, want ErrForbidden", err)
+	}
+	if _, err := s.RotateRefreshToken("stolen", next("fifth")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("the token issued on reuse was stored: %v", err)
+	}
+}
+
+func TestRotateRefreshTokenRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   models.RefreshToken
+		prepare func(s *MemoryStore)
+		hash    string
+		want    error
+	}{
+		{"unknown", models.RefreshToken{Hash: "a", FamilyId: "f", UserId: 1, ExpiresAt: time.Now().Add(time.Hour)}, nil, "b", ErrNotFound},
+		{"expired", models.RefreshToken{Hash: "a", FamilyId: "f", UserId: 1, ExpiresAt: time.Now().Add(-time.Second)}, nil, "a", ErrForbidden},
+		{"signed out", models.RefreshToken{Hash: "a", FamilyId: "f", UserId: 1, ExpiresAt: time.Now().Add(time.Hour)},
+			func(s *MemoryStore) { s.RevokeTokenFamilyForUser("f", 1) }, "a", ErrForbidden},
+		{"signed out everywhere", models.RefreshToken{Hash: "a", FamilyId: "f", UserId: 1, ExpiresAt: time.Now().Add(time.Hour)},
+			func(s *MemoryStore) { s.RevokeAllTokensForUser(1) }, "a", ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryStore()
+			if err := s.InsertRefreshToken(tt.token); err != nil {
+				t.Fatal(err)
+			}
+			if tt.prepare != nil {
+				tt.prepare(s)
+			}
+
+			_, err := s.RotateRefreshToken(tt.hash, models.RefreshToken{Hash: "next", ExpiresAt: time.Now().Add(time.Hour)})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("RotateRefreshToken() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+func (s *MemoryStore) FindTrashForUser(userID int) (models.Trash, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trash := models.Trash{Meadows: []models.Meadow{}, Trees: []models.Tree{}, Images: []models.Image{}}
+
+	for _, m := range s.meadows {
+		if m.meadow.DeletedAt == nil || !s.hasRole(m.meadow.ID, userID, models.RoleOwner) {
+			continue
+		}
+		meadow := m.meadow
+		meadow.TreeIds = models.IntSlize{}
+		for _, t := range s.trees {
+			if t.tree.MeadowId == meadow.ID && sameTime(t.tree.DeletedAt, meadow.DeletedAt) {
+				meadow.TreeIds = append(meadow.TreeIds, t.tree.ID)
+			}
+		}
+		sort.Ints(meadow.TreeIds)
+		trash.Meadows = append(trash.Meadows, meadow)
+	}
+
+	for _, t := range s.trees {
+		if t.tree.DeletedAt != nil && s.meadows[t.tree.MeadowId].meadow.DeletedAt == nil &&
+			s.hasRole(t.tree.MeadowId, userID, models.RoleEditor) {
+			trash.Trees = append(trash.Trees, s.withCondition(t.tree))
+		}
+	}
+
+	for _, img := range s.images {
+		t := s.trees[img.image.TreeId]
+		if img.image.DeletedAt != nil && t.tree.DeletedAt == nil && s.hasRole(t.tree.MeadowId, userID, models.RoleEditor) {
+			trash.Images = append(trash.Images, img.image)
+		}
+	}
+
+	return trash, nil
+}
+
+// PurgeTrash forgets everything trashed before the given time and lists the
+// paths of the forgotten images as files.
+func (s *MemoryStore) PurgeTrash(before time.Time) (models.DeletionReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := models.DeletionReport{MeadowIds: []int{}, TreeIds: []int{}, ImageIds: []int{}, Files: []string{}}
+	expired := func(deletedAt *time.Time) bool {
+		return deletedAt != nil && deletedAt.Before(before)
+	}
+
+	for id, img := range s.images {
+		t := s.trees[img.image.TreeId]
+		if expired(img.image.DeletedAt) || expired(t.tree.DeletedAt) || expired(s.meadows[t.tree.MeadowId].meadow.DeletedAt) {
+			delete(s.images, id)
+			report.ImageIds = append(report.ImageIds, id)
+			report.Files = append(report.Files, imageFiles(img.image)...)
+		}
+	}
+	for id, t := range s.trees {
+		if expired(t.tree.DeletedAt) || expired(s.meadows[t.tree.MeadowId].meadow.DeletedAt) {
+			s.deleteInspectionsOfTree(id)
+			s.deleteHarvestsOfTree(id)
+			s.deleteTasksOf(id, 0)
+			delete(s.trees, id)
+			report.TreeIds = append(report.TreeIds, id)
+		}
+	}
+	for id, m := range s.meadows {
+		if expired(m.meadow.DeletedAt) {
+			s.deleteTasksOf(0, id)
+			s.deleteMembersOf(id)
+			delete(s.meadows, id)
+			report.MeadowIds = append(report.MeadowIds, id)
+		}
+	}
+
+	return report, nil
+}
+
+func (s *MemoryStore) RestoreImageForUser(imageID int, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	img, ok := s.images[imageID]
+	meadowId := s.trees[img.image.TreeId].tree.MeadowId
+	if !ok || img.image.DeletedAt == nil || !s.isMember(meadowId, userID) {
+		return fmt.Errorf("trashed image %d: %w", imageID, ErrNotFound)
+	}
+	if err := s.allow(meadowId, userID, models.RoleEditor, fmt.Sprintf("trashed image %d", imageID)); err != nil {
+		return err
+	}
+	if s.trees[img.image.TreeId].tree.DeletedAt != nil {
+		return fmt.Errorf("tree %d of image %d is in the trash: %w", img.image.TreeId, imageID, ErrConflict)
+	}
+	if _, ok := s.liveImageByHash(img.image.TreeId, img.image.UploadSHA256); ok {
+		return fmt.Errorf("tree %d already has an image with the same content as image %d: %w", img.image.TreeId, imageID, ErrConflict)
+	}
+
+	img.image.DeletedAt = nil
+	s.images[imageID] = img
+	return nil
+}
+
+func (s *MemoryStore) RestoreMeadowForUser(meadowId int, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.meadows[meadowId]
+	if !ok || m.meadow.DeletedAt == nil || !s.isMember(meadowId, userID) {
+		return fmt.Errorf("trashed meadow %d: %w", meadowId, ErrNotFound)
+	}
+	if err := s.allow(meadowId, userID, models.RoleOwner, fmt.Sprintf("trashed meadow %d", meadowId)); err != nil {
+		return err
+	}
+
+	deletedAt := m.meadow.DeletedAt
+	for treeId, t := range s.trees {
+		if t.tree.MeadowId == meadowId && sameTime(t.tree.DeletedAt, deletedAt) {
+			s.restoreImagesOfTree(treeId, deletedAt)
+			t.tree.DeletedAt = nil
+			s.trees[treeId] = t
+		}
+	}
+	m.meadow.DeletedAt = nil
+	s.meadows[meadowId] = m
+	return nil
+}
+
+func (s *MemoryStore) RestoreTreeForUser(treeId int, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.trees[treeId]
+	if !ok || t.tree.DeletedAt == nil || !s.isMember(t.tree.MeadowId, userID) {
+		return fmt.Errorf("trashed tree %d: %w", treeId, ErrNotFound)
+	}
+	if err := s.allow(t.tree.MeadowId, userID, models.RoleEditor, fmt.Sprintf("trashed tree %d", treeId)); err != nil {
+		return err
+	}
+	if s.meadows[t.tree.MeadowId].meadow.DeletedAt != nil {
+		return fmt.Errorf("meadow %d of tree %d is in the trash: %w", t.tree.MeadowId, treeId, ErrConflict)
+	}
+
+	s.restoreImagesOfTree(treeId, t.tree.DeletedAt)
+	t.tree.DeletedAt = nil
+	s.trees[treeId] = t
+	return nil
+}
+
+// restoreImagesOfTree expects s.mu to be held by the caller.
+func (s *MemoryStore) restoreImagesOfTree(treeId int, deletedAt *time.Time) {
+	for id, img := range s.images {
+		if img.image.TreeId == treeId && sameTime(img.image.DeletedAt, deletedAt) {
+			img.image.DeletedAt = nil
+			s.images[id] = img
+		}
+	}
+}
+
+func sameTime(a *time.Time, b *time.Time) bool {
+	return a != nil && b != nil && a.Equal(*b)
+}
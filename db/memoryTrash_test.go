@@ -0,0 +1,62 @@
+package db
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+func TestPurgeTrash(t *testing.T) {
+	s := NewMemoryStore()
+	if err := s.InsertUser(models.User{Username: "alice", Email: "alice@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	user, _ := s.FindUserByUsername("alice")
+
+	meadowId, err := s.InsertOneMeadowForUser(models.Meadow{Name: "Orchard"}, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	insertTree := func() int {
+		id, err := s.InsertOneTreeForUser(models.Tree{MeadowId: int(meadowId), Type: "Apple"}, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return int(id)
+	}
+	trashed, kept := insertTree(), insertTree()
+	imageID, err := s.UploadImageDb(models.Image{TreeId: trashed, Path: "uploads/1.jpg", ThumbnailPath: "uploads/1_thumb.jpg"}, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DeleteOneTreeForUser(trashed, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing has been in the trash for long enough yet
+	report, err := s.PurgeTrash(time.Now().Add(-time.Hour))
+	if err != nil || len(report.TreeIds)+len(report.ImageIds) != 0 {
+		t.Fatalf("PurgeTrash() = %+v, %v, want nothing purged", report, err)
+	}
+
+	report, err = s.PurgeTrash(time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(report.TreeIds, []int{trashed}) || !slices.Equal(report.ImageIds, []int{int(imageID)}) || len(report.MeadowIds) != 0 {
+		t.Errorf("PurgeTrash() = %+v, want tree %d and image %d", report, trashed, imageID)
+	}
+	slices.Sort(report.Files)
+	if !slices.Equal(report.Files, []string{"uploads/1.jpg", "uploads/1_thumb.jpg"}) {
+		t.Errorf("purged files = %v, want the image with its thumbnail", report.Files)
+	}
+
+	if err := s.RestoreTreeForUser(trashed, user.ID); err == nil {
+		t.Error("a purged tree was restored")
+	}
+	if _, err := s.FindOneTreeById(kept, user.ID); err != nil {
+		t.Errorf("the tree outside the trash is gone: %v", err)
+	}
+}
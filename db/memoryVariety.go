@@ -0,0 +1,46 @@
+package db
+
+import (
+	"sort"
+)
+
+func (s *MemoryStore) CountUnlinkedTreeTypes() ([]TypeCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trees := map[string]int{}
+	for _, t := range s.trees {
+		if t.tree.VarietyId == nil {
+			trees[t.tree.Type]++
+		}
+	}
+
+	counts := []TypeCount{}
+	for treeType, n := range trees {
+		counts = append(counts, TypeCount{Type: treeType, Trees: n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Trees != counts[j].Trees {
+			return counts[i].Trees > counts[j].Trees
+		}
+		return counts[i].Type < counts[j].Type
+	})
+	return counts, nil
+}
+
+func (s *MemoryStore) LinkTreeType(treeType string, varietyId int, name string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var linked int64
+	for treeId, t := range s.trees {
+		if t.tree.VarietyId == nil && t.tree.Type == treeType {
+			id := varietyId
+			t.tree.VarietyId = &id
+			t.tree.Type = name
+			s.trees[treeId] = t
+			linked++
+		}
+	}
+	return linked, nil
+}
@@ -0,0 +1,131 @@
+package db
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/mysql"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// newMigrator opens its own connection to the database, so closing the
+// migrator never affects the connection used by the stores.
+func newMigrator() (*migrate.Migrate, error) {
+	source, err := iofs.New(migrationsFS, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	m, err := migrate.NewWithSourceInstance("iofs", source, "mysql://"+dsn()+"&multiStatements=true")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migrator: %w", err)
+	}
+	return m, nil
+}
+
+func closeMigrator(m *migrate.Migrate) {
+	if srcErr, dbErr := m.Close(); srcErr != nil || dbErr != nil {
+		log.Printf("Error closing migrator: %v %v\n", srcErr, dbErr)
+	}
+}
+
+// MigrateUp applies all pending migrations.
+func MigrateUp() error {
+	m, err := newMigrator()
+	if err != nil {
+		return err
+	}
+	defer closeMigrator(m)
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to migrate up: %w", err)
+	}
+	return nil
+}
+
+// MigrateDown rolls back the given number of migrations, which must be
+// positive.
+func MigrateDown(steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("failed to migrate down: %d is not a positive number of steps", steps)
+	}
+
+	m, err := newMigrator()
+	if err != nil {
+		return err
+	}
+	defer closeMigrator(m)
+
+	if err := m.Steps(-steps); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to migrate down: %w", err)
+	}
+	return nil
+}
+
+// MigrateDownAll rolls back every migration, leaving an empty schema.
+func MigrateDownAll() error {
+	m, err := newMigrator()
+	if err != nil {
+		return err
+	}
+	defer closeMigrator(m)
+
+	if err := m.Down(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to migrate down: %w", err)
+	}
+	return nil
+}
+
+// MigrateTo migrates up or down to the given version.
+func MigrateTo(version uint) error {
+	m, err := newMigrator()
+	if err != nil {
+		return err
+	}
+	defer closeMigrator(m)
+
+	if err := m.Migrate(version); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to migrate to version %d: %w", version, err)
+	}
+	return nil
+}
+
+// MigrateForce sets the recorded version without running any migration,
+// which is needed to recover from a failed migration marked dirty.
+func MigrateForce(version int) error {
+	m, err := newMigrator()
+	if err != nil {
+		return err
+	}
+	defer closeMigrator(m)
+
+	if err := m.Force(version); err != nil {
+		return fmt.Errorf("failed to force version %d: %w", version, err)
+	}
+	return nil
+}
+
+// MigrationVersion returns the currently applied version and whether the
+// last migration failed halfway.
+func MigrationVersion() (uint, bool, error) {
+	m, err := newMigrator()
+	if err != nil {
+		return 0, false, err
+	}
+	defer closeMigrator(m)
+
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read migration version: %w", err)
+	}
+	return version, dirty, nil
+}
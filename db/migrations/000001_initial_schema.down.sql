@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS images;
+DROP TABLE IF EXISTS trees;
+DROP TABLE IF EXISTS meadows;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,49 @@
+CREATE TABLE IF NOT EXISTS users (
+    ID INT NOT NULL AUTO_INCREMENT,
+    username VARCHAR(255) NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    changed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (ID),
+    UNIQUE KEY uq_users_username (username),
+    UNIQUE KEY uq_users_email (email)
+);
+
+CREATE TABLE IF NOT EXISTS meadows (
+    ID INT NOT NULL AUTO_INCREMENT,
+    Location VARCHAR(255) NOT NULL DEFAULT '',
+    Name VARCHAR(255) NOT NULL DEFAULT '',
+    Size JSON NOT NULL,
+    TreeIds JSON NOT NULL,
+    user_id INT NOT NULL,
+    PRIMARY KEY (ID),
+    KEY idx_meadows_user_id (user_id),
+    CONSTRAINT fk_meadows_user FOREIGN KEY (user_id) REFERENCES users (ID) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS trees (
+    ID INT NOT NULL AUTO_INCREMENT,
+    PlantDate DATETIME NOT NULL,
+    MeadowId INT NOT NULL,
+    Position JSON NOT NULL,
+    Type VARCHAR(255) NOT NULL DEFAULT '',
+    user_id INT NOT NULL,
+    PRIMARY KEY (ID),
+    KEY idx_trees_user_id (user_id),
+    KEY idx_trees_meadow_id (MeadowId),
+    CONSTRAINT fk_trees_user FOREIGN KEY (user_id) REFERENCES users (ID) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS images (
+    id INT NOT NULL AUTO_INCREMENT,
+    path VARCHAR(512) NOT NULL,
+    description TEXT NOT NULL,
+    datetime DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    user_id INT NOT NULL,
+    tree_id INT NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_images_user_id (user_id),
+    KEY idx_images_tree_id (tree_id),
+    CONSTRAINT fk_images_user FOREIGN KEY (user_id) REFERENCES users (ID) ON DELETE CASCADE
+);
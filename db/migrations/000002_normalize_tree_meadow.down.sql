@@ -0,0 +1,11 @@
+ALTER TABLE meadows ADD COLUMN TreeIds JSON NULL;
+
+UPDATE meadows m
+SET m.TreeIds = COALESCE(
+    (SELECT JSON_ARRAYAGG(t.ID) FROM trees t WHERE t.MeadowId = m.ID),
+    JSON_ARRAY()
+);
+
+ALTER TABLE meadows MODIFY COLUMN TreeIds JSON NOT NULL;
+
+ALTER TABLE trees DROP FOREIGN KEY fk_trees_meadow;
@@ -0,0 +1,36 @@
+-- Trees whose MeadowId points to no meadow take the meadow that lists them
+-- in its TreeIds blob. Trees with a valid MeadowId keep it, since the trees
+-- table is the source of truth from now on.
+UPDATE trees t
+JOIN meadows m ON m.user_id = t.user_id
+JOIN JSON_TABLE(m.TreeIds, '$[*]' COLUMNS (tree_id INT PATH '$')) ids ON ids.tree_id = t.ID
+LEFT JOIN meadows current_meadow ON current_meadow.ID = t.MeadowId
+SET t.MeadowId = m.ID
+WHERE current_meadow.ID IS NULL;
+
+-- Whatever is still orphaned was never reachable through a meadow. Rather
+-- than dropping it, each owner gets a holding meadow that lists their
+-- orphaned trees, so they can be sorted out by hand. The orphans are noted
+-- first, as a new meadow could take the ID a stale MeadowId points to.
+CREATE TABLE orphaned_trees_000002 AS
+SELECT t.ID, t.user_id FROM trees t
+LEFT JOIN meadows m ON m.ID = t.MeadowId
+WHERE m.ID IS NULL;
+
+INSERT INTO meadows (Location, Name, Size, TreeIds, user_id)
+SELECT DISTINCT '', 'Unassigned trees', JSON_ARRAY(), JSON_ARRAY(), o.user_id
+FROM orphaned_trees_000002 o;
+
+UPDATE trees t
+JOIN orphaned_trees_000002 o ON o.ID = t.ID
+SET t.MeadowId = (
+    SELECT MAX(holding.ID) FROM meadows holding
+    WHERE holding.user_id = o.user_id AND holding.Name = 'Unassigned trees'
+);
+
+DROP TABLE orphaned_trees_000002;
+
+ALTER TABLE trees
+    ADD CONSTRAINT fk_trees_meadow FOREIGN KEY (MeadowId) REFERENCES meadows (ID) ON DELETE CASCADE;
+
+ALTER TABLE meadows DROP COLUMN TreeIds;
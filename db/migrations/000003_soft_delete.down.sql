@@ -0,0 +1,12 @@
+-- Trashed rows cannot be represented without deleted_at, so they are purged.
+DELETE i FROM images i
+LEFT JOIN trees t ON t.ID = i.tree_id
+LEFT JOIN meadows m ON m.ID = t.MeadowId
+WHERE i.deleted_at IS NOT NULL OR t.deleted_at IS NOT NULL OR m.deleted_at IS NOT NULL;
+
+DELETE FROM trees WHERE deleted_at IS NOT NULL;
+DELETE FROM meadows WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE images DROP KEY idx_images_deleted_at, DROP COLUMN deleted_at;
+ALTER TABLE trees DROP KEY idx_trees_deleted_at, DROP COLUMN deleted_at;
+ALTER TABLE meadows DROP KEY idx_meadows_deleted_at, DROP COLUMN deleted_at;
@@ -0,0 +1,11 @@
+ALTER TABLE meadows
+    ADD COLUMN deleted_at DATETIME NULL,
+    ADD KEY idx_meadows_deleted_at (deleted_at);
+
+ALTER TABLE trees
+    ADD COLUMN deleted_at DATETIME NULL,
+    ADD KEY idx_trees_deleted_at (deleted_at);
+
+ALTER TABLE images
+    ADD COLUMN deleted_at DATETIME NULL,
+    ADD KEY idx_images_deleted_at (deleted_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS inspection_images;
+DROP TABLE IF EXISTS tree_inspections;
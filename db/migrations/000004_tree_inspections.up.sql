@@ -0,0 +1,23 @@
+CREATE TABLE tree_inspections (
+    id INT NOT NULL AUTO_INCREMENT,
+    tree_id INT NOT NULL,
+    user_id INT NOT NULL,
+    date DATETIME NOT NULL,
+    inspector VARCHAR(255) NOT NULL DEFAULT '',
+    vitality TINYINT NOT NULL,
+    diseases JSON NOT NULL,
+    pests JSON NOT NULL,
+    notes TEXT NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_tree_inspections_tree_date (tree_id, date),
+    CONSTRAINT fk_tree_inspections_tree FOREIGN KEY (tree_id) REFERENCES trees (ID) ON DELETE CASCADE,
+    CONSTRAINT fk_tree_inspections_user FOREIGN KEY (user_id) REFERENCES users (ID) ON DELETE CASCADE
+);
+
+CREATE TABLE inspection_images (
+    inspection_id INT NOT NULL,
+    image_id INT NOT NULL,
+    PRIMARY KEY (inspection_id, image_id),
+    CONSTRAINT fk_inspection_images_inspection FOREIGN KEY (inspection_id) REFERENCES tree_inspections (id) ON DELETE CASCADE,
+    CONSTRAINT fk_inspection_images_image FOREIGN KEY (image_id) REFERENCES images (id) ON DELETE CASCADE
+);
@@ -0,0 +1,15 @@
+CREATE TABLE harvests (
+    id INT NOT NULL AUTO_INCREMENT,
+    tree_id INT NOT NULL,
+    user_id INT NOT NULL,
+    date DATE NOT NULL,
+    quantity DECIMAL(10, 3) NOT NULL,
+    unit VARCHAR(8) NOT NULL,
+    grade VARCHAR(32) NOT NULL DEFAULT '',
+    notes TEXT NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_harvests_tree_date (tree_id, date),
+    KEY idx_harvests_user_date (user_id, date),
+    CONSTRAINT fk_harvests_tree FOREIGN KEY (tree_id) REFERENCES trees (ID) ON DELETE CASCADE,
+    CONSTRAINT fk_harvests_user FOREIGN KEY (user_id) REFERENCES users (ID) ON DELETE CASCADE
+);
@@ -0,0 +1,18 @@
+CREATE TABLE tasks (
+    id INT NOT NULL AUTO_INCREMENT,
+    user_id INT NOT NULL,
+    tree_id INT NULL,
+    meadow_id INT NULL,
+    type VARCHAR(32) NOT NULL,
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    notes TEXT NOT NULL,
+    due_date DATE NOT NULL,
+    recurrence JSON NULL,
+    assignee VARCHAR(255) NOT NULL DEFAULT '',
+    completed_at DATETIME NULL,
+    PRIMARY KEY (id),
+    KEY idx_tasks_user_due (user_id, completed_at, due_date),
+    CONSTRAINT fk_tasks_user FOREIGN KEY (user_id) REFERENCES users (ID) ON DELETE CASCADE,
+    CONSTRAINT fk_tasks_tree FOREIGN KEY (tree_id) REFERENCES trees (ID) ON DELETE CASCADE,
+    CONSTRAINT fk_tasks_meadow FOREIGN KEY (meadow_id) REFERENCES meadows (ID) ON DELETE CASCADE
+);
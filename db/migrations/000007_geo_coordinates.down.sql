@@ -0,0 +1,7 @@
+ALTER TABLE trees
+    DROP COLUMN Longitude,
+    DROP COLUMN Latitude;
+
+ALTER TABLE meadows
+    DROP COLUMN Grid,
+    DROP COLUMN Boundary;
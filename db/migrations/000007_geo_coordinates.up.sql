@@ -0,0 +1,7 @@
+ALTER TABLE meadows
+    ADD COLUMN Boundary JSON NULL,
+    ADD COLUMN Grid JSON NULL;
+
+ALTER TABLE trees
+    ADD COLUMN Latitude DOUBLE NULL,
+    ADD COLUMN Longitude DOUBLE NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE trees
+    DROP KEY idx_trees_variety_id,
+    DROP COLUMN variety_id;
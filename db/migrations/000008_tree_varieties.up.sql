@@ -0,0 +1,6 @@
+-- Trees refer to the variety catalog, which is embedded into the binary
+-- rather than stored in the database. Existing types are mapped to
+-- varieties with `migrate varieties`.
+ALTER TABLE trees
+    ADD COLUMN variety_id INT NULL,
+    ADD KEY idx_trees_variety_id (variety_id);
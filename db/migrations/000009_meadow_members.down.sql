@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS meadow_invitations;
+DROP TABLE IF EXISTS meadow_members;
@@ -0,0 +1,29 @@
+CREATE TABLE meadow_members (
+    meadow_id INT NOT NULL,
+    user_id INT NOT NULL,
+    role VARCHAR(16) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (meadow_id, user_id),
+    KEY idx_meadow_members_user_id (user_id),
+    CONSTRAINT fk_meadow_members_meadow FOREIGN KEY (meadow_id) REFERENCES meadows (ID) ON DELETE CASCADE,
+    CONSTRAINT fk_meadow_members_user FOREIGN KEY (user_id) REFERENCES users (ID) ON DELETE CASCADE
+);
+
+-- Access is decided by membership from now on; the user_id columns only
+-- record who created a row. Every meadow starts with its creator as owner.
+INSERT INTO meadow_members (meadow_id, user_id, role)
+SELECT ID, user_id, 'owner' FROM meadows;
+
+CREATE TABLE meadow_invitations (
+    id INT NOT NULL AUTO_INCREMENT,
+    meadow_id INT NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(16) NOT NULL,
+    invited_by INT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_meadow_invitations_meadow_email (meadow_id, email),
+    KEY idx_meadow_invitations_email (email),
+    CONSTRAINT fk_meadow_invitations_meadow FOREIGN KEY (meadow_id) REFERENCES meadows (ID) ON DELETE CASCADE,
+    CONSTRAINT fk_meadow_invitations_user FOREIGN KEY (invited_by) REFERENCES users (ID) ON DELETE CASCADE
+);
@@ -0,0 +1,6 @@
+DROP VIEW IF EXISTS meadow_access;
+ALTER TABLE meadows
+    DROP FOREIGN KEY fk_meadows_organization,
+    DROP COLUMN organization_id;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,37 @@
+CREATE TABLE organizations (
+    id INT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(255) NOT NULL,
+    created_by INT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_organizations_user FOREIGN KEY (created_by) REFERENCES users (ID) ON DELETE SET NULL
+);
+
+CREATE TABLE organization_members (
+    organization_id INT NOT NULL,
+    user_id INT NOT NULL,
+    role VARCHAR(16) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, user_id),
+    KEY idx_organization_members_user_id (user_id),
+    CONSTRAINT fk_organization_members_organization FOREIGN KEY (organization_id) REFERENCES organizations (id) ON DELETE CASCADE,
+    CONSTRAINT fk_organization_members_user FOREIGN KEY (user_id) REFERENCES users (ID) ON DELETE CASCADE
+);
+
+-- Meadows without an organization are personal. Deleting an organization
+-- is only allowed once it owns no live meadows, so only trashed ones lose it.
+ALTER TABLE meadows
+    ADD COLUMN organization_id INT NULL,
+    ADD CONSTRAINT fk_meadows_organization FOREIGN KEY (organization_id) REFERENCES organizations (id) ON DELETE SET NULL;
+
+-- The role of each user in each meadow: the better of the role as a member
+-- of the meadow and the role as a member of the organization owning it.
+CREATE VIEW meadow_access AS
+SELECT meadow_id, user_id, ELT(MAX(FIELD(role, 'viewer', 'editor', 'owner')), 'viewer', 'editor', 'owner') AS role
+FROM (
+    SELECT meadow_id, user_id, role FROM meadow_members
+    UNION ALL
+    SELECT m.ID, om.user_id, om.role FROM meadows m
+    JOIN organization_members om ON om.organization_id = m.organization_id
+) memberships
+GROUP BY meadow_id, user_id;
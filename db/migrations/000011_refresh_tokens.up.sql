@@ -0,0 +1,27 @@
+-- Every login starts a token family. Refreshing replaces the family's
+-- refresh token with a new one and extends the family's expiry; access
+-- tokens name their family and stop working once it is revoked.
+CREATE TABLE token_families (
+    id CHAR(32) NOT NULL,
+    user_id INT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME NULL,
+    PRIMARY KEY (id),
+    KEY idx_token_families_user_id (user_id),
+    KEY idx_token_families_expires_at (expires_at),
+    CONSTRAINT fk_token_families_user FOREIGN KEY (user_id) REFERENCES users (ID) ON DELETE CASCADE
+);
+
+-- Only SHA-256 hashes of refresh tokens are stored. Replaced tokens are
+-- kept with used_at set, so that using one again revokes the family.
+CREATE TABLE refresh_tokens (
+    token_hash CHAR(64) NOT NULL,
+    family_id CHAR(32) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME NULL,
+    PRIMARY KEY (token_hash),
+    KEY idx_refresh_tokens_family_id (family_id),
+    CONSTRAINT fk_refresh_tokens_family FOREIGN KEY (family_id) REFERENCES token_families (id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+-- Accounts that existed before email verification count as verified, so
+-- that enforcing verification does not lock them out.
+ALTER TABLE users ADD COLUMN email_verified_at DATETIME NULL;
+UPDATE users SET email_verified_at = created_at;
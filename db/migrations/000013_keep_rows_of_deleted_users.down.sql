@@ -0,0 +1,37 @@
+-- Rows of deleted accounts have no creator to go back to and are dropped.
+DELETE FROM tasks WHERE user_id IS NULL;
+DELETE FROM harvests WHERE user_id IS NULL;
+DELETE FROM tree_inspections WHERE user_id IS NULL;
+DELETE FROM images WHERE user_id IS NULL;
+DELETE FROM trees WHERE user_id IS NULL;
+DELETE FROM meadows WHERE user_id IS NULL;
+
+ALTER TABLE tasks DROP FOREIGN KEY fk_tasks_user;
+ALTER TABLE tasks
+    MODIFY user_id INT NOT NULL,
+    ADD CONSTRAINT fk_tasks_user FOREIGN KEY (user_id) REFERENCES users (ID) ON DELETE CASCADE;
+
+ALTER TABLE harvests DROP FOREIGN KEY fk_harvests_user;
+ALTER TABLE harvests
+    MODIFY user_id INT NOT NULL,
+    ADD CONSTRAINT fk_harvests_user FOREIGN KEY (user_id) REFERENCES users (ID) ON DELETE CASCADE;
+
+ALTER TABLE tree_inspections DROP FOREIGN KEY fk_tree_inspections_user;
+ALTER TABLE tree_inspections
+    MODIFY user_id INT NOT NULL,
+    ADD CONSTRAINT fk_tree_inspections_user FOREIGN KEY (user_id) REFERENCES users (ID) ON DELETE CASCADE;
+
+ALTER TABLE images DROP FOREIGN KEY fk_images_user;
+ALTER TABLE images
+    MODIFY user_id INT NOT NULL,
+    ADD CONSTRAINT fk_images_user FOREIGN KEY (user_id) REFERENCES users (ID) ON DELETE CASCADE;
+
+ALTER TABLE trees DROP FOREIGN KEY fk_trees_user;
+ALTER TABLE trees
+    MODIFY user_id INT NOT NULL,
+    ADD CONSTRAINT fk_trees_user FOREIGN KEY (user_id) REFERENCES users (ID) ON DELETE CASCADE;
+
+ALTER TABLE meadows DROP FOREIGN KEY fk_meadows_user;
+ALTER TABLE meadows
+    MODIFY user_id INT NOT NULL,
+    ADD CONSTRAINT fk_meadows_user FOREIGN KEY (user_id) REFERENCES users (ID) ON DELETE CASCADE;
@@ -0,0 +1,32 @@
+-- The user_id columns only record who created a row. Deleting an account
+-- must not take rows on meadows shared with others along with it, so they
+-- forget their creator instead.
+ALTER TABLE meadows DROP FOREIGN KEY fk_meadows_user;
+ALTER TABLE meadows
+    MODIFY user_id INT NULL,
+    ADD CONSTRAINT fk_meadows_user FOREIGN KEY (user_id) REFERENCES users (ID) ON DELETE SET NULL;
+
+ALTER TABLE trees DROP FOREIGN KEY fk_trees_user;
+ALTER TABLE trees
+    MODIFY user_id INT NULL,
+    ADD CONSTRAINT fk_trees_user FOREIGN KEY (user_id) REFERENCES users (ID) ON DELETE SET NULL;
+
+ALTER TABLE images DROP FOREIGN KEY fk_images_user;
+ALTER TABLE images
+    MODIFY user_id INT NULL,
+    ADD CONSTRAINT fk_images_user FOREIGN KEY (user_id) REFERENCES users (ID) ON DELETE SET NULL;
+
+ALTER TABLE tree_inspections DROP FOREIGN KEY fk_tree_inspections_user;
+ALTER TABLE tree_inspections
+    MODIFY user_id INT NULL,
+    ADD CONSTRAINT fk_tree_inspections_user FOREIGN KEY (user_id) REFERENCES users (ID) ON DELETE SET NULL;
+
+ALTER TABLE harvests DROP FOREIGN KEY fk_harvests_user;
+ALTER TABLE harvests
+    MODIFY user_id INT NULL,
+    ADD CONSTRAINT fk_harvests_user FOREIGN KEY (user_id) REFERENCES users (ID) ON DELETE SET NULL;
+
+ALTER TABLE tasks DROP FOREIGN KEY fk_tasks_user;
+ALTER TABLE tasks
+    MODIFY user_id INT NULL,
+    ADD CONSTRAINT fk_tasks_user FOREIGN KEY (user_id) REFERENCES users (ID) ON DELETE SET NULL;
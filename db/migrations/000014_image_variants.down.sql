@@ -0,0 +1,3 @@
+ALTER TABLE images
+    DROP COLUMN thumbnail_path,
+    DROP COLUMN medium_path;
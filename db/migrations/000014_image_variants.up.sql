@@ -0,0 +1,5 @@
+-- Resized JPEG copies of the uploaded file; NULL for images uploaded before
+-- variants were generated.
+ALTER TABLE images
+    ADD COLUMN thumbnail_path VARCHAR(512) NULL AFTER path,
+    ADD COLUMN medium_path VARCHAR(512) NULL AFTER thumbnail_path;
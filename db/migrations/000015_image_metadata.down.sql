@@ -0,0 +1,5 @@
+ALTER TABLE images
+    DROP COLUMN Latitude,
+    DROP COLUMN Longitude,
+    DROP COLUMN camera_model,
+    DROP COLUMN orientation;
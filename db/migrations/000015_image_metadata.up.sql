@@ -0,0 +1,6 @@
+-- Fields read from the EXIF data of uploaded photos
+ALTER TABLE images
+    ADD COLUMN Latitude DOUBLE NULL,
+    ADD COLUMN Longitude DOUBLE NULL,
+    ADD COLUMN camera_model VARCHAR(255) NULL,
+    ADD COLUMN orientation TINYINT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE images
+    DROP KEY idx_images_tree_sha256,
+    DROP COLUMN sha256;
@@ -0,0 +1,5 @@
+-- SHA-256 of the stored file, hex encoded; NULL for images uploaded before
+-- hashes were recorded.
+ALTER TABLE images
+    ADD COLUMN sha256 CHAR(64) NULL AFTER medium_path,
+    ADD KEY idx_images_tree_sha256 (tree_id, sha256);
@@ -0,0 +1,4 @@
+ALTER TABLE images
+    DROP KEY idx_images_tree_upload_sha256,
+    DROP COLUMN live_upload_sha256,
+    DROP COLUMN upload_sha256;
@@ -0,0 +1,20 @@
+-- SHA-256 of the file as it was uploaded, hex encoded, before GPS data was
+-- stripped or it was converted to JPEG. sha256 stays the hash of the stored
+-- file, which verification checks. Uploads are deduplicated on the live
+-- images of a tree, so images in the trash do not count.
+ALTER TABLE images
+    ADD COLUMN upload_sha256 CHAR(64) NULL AFTER sha256;
+ALTER TABLE images
+    ADD COLUMN live_upload_sha256 CHAR(64) AS (IF(deleted_at IS NULL, upload_sha256, NULL)) STORED;
+
+-- Older images were hashed as stored, which only differs if GPS data was
+-- stripped. Of copies stored by concurrent uploads the first keeps it.
+UPDATE images i JOIN (
+    SELECT MIN(id) AS id FROM images
+    WHERE sha256 IS NOT NULL AND deleted_at IS NULL
+    GROUP BY tree_id, sha256
+) kept ON kept.id = i.id
+SET i.upload_sha256 = i.sha256;
+
+ALTER TABLE images
+    ADD UNIQUE KEY idx_images_tree_upload_sha256 (tree_id, live_upload_sha256);
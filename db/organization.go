@@ -0,0 +1,307 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+// Deletes the organization. Only owners may do that, and only once the
+// organization owns no meadows outside the trash. Its trashed meadows have
+// no members of their own who could restore them, so they are deleted for
+// good along with it; the report lists their files for the caller to remove.
+func (s *MySQLStore) DeleteOrganizationForUser(organizationId int, userID int) (models.DeletionReport, error) {
+	report := models.DeletionReport{MeadowIds: []int{}, TreeIds: []int{}, ImageIds: []int{}, Files: []string{}}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return report, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := authorizeOrganization(tx, organizationId, userID, models.RoleOwner); err != nil {
+		return report, err
+	}
+
+	var meadows int
+	err = tx.QueryRow("SELECT COUNT(*) FROM meadows WHERE organization_id = ? AND deleted_at IS NULL FOR UPDATE",
+		organizationId).Scan(&meadows)
+	if err != nil {
+		return report, fmt.Errorf("failed to count meadows of organization %d: %w", organizationId, err)
+	}
+	if meadows > 0 {
+		return report, fmt.Errorf("organization %d still owns %d meadows: %w", organizationId, meadows, ErrConflict)
+	}
+
+	trashed, err := queryIDs(tx, "SELECT ID FROM meadows WHERE organization_id = ? FOR UPDATE", organizationId)
+	if err != nil {
+		return report, err
+	}
+	for _, meadowId := range trashed {
+		if err := purgeMeadow(tx, meadowId, &report); err != nil {
+			return report, err
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM organizations WHERE id = ?", organizationId); err != nil {
+		return report, fmt.Errorf("failed to delete organization %d: %w", organizationId, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return report, fmt.Errorf("failed to commit organization deletion: %w", err)
+	}
+
+	fmt.Printf("Deleted organization of user %d with ID %d and %d meadows from the trash\n", userID, organizationId, len(report.MeadowIds))
+	return report, nil
+}
+
+// Removes a member from the organization. Owners may remove anyone, other
+// members only themselves, and the last owner may not be removed at all.
+func (s *MySQLStore) DeleteOrganizationMemberForUser(organizationId int, memberId int, userID int) error {
+	need := models.RoleOwner
+	if memberId == userID {
+		need = models.RoleViewer
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := authorizeOrganization(tx, organizationId, userID, need); err != nil {
+		return err
+	}
+	role, err := organizationMemberRole(tx, organizationId, memberId)
+	if err != nil {
+		return err
+	}
+	if role == models.RoleOwner {
+		if err := checkOtherOrganizationOwners(tx, organizationId); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM organization_members WHERE organization_id = ? AND user_id = ?", organizationId, memberId); err != nil {
+		return fmt.Errorf("failed to remove member %d from organization %d: %w", memberId, organizationId, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit member removal: %w", err)
+	}
+
+	fmt.Printf("User %d removed member %d from organization %d\n", userID, memberId, organizationId)
+	return nil
+}
+
+// Lists the organizations the user is a member of, with the user's role
+func (s *MySQLStore) FindAllOrganizationsForUser(userID int) ([]models.Organization, error) {
+	rows, err := s.conn.Query(`SELECT o.id, o.name, om.role, o.created_at FROM organizations o
+		JOIN organization_members om ON om.organization_id = o.id
+		WHERE om.user_id = ? ORDER BY o.name, o.id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query organizations: %w", err)
+	}
+	defer rows.Close()
+
+	organizations := []models.Organization{}
+	for rows.Next() {
+		var organization models.Organization
+		if err := rows.Scan(&organization.ID, &organization.Name, &organization.Role, &organization.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan organization: %w", err)
+		}
+		organizations = append(organizations, organization)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read organizations: %w", err)
+	}
+	return organizations, nil
+}
+
+func (s *MySQLStore) FindOneOrganizationForUser(organizationId int, userID int) (models.Organization, error) {
+	var organization models.Organization
+
+	err := s.conn.QueryRow(`SELECT o.id, o.name, om.role, o.created_at FROM organizations o
+		JOIN organization_members om ON om.organization_id = o.id AND om.user_id = ?
+		WHERE o.id = ?`, userID, organizationId).Scan(&organization.ID, &organization.Name, &organization.Role, &organization.CreatedAt)
+	if err == sql.ErrNoRows {
+		return organization, fmt.Errorf("organization %d: %w", organizationId, ErrNotFound)
+	}
+	if err != nil {
+		return organization, fmt.Errorf("failed to find organization %d: %w", organizationId, err)
+	}
+	return organization, nil
+}
+
+// Lists the members of the organization, to any of its members
+func (s *MySQLStore) FindOrganizationMembers(organizationId int, userID int) ([]models.OrganizationMember, error) {
+	if err := authorizeOrganization(s.conn, organizationId, userID, models.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.conn.Query(`SELECT om.organization_id, om.user_id, u.username, om.role, om.created_at
+		FROM organization_members om JOIN users u ON u.ID = om.user_id
+		WHERE om.organization_id = ? ORDER BY om.created_at, om.user_id`, organizationId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query members of organization %d: %w", organizationId, err)
+	}
+	defer rows.Close()
+
+	members := []models.OrganizationMember{}
+	for rows.Next() {
+		var member models.OrganizationMember
+		if err := rows.Scan(&member.OrganizationId, &member.UserId, &member.Username, &member.Role, &member.Since); err != nil {
+			return nil, fmt.Errorf("failed to scan member: %w", err)
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read members: %w", err)
+	}
+	return members, nil
+}
+
+// Inserts the organization with the user as its owner
+func (s *MySQLStore) InsertOrganizationForUser(organization models.Organization, userID int) (int64, error) {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO organizations (name, created_by) VALUES (?, ?)", organization.Name, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert organization: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read inserted organization ID: %w", err)
+	}
+	if _, err := tx.Exec("INSERT INTO organization_members (organization_id, user_id, role) VALUES (?, ?, ?)",
+		id, userID, models.RoleOwner); err != nil {
+		return 0, fmt.Errorf("failed to add owner of organization %d: %w", id, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit organization: %w", err)
+	}
+
+	fmt.Printf("Inserted an organization for the user %d with ID: %d\n", userID, id)
+	return id, nil
+}
+
+// Adds the user with the username to the organization. Only owners may add
+// members.
+func (s *MySQLStore) InsertOrganizationMemberForUser(organizationId int, username string, role models.Role, userID int) error {
+	if err := role.Validate(); err != nil {
+		return fmt.Errorf("%v: %w", err, ErrInvalidInput)
+	}
+	if err := authorizeOrganization(s.conn, organizationId, userID, models.RoleOwner); err != nil {
+		return err
+	}
+
+	var memberId int
+	err := s.conn.QueryRow("SELECT ID FROM users WHERE username = ?", username).Scan(&memberId)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("user %s: %w", username, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find user %s: %w", username, err)
+	}
+
+	_, err = s.conn.Exec("INSERT INTO organization_members (organization_id, user_id, role) VALUES (?, ?, ?)",
+		organizationId, memberId, role)
+	if isDuplicateEntry(err) {
+		return fmt.Errorf("user %s is already a member of organization %d: %w", username, organizationId, ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add member to organization %d: %w", organizationId, err)
+	}
+
+	fmt.Printf("User %d added %s to organization %d as %s\n", userID, username, organizationId, role)
+	return nil
+}
+
+// Renames the organization. Only owners may do that.
+func (s *MySQLStore) UpdateOrganizationForUser(organization models.Organization, userID int) error {
+	if err := authorizeOrganization(s.conn, organization.ID, userID, models.RoleOwner); err != nil {
+		return err
+	}
+
+	if _, err := s.conn.Exec("UPDATE organizations SET name = ? WHERE id = ?", organization.Name, organization.ID); err != nil {
+		return fmt.Errorf("failed to update organization %d: %w", organization.ID, err)
+	}
+
+	fmt.Printf("Updated organization of user %d with ID %d\n", userID, organization.ID)
+	return nil
+}
+
+// Changes the role of a member of the organization. Only owners may do
+// that, and the last owner may not be demoted.
+func (s *MySQLStore) UpdateOrganizationMemberForUser(organizationId int, memberId int, role models.Role, userID int) error {
+	if err := role.Validate(); err != nil {
+		return fmt.Errorf("%v: %w", err, ErrInvalidInput)
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := authorizeOrganization(tx, organizationId, userID, models.RoleOwner); err != nil {
+		return err
+	}
+	current, err := organizationMemberRole(tx, organizationId, memberId)
+	if err != nil {
+		return err
+	}
+	if current == models.RoleOwner && role != models.RoleOwner {
+		if err := checkOtherOrganizationOwners(tx, organizationId); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE organization_members SET role = ? WHERE organization_id = ? AND user_id = ?",
+		role, organizationId, memberId); err != nil {
+		return fmt.Errorf("failed to update member %d of organization %d: %w", memberId, organizationId, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit member update: %w", err)
+	}
+
+	fmt.Printf("User %d made member %d of organization %d %s\n", userID, memberId, organizationId, role)
+	return nil
+}
+
+// organizationMemberRole reads and locks the member's role in the
+// organization.
+func organizationMemberRole(tx *sql.Tx, organizationId int, memberId int) (models.Role, error) {
+	var role models.Role
+	err := tx.QueryRow("SELECT role FROM organization_members WHERE organization_id = ? AND user_id = ? FOR UPDATE",
+		organizationId, memberId).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("member %d of organization %d: %w", memberId, organizationId, ErrNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query member %d of organization %d: %w", memberId, organizationId, err)
+	}
+	return role, nil
+}
+
+// checkOtherOrganizationOwners fails with ErrConflict unless the
+// organization has more than one owner.
+func checkOtherOrganizationOwners(tx *sql.Tx, organizationId int) error {
+	var owners int
+	err := tx.QueryRow("SELECT COUNT(*) FROM organization_members WHERE organization_id = ? AND role = ? FOR UPDATE",
+		organizationId, models.RoleOwner).Scan(&owners)
+	if err != nil {
+		return fmt.Errorf("failed to count owners of organization %d: %w", organizationId, err)
+	}
+	if owners <= 1 {
+		return fmt.Errorf("organization %d needs at least one owner: %w", organizationId, ErrConflict)
+	}
+	return nil
+}
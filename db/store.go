@@ -0,0 +1,248 @@
+package db
+
+import (
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+// The stores return errors wrapping ErrNotFound, ErrConflict, ErrForbidden
+// or ErrInvalidInput where the caller can act on them; any other error is
+// an unexpected storage failure.
+//
+// Meadows are shared through memberships. Everything on a meadow, its trees,
+// images, inspections, harvests and tasks, is visible to all its members,
+// changed by editors and owners, and the meadow itself is deleted by owners
+// only. Members of an organization hold their organization role in all of
+// its meadows. Users who are no members get ErrNotFound, members whose role
+// does not allow a change ErrForbidden.
+
+// MeadowStore persists meadows. A meadow is inserted into the organization
+// named by its Scope, which needs the user to be at least an editor there,
+// or else as a personal meadow owned by the user. Meadows are read with the
+// user's role and their scope. A meadow's TreeIds are derived from its trees
+// and ignored on writes. Deleting a meadow moves it to the trash together
+// with its trees and their images.
+type MeadowStore interface {
+	DeleteOneMeadowForUser(meadowId int, userID int) (models.DeletionReport, error)
+	FindAllMeadowsForUser(userID int) ([]models.Meadow, error)
+	FindAllMeadowsOfOrganization(organizationId int, userID int) ([]models.Meadow, error)
+	FindOneMeadowByIdForUser(meadowId int, userID int) (models.Meadow, error)
+	ImportMeadowForUser(meadow models.Meadow, trees []models.Tree, userID int) (int64, []int64, error)
+	InsertOneMeadowForUser(meadow models.Meadow, userID int) (int64, error)
+	UpdateMeadowForUser(meadow models.Meadow, userID int) error
+}
+
+// TreeStore persists trees, scoped to the user's meadows. Trees are read
+// with the condition of their latest inspection. Deleting a tree moves it to
+// the trash together with its images.
+type TreeStore interface {
+	DeleteOneTreeForUser(treeId int, userID int) (models.DeletionReport, error)
+	FindAllTreesForMeadow(meadowId int, userID int, filter TreeFilter) ([]models.Tree, error)
+	FindOneTreeById(treeId int, userID int) (models.Tree, error)
+	InsertOneTreeForUser(tree models.Tree, userID int) (int64, error)
+	InsertTreesForUser(meadowId int, trees []models.Tree, userID int) ([]int64, error)
+	UpdateTreeForUser(tree models.Tree, userID int) error
+}
+
+// TreeFilter narrows the trees of a meadow down by the condition of their
+// latest inspection. Zero values do not filter; any condition filter drops
+// trees that were never inspected.
+type TreeFilter struct {
+	MinVitality *int
+	MaxVitality *int
+	Disease     string
+	Pest        string
+}
+
+// InspectionStore persists tree inspections, scoped to the user's meadows.
+// Images linked to an inspection must belong to the inspected tree;
+// trashed images are left out when reading.
+type InspectionStore interface {
+	DeleteInspectionForUser(inspectionId int, treeId int, userID int) error
+	FindInspectionsForTree(treeId int, userID int) ([]models.TreeInspection, error)
+	FindOneInspectionForUser(inspectionId int, treeId int, userID int) (models.TreeInspection, error)
+	InsertInspectionForUser(inspection models.TreeInspection, userID int) (int64, error)
+	UpdateInspectionForUser(inspection models.TreeInspection, userID int) error
+}
+
+// YieldGroup is what yield statistics are summed up by, besides the year.
+type YieldGroup string
+
+const (
+	YieldByTree    YieldGroup = "trees"
+	YieldByVariety YieldGroup = "varieties"
+	YieldByMeadow  YieldGroup = "meadows"
+)
+
+// YieldFilter selects the harvests that go into yield statistics. Zero
+// values do not filter.
+type YieldFilter struct {
+	MeadowId int
+	FromYear int
+	ToYear   int
+}
+
+// HarvestStore persists harvests, scoped to the user's meadows.
+// Harvests of trashed trees are left out of yield statistics.
+type HarvestStore interface {
+	DeleteHarvestForUser(harvestId int, treeId int, userID int) error
+	FindHarvestsForTree(treeId int, userID int) ([]models.Harvest, error)
+	FindOneHarvestForUser(harvestId int, treeId int, userID int) (models.Harvest, error)
+	FindYieldForUser(userID int, group YieldGroup, filter YieldFilter) ([]models.YieldStat, error)
+	InsertHarvestForUser(harvest models.Harvest, userID int) (int64, error)
+	UpdateHarvestForUser(harvest models.Harvest, userID int) error
+}
+
+// TaskStore persists maintenance tasks, scoped to the user's meadows.
+// Tasks of trashed trees and meadows are left out until they are restored.
+// Completing a recurring task inserts its next occurrence.
+type TaskStore interface {
+	CompleteTaskForUser(taskId int, userID int, completedAt time.Time) (int64, error)
+	DeleteTaskForUser(taskId int, userID int) error
+	FindDueTasksForUser(userID int, before time.Time) ([]models.Task, error)
+	FindOneTaskForUser(taskId int, userID int) (models.Task, error)
+	FindTasksForMeadow(meadowId int, userID int) ([]models.Task, error)
+	FindTasksForTree(treeId int, userID int) ([]models.Task, error)
+	InsertTaskForUser(task models.Task, userID int) (int64, error)
+	UpdateTaskForUser(task models.Task, userID int) error
+}
+
+// ImageStore persists the metadata of uploaded tree images. Images are found
+// by the path of any of their files or by hash only for members of the
+// tree's meadow, and not while they are in the trash. FindAllImages,
+// FindImagesWithoutTree and PurgeImage are for maintenance jobs and act on
+// the images of all users.
+type ImageStore interface {
+	DeleteTreeImage(imageID int, userID int) error
+	FindAllImages() ([]models.Image, error)
+	FindImageByPathForUser(path string, userID int) (models.Image, error)
+	FindImagesWithoutTree() ([]models.Image, error)
+	FindTreeImageByHashForUser(treeID int, hash string, userID int) (models.Image, bool, error)
+	GetTreeImageDb(treeID int, userID int) ([]models.Image, error)
+	PurgeImage(imageID int) error
+	UpdateTreeImageDescriptionDb(imageID int, description string, userID int) error
+	UpdateTreeImageDatetimeDb(imageID int, datetime time.Time, userID int) error
+	UploadImageDb(image models.Image, userID int) (int64, error)
+}
+
+// TrashStore manages soft deleted meadows, trees and images. Deleting
+// through the other stores moves items to the trash, where they stay
+// restorable until PurgeTrash removes them for good.
+type TrashStore interface {
+	FindTrashForUser(userID int) (models.Trash, error)
+	PurgeTrash(before time.Time) (models.DeletionReport, error)
+	RestoreImageForUser(imageID int, userID int) error
+	RestoreMeadowForUser(meadowId int, userID int) error
+	RestoreTreeForUser(treeId int, userID int) error
+}
+
+// MemberStore manages who has access to a meadow. Members are listed to
+// every member, while invitations and roles are managed by owners. Members
+// may leave a meadow themselves, but the last owner of a personal meadow can
+// neither leave nor be demoted. Invitations are addressed to an email address and are accepted
+// or declined by the user with that address, once they have verified it.
+type MemberStore interface {
+	AcceptInvitationForUser(invitationId int, userID int) (int, error)
+	DeclineInvitationForUser(invitationId int, userID int) error
+	DeleteInvitationForUser(invitationId int, meadowId int, userID int) error
+	DeleteMemberForUser(meadowId int, memberId int, userID int) error
+	FindInvitationsForMeadow(meadowId int, userID int) ([]models.Invitation, error)
+	FindInvitationsForUser(userID int) ([]models.Invitation, error)
+	FindMembersForMeadow(meadowId int, userID int) ([]models.MeadowMember, error)
+	InsertInvitationForUser(invitation models.Invitation, userID int) (int64, error)
+	UpdateMemberForUser(meadowId int, memberId int, role models.Role, userID int) error
+}
+
+// OrganizationStore persists organizations and their members. The user who
+// inserts an organization becomes its owner. Members see the organization
+// and each other, owners rename it, manage its members and delete it once
+// it owns no meadows outside the trash, which deletes the meadows in the
+// trash for good. Members may leave an organization
+// themselves, but its last owner can neither leave nor be demoted.
+type OrganizationStore interface {
+	DeleteOrganizationForUser(organizationId int, userID int) (models.DeletionReport, error)
+	DeleteOrganizationMemberForUser(organizationId int, memberId int, userID int) error
+	FindAllOrganizationsForUser(userID int) ([]models.Organization, error)
+	FindOneOrganizationForUser(organizationId int, userID int) (models.Organization, error)
+	FindOrganizationMembers(organizationId int, userID int) ([]models.OrganizationMember, error)
+	InsertOrganizationForUser(organization models.Organization, userID int) (int64, error)
+	InsertOrganizationMemberForUser(organizationId int, username string, role models.Role, userID int) error
+	UpdateOrganizationForUser(organization models.Organization, userID int) error
+	UpdateOrganizationMemberForUser(organizationId int, memberId int, role models.Role, userID int) error
+}
+
+// TypeCount is how many trees share a type that is not linked to a variety.
+type TypeCount struct {
+	Type  string
+	Trees int
+}
+
+// VarietyStore links the free-text types of existing trees to the variety
+// catalog. Unlike the other stores it works across all users and includes
+// trashed trees, as it is meant for the `migrate varieties` command.
+type VarietyStore interface {
+	CountUnlinkedTreeTypes() ([]TypeCount, error)
+	LinkTreeType(treeType string, varietyId int, name string) (int64, error)
+}
+
+// UserStore persists registered accounts. Verifying an email address only
+// succeeds while the account still has that address, and changing the
+// address makes it unverified again. Deleting an account also deletes the
+// personal meadows it is the only owner of, with everything on them; the
+// user's rows on other meadows stay. The last owner of an organization
+// cannot delete the account.
+type UserStore interface {
+	DeleteUser(userID int) (models.DeletionReport, error)
+	EmailExists(email string) (bool, error)
+	FindAccountMeadows(userID int) ([]models.AccountMeadow, error)
+	FindUserByEmail(email string) (models.User, error)
+	FindUserByID(userID int) (models.User, error)
+	FindUserByUsername(username string) (models.User, error)
+	InsertUser(user models.User) error
+	MarkEmailVerified(userID int, email string) error
+	UpdatePassword(userID int, password string) error
+	UpdateUser(user models.User) error
+	UsernameExists(username string) (bool, error)
+}
+
+// TokenStore persists the refresh tokens of logins, see models.RefreshToken.
+// Rotating a token marks it as used and stores its replacement. A used,
+// expired or revoked token cannot be rotated; using a replaced token again
+// means it was stolen, so its whole family is revoked.
+type TokenStore interface {
+	InsertRefreshToken(token models.RefreshToken) error
+	IsTokenFamilyActive(familyId string) (bool, error)
+	PurgeExpiredTokens(before time.Time) (int64, error)
+	RevokeAllTokensForUser(userID int) error
+	RevokeTokenFamilyForUser(familyId string, userID int) error
+	RotateRefreshToken(hash string, next models.RefreshToken) (models.RefreshToken, error)
+}
+
+var (
+	_ MeadowStore       = (*MySQLStore)(nil)
+	_ TreeStore         = (*MySQLStore)(nil)
+	_ InspectionStore   = (*MySQLStore)(nil)
+	_ HarvestStore      = (*MySQLStore)(nil)
+	_ TaskStore         = (*MySQLStore)(nil)
+	_ ImageStore        = (*MySQLStore)(nil)
+	_ TrashStore        = (*MySQLStore)(nil)
+	_ MemberStore       = (*MySQLStore)(nil)
+	_ OrganizationStore = (*MySQLStore)(nil)
+	_ VarietyStore      = (*MySQLStore)(nil)
+	_ UserStore         = (*MySQLStore)(nil)
+	_ TokenStore        = (*MySQLStore)(nil)
+
+	_ MeadowStore       = (*MemoryStore)(nil)
+	_ TreeStore         = (*MemoryStore)(nil)
+	_ InspectionStore   = (*MemoryStore)(nil)
+	_ HarvestStore      = (*MemoryStore)(nil)
+	_ TaskStore         = (*MemoryStore)(nil)
+	_ ImageStore        = (*MemoryStore)(nil)
+	_ TrashStore        = (*MemoryStore)(nil)
+	_ MemberStore       = (*MemoryStore)(nil)
+	_ OrganizationStore = (*MemoryStore)(nil)
+	_ VarietyStore      = (*MemoryStore)(nil)
+	_ UserStore         = (*MemoryStore)(nil)
+	_ TokenStore        = (*MemoryStore)(nil)
+)
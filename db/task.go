@@ -0,0 +1,219 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+// taskColumns selects a task aliased as k. Combined with taskLiveJoin and
+// taskLiveCondition, only tasks whose tree and meadow are not in the trash
+// are selected.
+const taskColumns = "k.id, k.tree_id, k.meadow_id, k.type, k.title, k.notes, k.due_date, k.recurrence, k.assignee, k.completed_at"
+const taskLiveJoin = "LEFT JOIN trees t ON t.ID = k.tree_id JOIN meadows m ON m.ID = COALESCE(k.meadow_id, t.MeadowId)"
+const taskLiveCondition = "(t.ID IS NULL OR t.deleted_at IS NULL) AND m.deleted_at IS NULL"
+
+// Marks the task as completed. For a recurring task the next occurrence is
+// inserted in the same transaction and its ID returned, otherwise 0.
+func (s *MySQLStore) CompleteTaskForUser(taskId int, userID int, completedAt time.Time) (int64, error) {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := authorizeTask(tx, taskId, userID, models.RoleEditor); err != nil {
+		return 0, err
+	}
+
+	row := tx.QueryRow("SELECT "+taskColumns+" FROM tasks k "+taskLiveJoin+
+		" WHERE k.id = ? AND "+taskLiveCondition+" FOR UPDATE", taskId)
+	task, err := scanTask(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("task %d: %w", taskId, ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock task %d: %w", taskId, err)
+	}
+	if task.CompletedAt != nil {
+		return 0, fmt.Errorf("task %d is already completed: %w", taskId, ErrConflict)
+	}
+
+	if _, err := tx.Exec("UPDATE tasks SET completed_at = ? WHERE id = ?", completedAt.UTC(), taskId); err != nil {
+		return 0, fmt.Errorf("failed to complete task %d: %w", taskId, err)
+	}
+
+	var nextID int64
+	if task.Recurrence != nil {
+		next := task
+		next.DueDate = task.Recurrence.Next(task.DueDate, completedAt)
+		next.Recurrence = task.Recurrence.Anchored(task.DueDate)
+		if nextID, err = insertTask(tx, next, userID); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit task completion: %w", err)
+	}
+
+	fmt.Printf("Completed task of user %d with ID %d\n", userID, taskId)
+	return nextID, nil
+}
+
+func (s *MySQLStore) DeleteTaskForUser(taskId int, userID int) error {
+	if err := authorizeTask(s.conn, taskId, userID, models.RoleEditor); err != nil {
+		return err
+	}
+
+	result, err := s.conn.Exec("DELETE FROM tasks WHERE id = ?", taskId)
+	if err != nil {
+		return fmt.Errorf("failed to delete task %d: %w", taskId, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("task %d: %w", taskId, ErrNotFound)
+	}
+
+	fmt.Printf("Deleted task of user %d with ID %d\n", userID, taskId)
+	return nil
+}
+
+// Lists the open tasks of all the meadows the user is a member of, and of
+// their trees, that are due before the given time, overdue ones included
+func (s *MySQLStore) FindDueTasksForUser(userID int, before time.Time) ([]models.Task, error) {
+	return s.queryTasks("SELECT "+taskColumns+" FROM tasks k "+taskLiveJoin+
+		" WHERE "+memberOf("m.ID", models.RoleViewer)+" AND k.completed_at IS NULL AND k.due_date < ? AND "+taskLiveCondition+
+		" ORDER BY k.due_date, k.id", userID, before)
+}
+
+func (s *MySQLStore) FindOneTaskForUser(taskId int, userID int) (models.Task, error) {
+	row := s.conn.QueryRow("SELECT "+taskColumns+" FROM tasks k "+taskLiveJoin+
+		" WHERE k.id = ? AND "+memberOf("m.ID", models.RoleViewer)+" AND "+taskLiveCondition, taskId, userID)
+	task, err := scanTask(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return task, fmt.Errorf("task %d: %w", taskId, ErrNotFound)
+	}
+	if err != nil {
+		return task, fmt.Errorf("failed to find task %d: %w", taskId, err)
+	}
+	return task, nil
+}
+
+// Lists the tasks of the meadow and of its trees
+func (s *MySQLStore) FindTasksForMeadow(meadowId int, userID int) ([]models.Task, error) {
+	if _, err := s.FindOneMeadowByIdForUser(meadowId, userID); err != nil {
+		return nil, err
+	}
+
+	return s.queryTasks("SELECT "+taskColumns+" FROM tasks k "+taskLiveJoin+
+		" WHERE m.ID = ? AND "+taskLiveCondition+
+		" ORDER BY k.due_date, k.id", meadowId)
+}
+
+func (s *MySQLStore) FindTasksForTree(treeId int, userID int) ([]models.Task, error) {
+	if _, err := s.FindOneTreeById(treeId, userID); err != nil {
+		return nil, err
+	}
+
+	return s.queryTasks("SELECT "+taskColumns+" FROM tasks k WHERE k.tree_id = ? ORDER BY k.due_date, k.id", treeId)
+}
+
+func (s *MySQLStore) InsertTaskForUser(task models.Task, userID int) (int64, error) {
+	if task.TreeId != nil {
+		if err := authorizeTree(s.conn, *task.TreeId, userID, models.RoleEditor); err != nil {
+			return 0, err
+		}
+	} else if err := authorizeMeadow(s.conn, *task.MeadowId, userID, models.RoleEditor); err != nil {
+		return 0, err
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	id, err := insertTask(tx, task, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit task: %w", err)
+	}
+
+	fmt.Printf("Inserted task of user %d with ID %d\n", userID, id)
+	return id, nil
+}
+
+// Updates what is to be done and when. The tree or meadow of a task and its
+// completion are not changed.
+func (s *MySQLStore) UpdateTaskForUser(task models.Task, userID int) error {
+	if err := authorizeTask(s.conn, task.ID, userID, models.RoleEditor); err != nil {
+		return err
+	}
+
+	result, err := s.conn.Exec("UPDATE tasks SET type = ?, title = ?, notes = ?, due_date = ?, recurrence = ?, assignee = ? WHERE id = ?",
+		task.Type, task.Title, task.Notes, task.DueDate, task.Recurrence, task.Assignee, task.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update task %d: %w", task.ID, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("task %d: %w", task.ID, ErrNotFound)
+	}
+
+	fmt.Printf("Updated task of user %d with ID %d\n", userID, task.ID)
+	return nil
+}
+
+func (s *MySQLStore) queryTasks(query string, args ...any) ([]models.Task, error) {
+	tasks := []models.Task{}
+
+	rows, err := s.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tasks: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan task: %w", err)
+		}
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tasks: %w", err)
+	}
+	return tasks, nil
+}
+
+func insertTask(tx *sql.Tx, task models.Task, userID int) (int64, error) {
+	result, err := tx.Exec(`INSERT INTO tasks (user_id, tree_id, meadow_id, type, title, notes, due_date, recurrence, assignee)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		userID, task.TreeId, task.MeadowId, task.Type, task.Title, task.Notes, task.DueDate, task.Recurrence, task.Assignee)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert task: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get inserted task ID: %w", err)
+	}
+	return id, nil
+}
+
+// scanTask reads a row selected with taskColumns
+func scanTask(row interface{ Scan(dest ...any) error }) (models.Task, error) {
+	var task models.Task
+	err := row.Scan(&task.ID, &task.TreeId, &task.MeadowId, &task.Type, &task.Title, &task.Notes,
+		&task.DueDate, &task.Recurrence, &task.Assignee, &task.CompletedAt)
+	return task, err
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+// Starts a new token family with its first refresh token
+func (s *MySQLStore) InsertRefreshToken(token models.RefreshToken) error {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("INSERT INTO token_families (id, user_id, expires_at) VALUES (?, ?, ?)",
+		token.FamilyId, token.UserId, token.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to insert token family: %w", err)
+	}
+	if _, err := tx.Exec("INSERT INTO refresh_tokens (token_hash, family_id, expires_at) VALUES (?, ?, ?)",
+		token.Hash, token.FamilyId, token.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to insert refresh token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit refresh token: %w", err)
+	}
+	return nil
+}
+
+// Reports whether the family exists, has not expired and was not revoked
+func (s *MySQLStore) IsTokenFamilyActive(familyId string) (bool, error) {
+	var active bool
+	err := s.conn.QueryRow("SELECT revoked_at IS NULL AND expires_at > ? FROM token_families WHERE id = ?",
+		time.Now(), familyId).Scan(&active)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check token family: %w", err)
+	}
+	return active, nil
+}
+
+// Deletes the families that expired before the given time together with
+// their tokens, and returns how many families were deleted
+func (s *MySQLStore) PurgeExpiredTokens(before time.Time) (int64, error) {
+	result, err := s.conn.Exec("DELETE FROM token_families WHERE expires_at < ?", before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge expired tokens: %w", err)
+	}
+	purged, _ := result.RowsAffected()
+	return purged, nil
+}
+
+func (s *MySQLStore) RevokeAllTokensForUser(userID int) error {
+	if _, err := s.conn.Exec("UPDATE token_families SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL",
+		time.Now(), userID); err != nil {
+		return fmt.Errorf("failed to revoke tokens of user %d: %w", userID, err)
+	}
+
+	fmt.Printf("Revoked all tokens of user %d\n", userID)
+	return nil
+}
+
+func (s *MySQLStore) RevokeTokenFamilyForUser(familyId string, userID int) error {
+	result, err := s.conn.Exec("UPDATE token_families SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL",
+		time.Now(), familyId, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token family: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("token family of user %d: %w", userID, ErrNotFound)
+	}
+
+	fmt.Printf("Revoked a token family of user %d\n", userID)
+	return nil
+}
+
+// Replaces the refresh token with the given hash by next, which joins its
+// family, and returns next with its family and user filled in. Using a
+// replaced token again revokes the family, which is committed even though
+// an error is returned.
+func (s *MySQLStore) RotateRefreshToken(hash string, next models.RefreshToken) (models.RefreshToken, error) {
+	now := time.Now()
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return next, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var expiresAt time.Time
+	var usedAt, revokedAt sql.NullTime
+	err = tx.QueryRow(`SELECT r.family_id, f.user_id, r.expires_at, r.used_at, f.revoked_at
+		FROM refresh_tokens r JOIN token_families f ON f.id = r.family_id
+		WHERE r.token_hash = ? FOR UPDATE`, hash).Scan(&next.FamilyId, &next.UserId, &expiresAt, &usedAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return next, fmt.Errorf("refresh token: %w", ErrNotFound)
+	}
+	if err != nil {
+		return next, fmt.Errorf("failed to find refresh token: %w", err)
+	}
+
+	switch {
+	case revokedAt.Valid:
+		return next, fmt.Errorf("refresh token was revoked: %w", ErrForbidden)
+	case usedAt.Valid:
+		if _, err := tx.Exec("UPDATE token_families SET revoked_at = ? WHERE id = ?", now, next.FamilyId); err != nil {
+			return next, fmt.Errorf("failed to revoke token family: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return next, fmt.Errorf("failed to commit token family revocation: %w", err)
+		}
+		fmt.Printf("Refresh token of user %d was used twice, revoked its token family\n", next.UserId)
+		return next, fmt.Errorf("refresh token was already used, its session is revoked: %w", ErrForbidden)
+	case !expiresAt.After(now):
+		return next, fmt.Errorf("refresh token expired: %w", ErrForbidden)
+	}
+
+	if _, err := tx.Exec("UPDATE refresh_tokens SET used_at = ? WHERE token_hash = ?", now, hash); err != nil {
+		return next, fmt.Errorf("failed to mark refresh token as used: %w", err)
+	}
+	if _, err := tx.Exec("INSERT INTO refresh_tokens (token_hash, family_id, expires_at) VALUES (?, ?, ?)",
+		next.Hash, next.FamilyId, next.ExpiresAt); err != nil {
+		return next, fmt.Errorf("failed to insert refresh token: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE token_families SET expires_at = ? WHERE id = ?", next.ExpiresAt, next.FamilyId); err != nil {
+		return next, fmt.Errorf("failed to extend token family: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return next, fmt.Errorf("failed to commit refresh token: %w", err)
+	}
+	return next, nil
+}
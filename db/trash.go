@@ -0,0 +1,268 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+// Lists the trashed items the user can restore directly: meadows the user
+// owns, and trees of meadows that are not trashed as well as images of trees
+// that are not trashed, where the user is at least an editor.
+func (s *MySQLStore) FindTrashForUser(userID int) (models.Trash, error) {
+	trash := models.Trash{Meadows: []models.Meadow{}, Trees: []models.Tree{}, Images: []models.Image{}}
+
+	meadowRows, err := s.conn.Query(`SELECT m.ID, m.Location, m.Name, m.Size,
+		COALESCE((SELECT JSON_ARRAYAGG(t.ID) FROM trees t WHERE t.MeadowId = m.ID AND t.deleted_at = m.deleted_at), JSON_ARRAY()),
+		m.Boundary, m.Grid, m.deleted_at
+		FROM meadows m WHERE `+memberOf("m.ID", models.RoleOwner)+` AND m.deleted_at IS NOT NULL ORDER BY m.deleted_at DESC`, userID)
+	if err != nil {
+		return trash, fmt.Errorf("failed to query trashed meadows: %w", err)
+	}
+	defer meadowRows.Close()
+
+	for meadowRows.Next() {
+		var med models.Meadow
+		if err := meadowRows.Scan(&med.ID, &med.Location, &med.Name, &med.Size, &med.TreeIds, &med.Boundary, &med.Grid, &med.DeletedAt); err != nil {
+			return trash, fmt.Errorf("failed to scan trashed meadow: %w", err)
+		}
+		med.Area = med.Boundary.Area()
+		trash.Meadows = append(trash.Meadows, med)
+	}
+	if err := meadowRows.Err(); err != nil {
+		return trash, fmt.Errorf("failed to read trashed meadows: %w", err)
+	}
+
+	treeRows, err := s.conn.Query("SELECT "+treeColumns+" FROM trees t "+treeConditionJoin+
+		" JOIN meadows m ON m.ID = t.MeadowId"+
+		" WHERE "+memberOf("m.ID", models.RoleEditor)+" AND t.deleted_at IS NOT NULL AND m.deleted_at IS NULL ORDER BY t.deleted_at DESC", userID)
+	if err != nil {
+		return trash, fmt.Errorf("failed to query trashed trees: %w", err)
+	}
+	defer treeRows.Close()
+
+	for treeRows.Next() {
+		tree, err := scanTree(treeRows)
+		if err != nil {
+			return trash, err
+		}
+		trash.Trees = append(trash.Trees, tree)
+	}
+	if err := treeRows.Err(); err != nil {
+		return trash, fmt.Errorf("failed to read trashed trees: %w", err)
+	}
+
+	imageRows, err := s.conn.Query(`SELECT `+imageColumns+`
+		FROM images i JOIN trees t ON t.ID = i.tree_id
+		WHERE `+memberOf("t.MeadowId", models.RoleEditor)+` AND i.deleted_at IS NOT NULL AND t.deleted_at IS NULL ORDER BY i.deleted_at DESC`, userID)
+	if err != nil {
+		return trash, fmt.Errorf("failed to query trashed images: %w", err)
+	}
+	defer imageRows.Close()
+
+	for imageRows.Next() {
+		img, err := scanImage(imageRows)
+		if err != nil {
+			return trash, err
+		}
+		trash.Images = append(trash.Images, img)
+	}
+	if err := imageRows.Err(); err != nil {
+		return trash, fmt.Errorf("failed to read trashed images: %w", err)
+	}
+
+	return trash, nil
+}
+
+// Restores the meadow together with the trees and images trashed with it.
+// Only owners may do so.
+func (s *MySQLStore) RestoreMeadowForUser(meadowId int, userID int) error {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := authorize(tx, models.RoleOwner, fmt.Sprintf("trashed meadow %d", meadowId),
+		`SELECT mm.role FROM meadows m JOIN meadow_access mm ON mm.meadow_id = m.ID AND mm.user_id = ?
+		WHERE m.ID = ? AND m.deleted_at IS NOT NULL`, userID, meadowId); err != nil {
+		return err
+	}
+
+	var deletedAt time.Time
+	err = tx.QueryRow("SELECT deleted_at FROM meadows WHERE ID = ? AND deleted_at IS NOT NULL FOR UPDATE",
+		meadowId).Scan(&deletedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("trashed meadow %d: %w", meadowId, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock meadow %d: %w", meadowId, err)
+	}
+
+	// Images first, as they are matched through their tree's deleted_at
+	if _, err := tx.Exec(`UPDATE images i JOIN trees t ON t.ID = i.tree_id SET i.deleted_at = NULL
+		WHERE t.MeadowId = ? AND t.deleted_at = ? AND i.deleted_at = ?`, meadowId, deletedAt, deletedAt); err != nil {
+		return fmt.Errorf("failed to restore images of meadow %d: %w", meadowId, err)
+	}
+	if _, err := tx.Exec("UPDATE trees SET deleted_at = NULL WHERE MeadowId = ? AND deleted_at = ?", meadowId, deletedAt); err != nil {
+		return fmt.Errorf("failed to restore trees of meadow %d: %w", meadowId, err)
+	}
+	if _, err := tx.Exec("UPDATE meadows SET deleted_at = NULL WHERE ID = ?", meadowId); err != nil {
+		return fmt.Errorf("failed to restore meadow %d: %w", meadowId, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit meadow restore: %w", err)
+	}
+
+	fmt.Printf("Restored meadow of user %d with ID %d\n", userID, meadowId)
+	return nil
+}
+
+// Restores the tree together with the images trashed with it. A tree of a
+// trashed meadow can only come back with its meadow.
+func (s *MySQLStore) RestoreTreeForUser(treeId int, userID int) error {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := authorize(tx, models.RoleEditor, fmt.Sprintf("trashed tree %d", treeId),
+		`SELECT mm.role FROM trees t JOIN meadow_access mm ON mm.meadow_id = t.MeadowId AND mm.user_id = ?
+		WHERE t.ID = ? AND t.deleted_at IS NOT NULL`, userID, treeId); err != nil {
+		return err
+	}
+
+	var meadowId int
+	var deletedAt time.Time
+	var meadowDeletedAt sql.NullTime
+	err = tx.QueryRow(`SELECT t.MeadowId, t.deleted_at, m.deleted_at FROM trees t JOIN meadows m ON m.ID = t.MeadowId
+		WHERE t.ID = ? AND t.deleted_at IS NOT NULL FOR UPDATE`,
+		treeId).Scan(&meadowId, &deletedAt, &meadowDeletedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("trashed tree %d: %w", treeId, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock tree %d: %w", treeId, err)
+	}
+	if meadowDeletedAt.Valid {
+		return fmt.Errorf("meadow %d of tree %d is in the trash: %w", meadowId, treeId, ErrConflict)
+	}
+
+	if _, err := tx.Exec("UPDATE images SET deleted_at = NULL WHERE tree_id = ? AND deleted_at = ?", treeId, deletedAt); err != nil {
+		return fmt.Errorf("failed to restore images of tree %d: %w", treeId, err)
+	}
+	if _, err := tx.Exec("UPDATE trees SET deleted_at = NULL WHERE ID = ?", treeId); err != nil {
+		return fmt.Errorf("failed to restore tree %d: %w", treeId, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tree restore: %w", err)
+	}
+
+	fmt.Printf("Restored tree of user %d with ID %d\n", userID, treeId)
+	return nil
+}
+
+// Restores the image. An image of a trashed tree can only come back with
+// its tree.
+func (s *MySQLStore) RestoreImageForUser(imageID int, userID int) error {
+	if err := authorize(s.conn, models.RoleEditor, fmt.Sprintf("trashed image %d", imageID),
+		`SELECT mm.role FROM images i JOIN trees t ON t.ID = i.tree_id
+		JOIN meadow_access mm ON mm.meadow_id = t.MeadowId AND mm.user_id = ?
+		WHERE i.id = ? AND i.deleted_at IS NOT NULL`, userID, imageID); err != nil {
+		return err
+	}
+
+	var treeId int
+	var treeDeletedAt sql.NullTime
+	err := s.conn.QueryRow(`SELECT i.tree_id, t.deleted_at FROM images i JOIN trees t ON t.ID = i.tree_id
+		WHERE i.id = ? AND i.deleted_at IS NOT NULL`,
+		imageID).Scan(&treeId, &treeDeletedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("trashed image %d: %w", imageID, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find image %d: %w", imageID, err)
+	}
+	if treeDeletedAt.Valid {
+		return fmt.Errorf("tree %d of image %d is in the trash: %w", treeId, imageID, ErrConflict)
+	}
+
+	result, err := s.conn.Exec("UPDATE images SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", imageID)
+	if isDuplicateEntry(err) {
+		return fmt.Errorf("tree %d already has an image with the same content as image %d: %w", treeId, imageID, ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore image %d: %w", imageID, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("trashed image %d: %w", imageID, ErrNotFound)
+	}
+
+	fmt.Printf("Restored image of user %d with ID %d\n", userID, imageID)
+	return nil
+}
+
+// Permanently deletes everything of all users that was trashed before the
+// given time. The report lists the files of the deleted images, which the
+// caller removes once the transaction committed.
+func (s *MySQLStore) PurgeTrash(before time.Time) (models.DeletionReport, error) {
+	report := models.DeletionReport{MeadowIds: []int{}, TreeIds: []int{}, ImageIds: []int{}, Files: []string{}}
+	before = before.UTC()
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return report, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	meadowIds, err := queryIDs(tx, "SELECT ID FROM meadows WHERE deleted_at < ? FOR UPDATE", before)
+	if err != nil {
+		return report, err
+	}
+
+	treeIds, err := queryIDs(tx, `SELECT t.ID FROM trees t JOIN meadows m ON m.ID = t.MeadowId
+		WHERE t.deleted_at < ? OR m.deleted_at < ? FOR UPDATE`, before, before)
+	if err != nil {
+		return report, err
+	}
+
+	imageIds, paths, err := queryImages(tx, `SELECT i.id, i.path, i.thumbnail_path, i.medium_path FROM images i
+		LEFT JOIN trees t ON t.ID = i.tree_id
+		LEFT JOIN meadows m ON m.ID = t.MeadowId
+		WHERE i.deleted_at < ? OR t.deleted_at < ? OR m.deleted_at < ? FOR UPDATE`, before, before, before)
+	if err != nil {
+		return report, err
+	}
+
+	if _, err := tx.Exec(`DELETE i FROM images i
+		LEFT JOIN trees t ON t.ID = i.tree_id
+		LEFT JOIN meadows m ON m.ID = t.MeadowId
+		WHERE i.deleted_at < ? OR t.deleted_at < ? OR m.deleted_at < ?`, before, before, before); err != nil {
+		return report, fmt.Errorf("failed to purge images: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE t FROM trees t JOIN meadows m ON m.ID = t.MeadowId
+		WHERE t.deleted_at < ? OR m.deleted_at < ?`, before, before); err != nil {
+		return report, fmt.Errorf("failed to purge trees: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM meadows WHERE deleted_at < ?", before); err != nil {
+		return report, fmt.Errorf("failed to purge meadows: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return report, fmt.Errorf("failed to commit trash purge: %w", err)
+	}
+
+	report.MeadowIds = meadowIds
+	report.TreeIds = treeIds
+	report.ImageIds = imageIds
+	report.Files = paths
+
+	return report, nil
+}
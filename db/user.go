@@ -0,0 +1,304 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+// Deletes the account together with the personal meadows only the user
+// owns, including their trees and images in the trash. The report lists the
+// files of the deleted images for the caller to remove.
+func (s *MySQLStore) DeleteUser(userID int) (models.DeletionReport, error) {
+	report := models.DeletionReport{MeadowIds: []int{}, TreeIds: []int{}, ImageIds: []int{}, Files: []string{}}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return report, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow("SELECT ID FROM users WHERE ID = ? FOR UPDATE", userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return report, fmt.Errorf("user %d: %w", userID, ErrNotFound)
+	}
+	if err != nil {
+		return report, fmt.Errorf("failed to lock user %d: %w", userID, err)
+	}
+
+	organizationIds, err := queryIDs(tx, `SELECT om.organization_id FROM organization_members om
+		WHERE om.user_id = ? AND om.role = ? AND NOT EXISTS (
+			SELECT 1 FROM organization_members other
+			WHERE other.organization_id = om.organization_id AND other.role = ? AND other.user_id <> om.user_id)
+		FOR UPDATE`, userID, models.RoleOwner, models.RoleOwner)
+	if err != nil {
+		return report, err
+	}
+	if len(organizationIds) > 0 {
+		return report, fmt.Errorf("user %d is the last owner of organization %d: %w", userID, organizationIds[0], ErrConflict)
+	}
+
+	meadowIds, err := queryIDs(tx, "SELECT m.ID FROM meadows m "+soleOwnerCondition+" FOR UPDATE", userID, models.RoleOwner, models.RoleOwner)
+	if err != nil {
+		return report, err
+	}
+
+	for _, meadowId := range meadowIds {
+		if err := purgeMeadow(tx, meadowId, &report); err != nil {
+			return report, err
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM users WHERE ID = ?", userID); err != nil {
+		return report, fmt.Errorf("failed to delete user %d: %w", userID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return report, fmt.Errorf("failed to commit user deletion: %w", err)
+	}
+
+	fmt.Printf("Deleted user %d with %d meadows, %d trees and %d images\n", userID, len(report.MeadowIds), len(report.TreeIds), len(report.ImageIds))
+	return report, nil
+}
+
+// Lists what DeleteUser deletes for good: the personal meadows only the user
+// owns with all their trees and images, including those in the trash
+func (s *MySQLStore) FindAccountMeadows(userID int) ([]models.AccountMeadow, error) {
+	accountMeadows := []models.AccountMeadow{}
+
+	rows, err := s.conn.Query(`SELECT m.ID, m.Location, m.Name, m.Size,
+		COALESCE((SELECT JSON_ARRAYAGG(t.ID) FROM trees t WHERE t.MeadowId = m.ID), JSON_ARRAY()),
+		m.Boundary, m.Grid, m.deleted_at FROM meadows m `+soleOwnerCondition+` ORDER BY m.ID`,
+		userID, models.RoleOwner, models.RoleOwner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query meadows of user %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		meadow := models.Meadow{Role: models.RoleOwner, Scope: models.NewMeadowScope(0, "")}
+		if err := rows.Scan(&meadow.ID, &meadow.Location, &meadow.Name, &meadow.Size, &meadow.TreeIds, &meadow.Boundary, &meadow.Grid, &meadow.DeletedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan meadow: %w", err)
+		}
+		meadow.Area = meadow.Boundary.Area()
+		accountMeadows = append(accountMeadows, models.AccountMeadow{Meadow: meadow})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read meadows of user %d: %w", userID, err)
+	}
+
+	for i := range accountMeadows {
+		meadowId := accountMeadows[i].Meadow.ID
+		if accountMeadows[i].Trees, err = s.queryAllTreesOfMeadow(meadowId); err != nil {
+			return nil, err
+		}
+		if accountMeadows[i].Images, err = s.queryAllImagesOfMeadow(meadowId); err != nil {
+			return nil, err
+		}
+	}
+	return accountMeadows, nil
+}
+
+// soleOwnerCondition joins the meadows aliased as m to the user's
+// memberships and keeps the personal meadows the user is the only owner of.
+// It takes the user ID and the owner role twice as arguments.
+const soleOwnerCondition = `JOIN meadow_members mm ON mm.meadow_id = m.ID
+	WHERE mm.user_id = ? AND mm.role = ? AND m.organization_id IS NULL AND NOT EXISTS (
+		SELECT 1 FROM meadow_members other
+		WHERE other.meadow_id = mm.meadow_id AND other.role = ? AND other.user_id <> mm.user_id)`
+
+// queryAllTreesOfMeadow lists the trees of the meadow, including those in
+// the trash
+func (s *MySQLStore) queryAllTreesOfMeadow(meadowId int) ([]models.Tree, error) {
+	trees := []models.Tree{}
+
+	rows, err := s.conn.Query("SELECT "+treeColumns+" FROM trees t "+treeConditionJoin+" WHERE t.MeadowId = ? ORDER BY t.ID", meadowId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trees of meadow %d: %w", meadowId, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		tree, err := scanTree(rows)
+		if err != nil {
+			return nil, err
+		}
+		trees = append(trees, tree)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read trees of meadow %d: %w", meadowId, err)
+	}
+	return trees, nil
+}
+
+// queryAllImagesOfMeadow lists the images of the meadow's trees, including
+// those in the trash
+func (s *MySQLStore) queryAllImagesOfMeadow(meadowId int) ([]models.Image, error) {
+	images := []models.Image{}
+
+	rows, err := s.conn.Query("SELECT "+imageColumns+" FROM images i JOIN trees t ON t.ID = i.tree_id WHERE t.MeadowId = ? ORDER BY i.id", meadowId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query images of meadow %d: %w", meadowId, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		img, err := scanImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read images of meadow %d: %w", meadowId, err)
+	}
+	return images, nil
+}
+
+func (s *MySQLStore) EmailExists(email string) (bool, error) {
+	var exists string
+	err := s.conn.QueryRow("SELECT email FROM users WHERE email = ?", email).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check email: %w", err)
+	}
+	return true, nil
+}
+
+func (s *MySQLStore) FindUserByEmail(email string) (models.User, error) {
+	user, err := scanUser(s.conn.QueryRow("SELECT "+userColumns+" FROM users WHERE email = ?", email))
+	if err == sql.ErrNoRows {
+		return user, fmt.Errorf("user with email %s: %w", email, ErrNotFound)
+	}
+	if err != nil {
+		return user, fmt.Errorf("failed to find user with email %s: %w", email, err)
+	}
+	return user, nil
+}
+
+func (s *MySQLStore) FindUserByID(userID int) (models.User, error) {
+	user, err := scanUser(s.conn.QueryRow("SELECT "+userColumns+" FROM users WHERE ID = ?", userID))
+	if err == sql.ErrNoRows {
+		return user, fmt.Errorf("user %d: %w", userID, ErrNotFound)
+	}
+	if err != nil {
+		return user, fmt.Errorf("failed to find user %d: %w", userID, err)
+	}
+	return user, nil
+}
+
+func (s *MySQLStore) FindUserByUsername(username string) (models.User, error) {
+	user, err := scanUser(s.conn.QueryRow("SELECT "+userColumns+" FROM users WHERE username = ?", username))
+	if err == sql.ErrNoRows {
+		return user, fmt.Errorf("user %s: %w", username, ErrNotFound)
+	}
+	if err != nil {
+		return user, fmt.Errorf("failed to find user %s: %w", username, err)
+	}
+	return user, nil
+}
+
+func (s *MySQLStore) InsertUser(user models.User) error {
+	_, err := s.conn.Exec(
+		"INSERT INTO users (username, password, email) VALUES (?, ?, ?)",
+		user.Username,
+		user.Password,
+		user.Email,
+	)
+	if isDuplicateEntry(err) {
+		return fmt.Errorf("user %s: %w", user.Username, ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+func (s *MySQLStore) UsernameExists(username string) (bool, error) {
+	var exists string
+	err := s.conn.QueryRow("SELECT username FROM users WHERE username = ?", username).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check username: %w", err)
+	}
+	return true, nil
+}
+
+// Marks the email address as verified, unless the account has changed its
+// address in the meantime
+func (s *MySQLStore) MarkEmailVerified(userID int, email string) error {
+	result, err := s.conn.Exec("UPDATE users SET email_verified_at = ? WHERE ID = ? AND email = ? AND email_verified_at IS NULL",
+		time.Now(), userID, email)
+	if err != nil {
+		return fmt.Errorf("failed to verify email of user %d: %w", userID, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("unverified email %s of user %d: %w", email, userID, ErrNotFound)
+	}
+
+	fmt.Printf("Verified email of user %d\n", userID)
+	return nil
+}
+
+// Replaces the password hash of the user
+func (s *MySQLStore) UpdatePassword(userID int, password string) error {
+	result, err := s.conn.Exec("UPDATE users SET password = ?, changed_at = ? WHERE ID = ?", password, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to update password of user %d: %w", userID, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("user %d: %w", userID, ErrNotFound)
+	}
+
+	fmt.Printf("Updated password of user %d\n", userID)
+	return nil
+}
+
+// Changes the username and email address of the user. A new address has
+// to be verified again.
+func (s *MySQLStore) UpdateUser(user models.User) error {
+	// MySQL assigns from left to right, so email_verified_at still sees the
+	// old address
+	result, err := s.conn.Exec(`UPDATE users SET username = ?, email_verified_at = IF(email = ?, email_verified_at, NULL),
+		email = ?, changed_at = ? WHERE ID = ?`, user.Username, user.Email, user.Email, time.Now(), user.ID)
+	if isDuplicateEntry(err) {
+		return fmt.Errorf("username or email of user %d already taken: %w", user.ID, ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update user %d: %w", user.ID, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("user %d: %w", user.ID, ErrNotFound)
+	}
+
+	fmt.Printf("Updated user %d\n", user.ID)
+	return nil
+}
+
+// userColumns are the columns scanUser expects, in order.
+const userColumns = "ID, username, password, email, email_verified_at, created_at, changed_at"
+
+func scanUser(row *sql.Row) (models.User, error) {
+	var user models.User
+	var verifiedAt sql.NullTime
+	if err := row.Scan(&user.ID, &user.Username, &user.Password, &user.Email, &verifiedAt, &user.CreatedAt, &user.ChangedAt); err != nil {
+		return models.User{}, err
+	}
+	if verifiedAt.Valid {
+		user.EmailVerifiedAt = &verifiedAt.Time
+	}
+	return user, nil
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"fmt"
+)
+
+// Counts the trees per type that are not linked to a variety yet, most
+// common types first. Types are grouped by the column's collation, which
+// ignores case.
+func (s *MySQLStore) CountUnlinkedTreeTypes() ([]TypeCount, error) {
+	rows, err := s.conn.Query("SELECT Type, COUNT(*) FROM trees WHERE variety_id IS NULL GROUP BY Type ORDER BY COUNT(*) DESC, Type")
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tree types: %w", err)
+	}
+	defer rows.Close()
+
+	counts := []TypeCount{}
+	for rows.Next() {
+		var count TypeCount
+		if err := rows.Scan(&count.Type, &count.Trees); err != nil {
+			return nil, fmt.Errorf("failed to scan tree type: %w", err)
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+// Links the unlinked trees of the given type to the variety and renames
+// their type to the variety's name. Returns the number of trees changed.
+func (s *MySQLStore) LinkTreeType(treeType string, varietyId int, name string) (int64, error) {
+	result, err := s.conn.Exec("UPDATE trees SET variety_id = ?, Type = ? WHERE Type = ? AND variety_id IS NULL",
+		varietyId, name, treeType)
+	if err != nil {
+		return 0, fmt.Errorf("failed to link tree type %q: %w", treeType, err)
+	}
+	linked, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read linked trees: %w", err)
+	}
+	return linked, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Johnhi19/TreeSpotter_backend/db"
+	"github.com/Johnhi19/TreeSpotter_backend/handlers"
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+// gridToCoordinates converts the grid position given by the x and y query
+// parameters to coordinates, using the meadow's grid origin.
+func (s *server) gridToCoordinates(c *gin.Context) {
+	grid, ok := s.meadowGrid(c)
+	if !ok {
+		return
+	}
+
+	x, errX := strconv.Atoi(c.Query("x"))
+	y, errY := strconv.Atoi(c.Query("y"))
+	if errX != nil || errY != nil {
+		handlers.RespondInvalidInput(c, "x and y must be whole numbers")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, grid.ToLatLon(models.Position{X: x, Y: y}))
+}
+
+// coordinatesToGrid converts the coordinates given by the lat and lon query
+// parameters to the nearest grid position of the meadow.
+func (s *server) coordinatesToGrid(c *gin.Context) {
+	grid, ok := s.meadowGrid(c)
+	if !ok {
+		return
+	}
+
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	lon, errLon := strconv.ParseFloat(c.Query("lon"), 64)
+	if errLat != nil || errLon != nil {
+		handlers.RespondInvalidInput(c, "lat and lon must be numbers")
+		return
+	}
+	point := models.LatLon{Lat: lat, Lon: lon}
+	if err := point.Validate(); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, grid.ToPosition(point))
+}
+
+// meadowGrid loads the grid origin of the meadow in the URL. It has already
+// responded when ok is false.
+func (s *server) meadowGrid(c *gin.Context) (models.GridOrigin, bool) {
+	userID := c.GetInt("user_id")
+
+	intMeadowID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return models.GridOrigin{}, false
+	}
+
+	meadow, err := s.meadows.FindOneMeadowByIdForUser(intMeadowID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return models.GridOrigin{}, false
+	}
+	if meadow.Grid == nil {
+		handlers.RespondError(c, fmt.Errorf("meadow %d has no grid origin: %w", intMeadowID, db.ErrConflict))
+		return models.GridOrigin{}, false
+	}
+	return *meadow.Grid, true
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Johnhi19/TreeSpotter_backend/db"
+	"github.com/Johnhi19/TreeSpotter_backend/handlers"
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *server) exportMeadowGeoJSON(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intMeadowID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	meadow, err := s.meadows.FindOneMeadowByIdForUser(intMeadowID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+	trees, err := s.trees.FindAllTreesForMeadow(intMeadowID, userID, db.TreeFilter{})
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	fc, err := models.MeadowFeatureCollection(meadow, trees)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/geo+json")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=meadow-%d.geojson", intMeadowID))
+	c.IndentedJSON(http.StatusOK, fc)
+}
+
+// importMeadowGeoJSON creates a meadow with its trees from a feature
+// collection as produced by exportMeadowGeoJSON. Nothing is created unless
+// every feature is valid.
+func (s *server) importMeadowGeoJSON(c *gin.Context) {
+	var fc models.FeatureCollection
+
+	userID := c.GetInt("user_id")
+
+	if err := c.ShouldBindJSON(&fc); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	meadow, trees, featureErrors := models.ParseMeadowFeatureCollection(fc, s.varieties.ResolveTree)
+	if len(featureErrors) > 0 {
+		handlers.RespondValidationErrors(c, fmt.Sprintf("%d invalid features", len(featureErrors)), featureErrors)
+		return
+	}
+
+	meadow.Scope = models.NewMeadowScope(c.GetInt("organization_id"), "")
+
+	meadowID, treeIDs, err := s.meadows.ImportMeadowForUser(meadow, trees, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Meadow imported successfully",
+		"id":      meadowID,
+		"treeIds": treeIDs,
+	})
+}
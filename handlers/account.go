@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/db"
+	"github.com/Johnhi19/TreeSpotter_backend/mail"
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Purposes of the tokens sent by mail. A token only works for its purpose.
+const (
+	purposePasswordReset     = "password_reset"
+	purposeEmailVerification = "email_verification"
+)
+
+const (
+	passwordResetLifetime     = time.Hour
+	emailVerificationLifetime = 48 * time.Hour
+)
+
+// errInvalidMailToken is returned for mail tokens that are malformed,
+// expired, meant for something else or already used.
+var errInvalidMailToken = errors.New("invalid or expired token")
+
+// ----------------------
+// Forgot password
+// ----------------------
+
+// ForgotPassword mails a password reset link to the address, if it belongs
+// to an account. The response is the same either way, so that it does not
+// tell which addresses are registered.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var body struct {
+		Email string `json:"email" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		RespondInvalidInput(c, "Invalid input")
+		return
+	}
+
+	user, err := h.users.FindUserByEmail(body.Email)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		RespondError(c, err)
+		return
+	}
+	if err == nil {
+		if err := h.sendPasswordResetMail(user); err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+		}
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the address is registered, a reset link has been sent"})
+}
+
+// ----------------------
+// Reset password
+// ----------------------
+
+// ResetPassword sets a new password with a token from a reset mail. It logs
+// the user out everywhere.
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var body struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		RespondInvalidInput(c, "Invalid input")
+		return
+	}
+
+	user, err := h.parseMailToken(body.Token, purposePasswordReset)
+	if errors.Is(err, errInvalidMailToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_TOKEN", "error": "Invalid or expired token"})
+		return
+	}
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(body.Password), bcrypt.DefaultCost)
+	if err != nil {
+		RespondInvalidInput(c, "Invalid password")
+		return
+	}
+	if err := h.users.UpdatePassword(user.ID, string(hashed)); err != nil {
+		RespondError(c, err)
+		return
+	}
+	if err := h.tokens.RevokeAllTokensForUser(user.ID); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// ----------------------
+// Email verification
+// ----------------------
+
+// VerifyEmail confirms the user's email address with a token from a
+// verification mail.
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var body struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		RespondInvalidInput(c, "Invalid input")
+		return
+	}
+
+	user, err := h.parseMailToken(body.Token, purposeEmailVerification)
+	if err == nil && user.EmailVerifiedAt != nil {
+		err = errInvalidMailToken
+	}
+	if err == nil {
+		err = h.users.MarkEmailVerified(user.ID, user.Email)
+	}
+	if errors.Is(err, errInvalidMailToken) || errors.Is(err, db.ErrNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_TOKEN", "error": "Invalid or expired token"})
+		return
+	}
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerification mails another verification link to the address, if it
+// belongs to an account that has not verified it yet. Like ForgotPassword it
+// does not tell which addresses are registered.
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var body struct {
+		Email string `json:"email" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		RespondInvalidInput(c, "Invalid input")
+		return
+	}
+
+	user, err := h.users.FindUserByEmail(body.Email)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		RespondError(c, err)
+		return
+	}
+	if err == nil && user.EmailVerifiedAt == nil {
+		if err := h.sendVerificationMail(user); err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+		}
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the address awaits verification, a new link has been sent"})
+}
+
+func (h *AuthHandler) sendPasswordResetMail(user models.User) error {
+	token, err := signMailToken(user, purposePasswordReset, passwordResetLifetime)
+	if err != nil {
+		return err
+	}
+
+	return h.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your TreeSpotter password",
+		Body: fmt.Sprintf("Hello %s,\n\nopen the following link within the next hour to choose a new password:\n\n%s\n\n"+
+			"If you did not ask for this, you can ignore this mail and keep your current password.\n",
+			user.Username, h.link("reset-password", token)),
+	})
+}
+
+func (h *AuthHandler) sendVerificationMail(user models.User) error {
+	token, err := signMailToken(user, purposeEmailVerification, emailVerificationLifetime)
+	if err != nil {
+		return err
+	}
+
+	return h.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your TreeSpotter email address",
+		Body: fmt.Sprintf("Hello %s,\n\nplease confirm your email address by opening the following link within the next two days:\n\n%s\n",
+			user.Username, h.link("verify-email", token)),
+	})
+}
+
+// link builds the app link that hands the token to the given page.
+func (h *AuthHandler) link(page string, token string) string {
+	return fmt.Sprintf("%s/%s?token=%s", strings.TrimRight(h.options.AppURL, "/"), page, url.QueryEscape(token))
+}
+
+// signMailToken creates a token for the purpose that expires after the
+// lifetime. It carries a fingerprint of what the purpose changes, so that it
+// stops working once it has been used.
+func signMailToken(user models.User, purpose string, lifetime time.Duration) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": user.ID,
+		"purpose": purpose,
+		"fp":      fingerprint(user, purpose),
+		"iat":     now.Unix(),
+		"exp":     now.Add(lifetime).Unix(),
+	})
+
+	signed, err := token.SignedString(jwtSecret)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign %s token: %w", purpose, err)
+	}
+	return signed, nil
+}
+
+// parseMailToken checks a token created by signMailToken and returns its
+// user. It fails with errInvalidMailToken if the token is not valid for the
+// purpose.
+func (h *AuthHandler) parseMailToken(tokenString string, purpose string) (models.User, error) {
+	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return models.User{}, errInvalidMailToken
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	userID, _ := claims["user_id"].(float64)
+	if claims["purpose"] != purpose || userID == 0 {
+		return models.User{}, errInvalidMailToken
+	}
+
+	user, err := h.users.FindUserByID(int(userID))
+	if errors.Is(err, db.ErrNotFound) {
+		return models.User{}, errInvalidMailToken
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+	if claims["fp"] != fingerprint(user, purpose) {
+		return models.User{}, errInvalidMailToken
+	}
+	return user, nil
+}
+
+// fingerprint hashes the parts of the account a mail token is bound to: a
+// reset token dies with the password it replaces, a verification token with
+// the address it verifies.
+func fingerprint(user models.User, purpose string) string {
+	state := purpose + "\x00" + user.Email
+	if purpose == purposePasswordReset {
+		state += "\x00" + user.Password
+	}
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/db"
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+func TestParseMailToken(t *testing.T) {
+	store := db.NewMemoryStore()
+	if err := store.InsertUser(models.User{Username: "alice", Password: "hash", Email: "alice@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	user, err := store.FindUserByUsername("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewAuthHandler(store, store, nil, AuthOptions{})
+
+	sign := func(user models.User, purpose string, lifetime time.Duration) string {
+		token, err := signMailToken(user, purpose, lifetime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	changed := user
+	changed.Password = "other hash"
+	moved := user
+	moved.Email = "alice@example.org"
+	unknown := user
+	unknown.ID = 9999
+
+	tests := []struct {
+		name    string
+		token   string
+		purpose string
+		valid   bool
+	}{
+		{"reset", sign(user, purposePasswordReset, time.Hour), purposePasswordReset, true},
+		{"verification", sign(user, purposeEmailVerification, time.Hour), purposeEmailVerification, true},
+		{"reset token for verification", sign(user, purposePasswordReset, time.Hour), purposeEmailVerification, false},
+		{"verification token for reset", sign(user, purposeEmailVerification, time.Hour), purposePasswordReset, false},
+		{"expired", sign(user, purposePasswordReset, -time.Minute), purposePasswordReset, false},
+		{"for another password", sign(changed, purposePasswordReset, time.Hour), purposePasswordReset, false},
+		{"for another address", sign(moved, purposeEmailVerification, time.Hour), purposeEmailVerification, false},
+		{"password does not matter for verification", sign(changed, purposeEmailVerification, time.Hour), purposeEmailVerification, true},
+		{"unknown user", sign(unknown, purposePasswordReset, time.Hour), purposePasswordReset, false},
+		{"malformed", "not.a.token", purposePasswordReset, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := h.parseMailToken(tt.token, tt.purpose)
+			if tt.valid && (err != nil || got.ID != user.ID) {
+				t.Errorf("parseMailToken() = %d, %v, want user %d", got.ID, err, user.ID)
+			}
+			if !tt.valid && !errors.Is(err, errInvalidMailToken) {
+				t.Errorf("parseMailToken() = %d, %v, want errInvalidMailToken", got.ID, err)
+			}
+		})
+	}
+}
@@ -1,12 +1,18 @@
 package handlers
 
 import (
-	"database/sql"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"time"
 
-	database "github.com/Johnhi19/TreeSpotter_backend/db"
+	"github.com/Johnhi19/TreeSpotter_backend/db"
+	"github.com/Johnhi19/TreeSpotter_backend/mail"
 	"github.com/Johnhi19/TreeSpotter_backend/models"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -15,42 +21,73 @@ import (
 
 var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
 
+// TokenLifetimes sets how long access tokens and refresh tokens are valid.
+// A refresh token's session ends once it has not been refreshed for the
+// refresh lifetime.
+type TokenLifetimes struct {
+	Access  time.Duration
+	Refresh time.Duration
+}
+
+// DefaultTokenLifetimes keeps access tokens short-lived and sessions alive
+// for a month of inactivity.
+var DefaultTokenLifetimes = TokenLifetimes{
+	Access:  15 * time.Minute,
+	Refresh: 30 * 24 * time.Hour,
+}
+
+// AuthOptions configure an AuthHandler.
+type AuthOptions struct {
+	Lifetimes TokenLifetimes
+	// AppURL is the address of the app the links in mails lead to.
+	AppURL string
+	// RequireVerifiedEmail refuses to log in users who have not verified
+	// their email address yet.
+	RequireVerifiedEmail bool
+}
+
+// AuthHandler serves registration, login, sessions and the password and
+// email flows on top of a UserStore and a TokenStore.
+type AuthHandler struct {
+	users   db.UserStore
+	tokens  db.TokenStore
+	mailer  mail.Mailer
+	options AuthOptions
+}
+
+func NewAuthHandler(users db.UserStore, tokens db.TokenStore, mailer mail.Mailer, options AuthOptions) *AuthHandler {
+	return &AuthHandler{users: users, tokens: tokens, mailer: mailer, options: options}
+}
+
 // ----------------------
 // Register
 // ----------------------
-func Register(c *gin.Context) {
+func (h *AuthHandler) Register(c *gin.Context) {
 	var user models.User
 
 	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
-		return
-	}
-
-	// Ensure DB is connected
-	if database.DB == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": "DATABASE_ISSUE", "error": "database not initialized"})
+		RespondInvalidInput(c, "Invalid input")
 		return
 	}
 
 	// Check if username exists
-	var exists string
-	err := database.DB.QueryRow("SELECT username FROM users WHERE username = ?", user.Username).Scan(&exists)
-	if err != sql.ErrNoRows && err != nil {
+	exists, err := h.users.UsernameExists(user.Username)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": "DATABASE_ISSUE", "error": "Database error when checking username"})
 		return
 	}
-	if exists != "" {
+	if exists {
 		c.JSON(http.StatusBadRequest, gin.H{"code": "USERNAME_TAKEN", "error": "Username already taken"})
 		return
 	}
 
 	// Check if email exists
-	err = database.DB.QueryRow("SELECT email FROM users WHERE email = ?", user.Email).Scan(&exists)
-	if err != sql.ErrNoRows && err != nil {
+	exists, err = h.users.EmailExists(user.Email)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": "DATABASE_ISSUE", "error": "Database error when checking email"})
 		return
 	}
-	if exists != "" {
+	if exists {
 		c.JSON(http.StatusBadRequest, gin.H{"code": "EMAIL_TAKEN", "error": "Email already registered"})
 		return
 	}
@@ -59,59 +96,151 @@ func Register(c *gin.Context) {
 	hashed, _ := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 
 	// Insert into DB
-	_, err = database.DB.Exec(
-		"INSERT INTO users (username, password, email) VALUES (?, ?, ?)",
-		user.Username,
-		string(hashed),
-		user.Email,
-	)
-	if err != nil {
+	user.Password = string(hashed)
+	if err := h.users.InsertUser(user); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": "DATABASE_ISSUE", "error": "Failed to create user"})
 		return
 	}
 
+	// A failed mail does not undo the registration, the user can ask for
+	// another one
+	if stored, err := h.users.FindUserByUsername(user.Username); err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+	} else if err := h.sendVerificationMail(stored); err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "User registered"})
 }
 
 // ----------------------
 // Login
 // ----------------------
-func Login(c *gin.Context) {
+func (h *AuthHandler) Login(c *gin.Context) {
 	var user models.User
-	var stored models.User
 
 	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
-		return
-	}
-
-	// Ensure DB is connected
-	if database.DB == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": "DATABASE_ISSUE", "error": "database not initialized"})
+		RespondInvalidInput(c, "Invalid input")
 		return
 	}
 
 	// Get user by username
-	err := database.DB.QueryRow(
-		"SELECT ID, username, password FROM users WHERE username = ?",
-		user.Username,
-	).Scan(&stored.ID, &stored.Username, &stored.Password)
-
-	if err == sql.ErrNoRows || err != nil {
+	stored, err := h.users.FindUserByUsername(user.Username)
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"code": "INVALID_CREDENTIALS", "error": "Invalid username or password"})
 		return
 	}
 
 	// Compare password
-	if bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte(user.Password)) != nil {
+	if !CheckPassword(stored, user.Password) {
 		c.JSON(http.StatusUnauthorized, gin.H{"code": "INVALID_CREDENTIALS", "error": "Invalid username or password"})
 		return
 	}
 
-	// Generate JWT
+	if h.options.RequireVerifiedEmail && stored.EmailVerifiedAt == nil {
+		c.JSON(http.StatusForbidden, gin.H{"code": "EMAIL_NOT_VERIFIED", "error": "Email address has not been verified yet"})
+		return
+	}
+
+	h.startSession(c, stored.ID)
+}
+
+// ----------------------
+// Refresh
+// ----------------------
+
+// Refresh trades a refresh token for a new access token and a new refresh
+// token. Every refresh token works once: presenting one again revokes the
+// whole session, as it means the token was stolen.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var body struct {
+		RefreshToken string `json:"refreshToken" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		RespondInvalidInput(c, "Invalid input")
+		return
+	}
+
+	next, plain, err := h.newRefreshToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": "UNKNOWN_ERROR", "error": "Token creation failed"})
+		return
+	}
+	next, err = h.tokens.RotateRefreshToken(hashToken(body.RefreshToken), next)
+	if errors.Is(err, db.ErrNotFound) || errors.Is(err, db.ErrForbidden) {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": "INVALID_TOKEN", "error": "Invalid or expired refresh token"})
+		return
+	}
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	h.respondTokens(c, next, plain)
+}
+
+// ----------------------
+// Logout
+// ----------------------
+
+// Logout revokes the session the access token belongs to. Its access
+// tokens stop working right away.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	if err := h.tokens.RevokeTokenFamilyForUser(c.GetString("session_id"), userID); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll revokes every session of the user, on all devices.
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	if err := h.tokens.RevokeAllTokensForUser(userID); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions successfully"})
+}
+
+// startSession logs the user in with a new token family and writes its
+// first tokens.
+func (h *AuthHandler) startSession(c *gin.Context, userID int) {
+	familyId, err := randomToken(16, hex.EncodeToString)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": "UNKNOWN_ERROR", "error": "Token creation failed"})
+		return
+	}
+	refresh, plain, err := h.newRefreshToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": "UNKNOWN_ERROR", "error": "Token creation failed"})
+		return
+	}
+	refresh.FamilyId = familyId
+	refresh.UserId = userID
+	if err := h.tokens.InsertRefreshToken(refresh); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	h.respondTokens(c, refresh, plain)
+}
+
+// respondTokens signs an access token for the refresh token's session and
+// writes both tokens.
+func (h *AuthHandler) respondTokens(c *gin.Context, refresh models.RefreshToken, plain string) {
+	now := time.Now()
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": stored.ID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(), // token expires after a day
+		"user_id": refresh.UserId,
+		"sid":     refresh.FamilyId,
+		"iat":     now.Unix(),
+		"exp":     now.Add(h.options.Lifetimes.Access).Unix(),
 	})
 
 	tokenString, err := token.SignedString(jwtSecret)
@@ -120,5 +249,35 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": tokenString})
+	c.JSON(http.StatusOK, gin.H{
+		"token":        tokenString,
+		"refreshToken": plain,
+		"expiresIn":    int(h.options.Lifetimes.Access.Seconds()),
+	})
+}
+
+// newRefreshToken creates a random refresh token. Only its hash is stored,
+// the plain token is handed to the client.
+func (h *AuthHandler) newRefreshToken() (models.RefreshToken, string, error) {
+	plain, err := randomToken(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return models.RefreshToken{}, "", err
+	}
+	return models.RefreshToken{
+		Hash:      hashToken(plain),
+		ExpiresAt: time.Now().Add(h.options.Lifetimes.Refresh),
+	}, plain, nil
+}
+
+func randomToken(size int, encode func([]byte) string) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encode(b), nil
+}
+
+func hashToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
 }
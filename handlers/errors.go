@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Johnhi19/TreeSpotter_backend/db"
+	"github.com/gin-gonic/gin"
+)
+
+// RespondError writes err as a {code, error} JSON response with the status
+// and code of ErrorStatus.
+func RespondError(c *gin.Context, err error) {
+	status, code, message := ErrorStatus(err)
+	respond(c, status, code, message)
+}
+
+// ErrorStatus returns the HTTP status, code and message err is answered
+// with. They follow the UploadError or db sentinel error that err wraps;
+// anything else is logged and reported as an internal error without
+// leaking its details.
+func ErrorStatus(err error) (int, string, string) {
+	var uploadErr *UploadError
+	switch {
+	case errors.As(err, &uploadErr):
+		return uploadErr.Status, uploadErr.Code, uploadErr.Message
+	case errors.Is(err, db.ErrNotFound):
+		return http.StatusNotFound, "NOT_FOUND", err.Error()
+	case errors.Is(err, db.ErrConflict):
+		return http.StatusConflict, "CONFLICT", err.Error()
+	case errors.Is(err, db.ErrForbidden):
+		return http.StatusForbidden, "FORBIDDEN", err.Error()
+	case errors.Is(err, db.ErrInvalidInput):
+		return http.StatusBadRequest, "INVALID_INPUT", err.Error()
+	default:
+		fmt.Printf("ERROR: %v\n", err)
+		return http.StatusInternalServerError, "DATABASE_ISSUE", "Internal server error"
+	}
+}
+
+// RespondInvalidInput writes a 400 response for a malformed request.
+func RespondInvalidInput(c *gin.Context, message string) {
+	respond(c, http.StatusBadRequest, "INVALID_INPUT", message)
+}
+
+// RespondValidationErrors writes a 400 response for a bulk request, listing
+// what is wrong with each invalid item in details.
+func RespondValidationErrors(c *gin.Context, message string, details any) {
+	c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": "INVALID_INPUT", "error": message, "details": details})
+}
+
+func respond(c *gin.Context, status int, code string, message string) {
+	c.AbortWithStatusJSON(status, gin.H{"code": code, "error": message})
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Johnhi19/TreeSpotter_backend/db"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		code    string
+		message string
+	}{
+		{"not found", fmt.Errorf("tree 3: %w", db.ErrNotFound), http.StatusNotFound, "NOT_FOUND", "tree 3: not found"},
+		{"forbidden", fmt.Errorf("meadow 1 needs the editor role: %w", db.ErrForbidden), http.StatusForbidden, "FORBIDDEN", ""},
+		{"conflict", fmt.Errorf("already a member: %w", db.ErrConflict), http.StatusConflict, "CONFLICT", ""},
+		{"invalid input", fmt.Errorf("bad day: %w", db.ErrInvalidInput), http.StatusBadRequest, "INVALID_INPUT", ""},
+		{"wrapped twice", fmt.Errorf("failed to delete: %w", fmt.Errorf("tree 3: %w", db.ErrNotFound)), http.StatusNotFound, "NOT_FOUND", ""},
+		{"upload", fmt.Errorf("x.jpg: %w", &UploadError{Status: http.StatusRequestEntityTooLarge, Code: "FILE_TOO_LARGE", Message: "too large"}),
+			http.StatusRequestEntityTooLarge, "FILE_TOO_LARGE", "too large"},
+		{"anything else", errors.New("connection refused to 10.0.0.3"), http.StatusInternalServerError, "DATABASE_ISSUE", "Internal server error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, code, message := ErrorStatus(tt.err)
+			if status != tt.status || code != tt.code || (tt.message != "" && message != tt.message) {
+				t.Errorf("ErrorStatus() = %d %s %q, want %d %s %q", status, code, message, tt.status, tt.code, tt.message)
+			}
+		})
+	}
+}
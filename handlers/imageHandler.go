@@ -1,90 +1,436 @@
 package handlers
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
-	"path/filepath"
+	"path"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/Johnhi19/TreeSpotter_backend/imaging"
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+	"github.com/Johnhi19/TreeSpotter_backend/storage"
+	"github.com/gin-gonic/gin"
 )
 
-// set the upload path inside the container
-var uploadPath = "./uploads"
+// UploadPrefix is the part of the blob key in front of the file name of
+// uploaded images. Images are served under "/" followed by their key.
+const UploadPrefix = "uploads/"
 
-func UploadImageHandler(w http.ResponseWriter, r *http.Request) *os.File {
-	// Limit file size to 10MB. This line saves you from those accidental 100MB uploads!
-	r.ParseMultipartForm(10 << 20)
+// imageVariants are the resized JPEG copies stored next to each upload,
+// from the largest to the smallest
+var imageVariants = []struct {
+	name    string
+	maxSize int
+}{
+	{models.VariantMedium, 1280},
+	{models.VariantThumbnail, 256},
+}
+
+// Limits of upload requests
+const (
+	// MaxImageSize is the largest file, in bytes, accepted per image
+	MaxImageSize = 10 << 20
+	// MaxImagesPerUpload is how many files one request may upload
+	MaxImagesPerUpload = 20
+	// maxDescriptionSize is the longest description, in bytes
+	maxDescriptionSize = 64 << 10
+	// exifHeadSize is how much of a JPEG or PNG is held in memory while it
+	// is streamed, to read and strip its EXIF data
+	exifHeadSize = 256 << 10
+)
+
+// UploadOptions control how uploaded images are stored.
+type UploadOptions struct {
+	// StripGPS removes the location from the EXIF data of stored files. It
+	// is still read into the image's metadata.
+	StripGPS bool
+}
+
+// UploadError rejects a single file of an upload. RespondError answers it
+// with its status and code.
+type UploadError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *UploadError) Error() string {
+	return e.Message
+}
+
+// ImageUpload is an uploaded image that was read and checked, but not
+// stored yet. Its content waits in a temporary file, which Close removes.
+type ImageUpload struct {
+	// FileName is the name the client sent the file with
+	FileName string
+	// Image is dated and described by the EXIF data and carries the
+	// SHA-256 of the file as it was uploaded and as it will be stored
+	Image models.Image
+	// Err is set if the file was rejected, the other fields are then unset
+	Err error
+	// Existing is the image the tree already has with the same upload
+	// hash. Such an upload keeps no temporary file.
+	Existing *models.Image
+
+	file        *os.File
+	size        int64
+	ext         string
+	contentType string
+}
+
+// Close removes the temporary file of the upload.
+func (u ImageUpload) Close() {
+	if u.file == nil {
+		return
+	}
+	u.file.Close()
+	if err := os.Remove(u.file.Name()); err != nil {
+		fmt.Printf("Warning: failed to remove temporary file %s: %v\n", u.file.Name(), err)
+	}
+}
+
+// FindExisting looks up the image the tree already has with the given
+// upload hash.
+type FindExisting func(uploadSHA256 string) (models.Image, bool, error)
 
-	// Retrieve the file from form data
-	file, handler, err := r.FormFile("treeImage")
+// ReadImageUploads streams the files of the form's treeImage fields into
+// temporary files and returns them in the order they were sent, together
+// with the form's description. Each file is looked up with existing as soon
+// as it is hashed, and files the tree already has are returned with
+// Existing set instead of being kept. Files that cannot be stored are
+// returned with Err set. It returns false after writing an error response
+// if the request as a whole is unusable.
+func ReadImageUploads(c *gin.Context, options UploadOptions, existing FindExisting) (string, []ImageUpload, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxImagesPerUpload*MaxImageSize+maxDescriptionSize+1<<20)
+	reader, err := c.Request.MultipartReader()
 	if err != nil {
+		RespondInvalidInput(c, "Expected a multipart form")
+		return "", nil, false
+	}
+
+	var description string
+	var uploads []ImageUpload
+	fail := func(status int, code string, message string) (string, []ImageUpload, bool) {
+		for _, upload := range uploads {
+			upload.Close()
+		}
+		respond(c, status, code, message)
+		return "", nil, false
+	}
+
+	for {
+		part, err := reader.NextPart()
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return fail(http.StatusRequestEntityTooLarge, "IMAGE_TOO_LARGE", "Request is too large")
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(http.StatusBadRequest, "INVALID_INPUT", "Invalid multipart form")
+		}
+
+		switch part.FormName() {
+		case "description":
+			value, err := io.ReadAll(io.LimitReader(part, maxDescriptionSize+1))
+			if err != nil {
+				return fail(http.StatusBadRequest, "INVALID_INPUT", "Invalid multipart form")
+			}
+			if len(value) > maxDescriptionSize {
+				return fail(http.StatusBadRequest, "INVALID_INPUT", "Description is too long")
+			}
+			description = string(value)
+		case "treeImage":
+			if len(uploads) == MaxImagesPerUpload {
+				return fail(http.StatusBadRequest, "INVALID_INPUT", fmt.Sprintf("At most %d images can be uploaded at once", MaxImagesPerUpload))
+			}
+			uploads = append(uploads, readImagePart(part, options, existing))
+		}
+		part.Close()
+	}
+
+	if len(uploads) == 0 {
 		fmt.Println("Error Retrieving the File")
-		http.Error(w, "Error retrieving the file", http.StatusBadRequest)
-		return nil
+		RespondInvalidInput(c, "Error retrieving the file")
+		return "", nil, false
 	}
-	defer file.Close()
+	return description, uploads, true
+}
 
-	// Read the file into a byte slice to validate its type
-	fileBytes, err := io.ReadAll(file)
-	if err != nil {
-		fmt.Println("Error reading file")
-		http.Error(w, "Invalid file", http.StatusBadRequest)
-		return nil
+// readImagePart checks the file in part and copies it to a temporary file
+// while hashing it as uploaded and as stored. JPEG and PNG files are streamed, WebP and HEIC files
+// have to be decoded as a whole to be converted to JPEG, which is skipped
+// for files the tree already has.
+func readImagePart(part *multipart.Part, options UploadOptions, existing FindExisting) ImageUpload {
+	upload := ImageUpload{FileName: part.FileName()}
+	tooLarge := &UploadError{http.StatusRequestEntityTooLarge, "IMAGE_TOO_LARGE", fmt.Sprintf("Image is larger than %d MB", MaxImageSize>>20)}
+	unreadable := &UploadError{http.StatusBadRequest, "INVALID_INPUT", "Invalid file"}
+
+	// One byte more than allowed tells files that are too large
+	reader := bufio.NewReaderSize(io.LimitReader(part, MaxImageSize+1), exifHeadSize)
+	head, err := reader.Peek(exifHeadSize)
+	if err != nil && err != io.EOF {
+		fmt.Println("Error reading file:", err)
+		upload.Err = unreadable
+		return upload
+	}
+
+	// Uploads are deduplicated on the hash of what was uploaded, which does
+	// not change with GPS stripping or the conversion to JPEG. known looks
+	// the hash up and reports whether the upload is done with.
+	uploadHash := sha256.New()
+	known := func() bool {
+		upload.Image.UploadSHA256 = hex.EncodeToString(uploadHash.Sum(nil))
+		image, found, err := existing(upload.Image.UploadSHA256)
+		if err != nil {
+			upload.Err = err
+			return true
+		}
+		if found {
+			upload.Existing = &image
+		}
+		return found
 	}
+	var content io.Reader
+	switch imaging.DetectFormat(head) {
+	case imaging.FormatJPEG, imaging.FormatPNG:
+		head = bytes.Clone(head)
+		reader.Discard(len(head))
+		uploadHash.Write(head)
+
+		info, ok := imaging.ReadExif(head)
+		describeImage(&upload.Image, info, ok)
+		if options.StripGPS {
+			head = imaging.StripGPS(head)
+		}
 
-	if !isValidFileType(fileBytes) {
+		content = io.MultiReader(bytes.NewReader(head), io.TeeReader(reader, uploadHash))
+		upload.contentType = http.DetectContentType(head)
+		upload.ext = ".png"
+		if upload.contentType == "image/jpeg" {
+			upload.ext = ".jpg"
+		}
+	case imaging.FormatWebP, imaging.FormatHEIC:
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			fmt.Println("Error reading file:", err)
+			upload.Err = unreadable
+			return upload
+		}
+		if len(data) > MaxImageSize {
+			upload.Err = tooLarge
+			return upload
+		}
+		uploadHash.Write(data)
+		if known() {
+			return upload
+		}
+
+		// The converted image is upright and carries no EXIF data
+		info, ok := imaging.ReadExif(data)
+		info.Orientation = 1
+		describeImage(&upload.Image, info, ok)
+
+		converted, err := imaging.ToJPEG(data)
+		if err != nil {
+			upload.Err = imageError(err)
+			return upload
+		}
+		content = bytes.NewReader(converted)
+		upload.contentType = "image/jpeg"
+		upload.ext = ".jpg"
+	default:
 		fmt.Println("Invalid file type")
-		http.Error(w, "Invalid file type", http.StatusUnsupportedMediaType)
-		return nil
+		upload.Err = &UploadError{http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE", "Invalid file type"}
+		return upload
+	}
+
+	file, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		fmt.Println("Error creating temporary file:", err)
+		upload.Err = &UploadError{http.StatusInternalServerError, "FILE_ISSUE", "Error saving the file"}
+		return upload
+	}
+	upload.file = file
+
+	storedHash := sha256.New()
+	upload.size, err = io.Copy(io.MultiWriter(file, storedHash), content)
+	if err != nil || upload.size > MaxImageSize {
+		upload.Close()
+		upload.file = nil
+		upload.Err = tooLarge
+		if err != nil {
+			fmt.Println("Error reading file:", err)
+			upload.Err = unreadable
+		}
+		return upload
 	}
+	upload.Image.SHA256 = hex.EncodeToString(storedHash.Sum(nil))
 
-	// Build timestamped filename while preserving extension
-	ext := filepath.Ext(handler.Filename)
-	base := time.Now().UnixNano()
-	newName := fmt.Sprintf("%d%s", base, ext)
+	// JPEG and PNG files are only hashed once they were streamed
+	if upload.Image.UploadSHA256 == "" && known() {
+		upload.Close()
+		upload.file = nil
+	}
+	return upload
+}
+
+// describeImage dates image and fills in its metadata from the EXIF data
+func describeImage(image *models.Image, info imaging.Exif, ok bool) {
+	if !ok {
+		return
+	}
+	image.Datetime = info.TakenAt
+	image.Metadata = &models.ImageMetadata{CameraModel: info.CameraModel, Orientation: info.Orientation}
+	if info.HasLocation {
+		image.Metadata.Coordinates = &models.LatLon{Lat: info.Latitude, Lon: info.Longitude}
+	}
+}
 
-	// Now let’s save it locally
-	dst, err := createFile(newName)
+// imageError turns an error decoding an image into an UploadError
+func imageError(err error) error {
+	if errors.Is(err, imaging.ErrTooLarge) {
+		return &UploadError{http.StatusRequestEntityTooLarge, "IMAGE_TOO_LARGE", "Image has too many pixels"}
+	}
+	fmt.Println("Error decoding image:", err)
+	return &UploadError{http.StatusBadRequest, "INVALID_INPUT", "Invalid image"}
+}
+
+// StoreImageUpload stores the upload together with its resized variants and
+// returns its image with the blob keys of its files as paths. Errors are
+// UploadErrors.
+func StoreImageUpload(blobs storage.BlobStore, upload ImageUpload) (models.Image, error) {
+	image := upload.Image
+	saveErr := &UploadError{http.StatusInternalServerError, "FILE_ISSUE", "Error saving the file"}
+
+	orientation := 1
+	if image.Metadata != nil && image.Metadata.Orientation != 0 {
+		orientation = image.Metadata.Orientation
+	}
+	sizes := make([]int, len(imageVariants))
+	for i, variant := range imageVariants {
+		sizes[i] = variant.maxSize
+	}
+	if _, err := upload.file.Seek(0, io.SeekStart); err != nil {
+		fmt.Println("Error reading temporary file:", err)
+		return image, saveErr
+	}
+	variants, err := imaging.Variants(upload.file, orientation, sizes...)
 	if err != nil {
-		fmt.Println("Error creating file:", err)
-		http.Error(w, "Error creating the file", http.StatusInternalServerError)
-		return nil
+		return image, imageError(err)
 	}
-	defer dst.Close()
 
-	// Copy the uploaded file to the destination file
-	if _, err := dst.Write(fileBytes); err != nil {
-		fmt.Println("Error copying file:", err)
-		http.Error(w, "Error saving the file", http.StatusInternalServerError)
-		return nil
+	// Build timestamped filenames; WebP and HEIC uploads were converted to JPEG
+	base := fmt.Sprintf("%s%d", UploadPrefix, time.Now().UnixNano())
+	image.Path = base + upload.ext
+	image.MediumPath = base + "_" + models.VariantMedium + ".jpg"
+	image.ThumbnailPath = base + "_" + models.VariantThumbnail + ".jpg"
+
+	// Now let’s store them, streaming the original from its temporary file
+	if _, err := upload.file.Seek(0, io.SeekStart); err != nil {
+		fmt.Println("Error reading temporary file:", err)
+		return image, saveErr
+	}
+	if err := blobs.Put(image.Path, upload.file, upload.size, upload.contentType); err != nil {
+		fmt.Println("Error saving file:", err)
+		DeleteImageFiles(blobs, image)
+		return image, saveErr
+	}
+	for i, variant := range imageVariants {
+		data := variants[i]
+		if err := blobs.Put(image.Variants()[variant.name], bytes.NewReader(data), int64(len(data)), http.DetectContentType(data)); err != nil {
+			fmt.Println("Error saving file:", err)
+			DeleteImageFiles(blobs, image)
+			return image, saveErr
+		}
 	}
 
-	fmt.Printf("Successfully saved file: %s\n", newName)
-	return dst
+	fmt.Printf("Successfully saved file: %s\n", image.Path)
+	return image, nil
 }
 
-func createFile(filename string) (*os.File, error) {
-	// Build the file path and create it
-	dst, err := os.Create(filepath.Join(uploadPath, filename))
+// RebuildImageVariants generates the resized variants of an image anew from
+// its original and stores them under the image's variant paths.
+func RebuildImageVariants(blobs storage.BlobStore, image models.Image) error {
+	blob, _, err := blobs.Get(image.Path)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(blob)
+	blob.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read image %d: %w", image.ID, err)
+	}
+
+	orientation := 1
+	if image.Metadata != nil && image.Metadata.Orientation != 0 {
+		orientation = image.Metadata.Orientation
+	}
+	sizes := make([]int, len(imageVariants))
+	for i, variant := range imageVariants {
+		sizes[i] = variant.maxSize
+	}
+	variants, err := imaging.Variants(bytes.NewReader(data), orientation, sizes...)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to resize image %d: %w", image.ID, err)
 	}
 
-	return dst, nil
+	for i, variant := range imageVariants {
+		key := image.Variants()[variant.name]
+		if key == "" {
+			continue
+		}
+		if err := blobs.Put(key, bytes.NewReader(variants[i]), int64(len(variants[i])), "image/jpeg"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteImageFiles removes the files of an image that was not stored after
+// all. Failures are only logged.
+func DeleteImageFiles(blobs storage.BlobStore, image models.Image) {
+	for _, key := range image.Variants() {
+		if err := blobs.Delete(key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			fmt.Printf("Warning: failed to delete file %s: %v\n", key, err)
+		}
+	}
 }
 
-// only allow jpg, png, jpeg
-func isValidFileType(file []byte) bool {
-	fileType := http.DetectContentType(file)
+// ImageKey returns the blob key of the uploaded image with the given file
+// name, or "" if the name is not a plain file name.
+func ImageKey(name string) string {
+	if name == "" || name != path.Base(name) || name[0] == '.' {
+		return ""
+	}
+	return UploadPrefix + name
+}
 
-	allowed := map[string]bool{
-		"image/jpeg": true,
-		"image/jpg":  true,
-		"image/png":  true,
+// ServeImage writes the image stored under key. The caller checks that the
+// user may see it.
+func ServeImage(c *gin.Context, blobs storage.BlobStore, key string) {
+	blob, info, err := blobs.Get(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		respond(c, http.StatusNotFound, "NOT_FOUND", "Image file not found")
+		return
+	}
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		respond(c, http.StatusInternalServerError, "FILE_ISSUE", "Error reading the file")
+		return
 	}
+	defer blob.Close()
 
-	return allowed[fileType]
+	c.DataFromReader(http.StatusOK, info.Size, info.ContentType, blob, map[string]string{
+		"Cache-Control": "private, max-age=3600",
+	})
 }
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ----------------------
+// Profile
+// ----------------------
+
+// Me returns the profile of the logged in user.
+func (h *AuthHandler) Me(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	user, err := h.users.FindUserByID(userID)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user.Profile())
+}
+
+// UpdateMe changes the username and email address. A new address is
+// unverified until the user follows the link mailed to it.
+func (h *AuthHandler) UpdateMe(c *gin.Context) {
+	var body struct {
+		Username string `json:"username" binding:"required"`
+		Email    string `json:"email" binding:"required"`
+	}
+
+	userID := c.GetInt("user_id")
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		RespondInvalidInput(c, err.Error())
+		return
+	}
+	body.Username = strings.TrimSpace(body.Username)
+	body.Email = strings.TrimSpace(body.Email)
+	if body.Username == "" || body.Email == "" {
+		RespondInvalidInput(c, "Username and email must not be empty")
+		return
+	}
+
+	user, err := h.users.FindUserByID(userID)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	emailChanged := user.Email != body.Email
+
+	user.Username = body.Username
+	user.Email = body.Email
+	if err := h.users.UpdateUser(user); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	user, err = h.users.FindUserByID(userID)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	if emailChanged {
+		if err := h.sendVerificationMail(user); err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, user.Profile())
+}
+
+// ----------------------
+// Change password
+// ----------------------
+
+// ChangePassword replaces the password after checking the current one. All
+// sessions are logged out and the response carries the tokens of a new one.
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var body struct {
+		CurrentPassword string `json:"currentPassword" binding:"required"`
+		NewPassword     string `json:"newPassword" binding:"required"`
+	}
+
+	userID := c.GetInt("user_id")
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	user, err := h.users.FindUserByID(userID)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	if !CheckPassword(user, body.CurrentPassword) {
+		c.JSON(http.StatusForbidden, gin.H{"code": "INVALID_CREDENTIALS", "error": "Current password is wrong"})
+		return
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(body.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		RespondInvalidInput(c, "Invalid password")
+		return
+	}
+	if err := h.users.UpdatePassword(userID, string(hashed)); err != nil {
+		RespondError(c, err)
+		return
+	}
+	if err := h.tokens.RevokeAllTokensForUser(userID); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	h.startSession(c, userID)
+}
+
+// CheckPassword reports whether password is the user's password.
+func CheckPassword(user models.User, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) == nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Johnhi19/TreeSpotter_backend/db"
+	"github.com/Johnhi19/TreeSpotter_backend/handlers"
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *server) getHarvestsOfTree(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intTreeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	harvests, err := s.harvests.FindHarvestsForTree(intTreeID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, harvests)
+}
+
+func (s *server) findHarvestByID(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intTreeID, intHarvestID, ok := treeChildParams(c, "harvestId")
+	if !ok {
+		return
+	}
+
+	harvest, err := s.harvests.FindOneHarvestForUser(intHarvestID, intTreeID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, harvest)
+}
+
+func (s *server) insertHarvest(c *gin.Context) {
+	var harvest models.Harvest
+
+	userID := c.GetInt("user_id")
+
+	intTreeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	if err := c.ShouldBindJSON(&harvest); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+	harvest.TreeId = intTreeID
+
+	if err := harvest.Validate(); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	insertedID, err := s.harvests.InsertHarvestForUser(harvest, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Harvest inserted successfully",
+		"id":      insertedID,
+	})
+}
+
+func (s *server) updateHarvest(c *gin.Context) {
+	var harvest models.Harvest
+
+	userID := c.GetInt("user_id")
+
+	intTreeID, intHarvestID, ok := treeChildParams(c, "harvestId")
+	if !ok {
+		return
+	}
+
+	if err := c.ShouldBindJSON(&harvest); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+	harvest.ID = intHarvestID
+	harvest.TreeId = intTreeID
+
+	if err := harvest.Validate(); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	if err := s.harvests.UpdateHarvestForUser(harvest, userID); err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Harvest updated successfully",
+	})
+}
+
+func (s *server) removeHarvest(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intTreeID, intHarvestID, ok := treeChildParams(c, "harvestId")
+	if !ok {
+		return
+	}
+
+	if err := s.harvests.DeleteHarvestForUser(intHarvestID, intTreeID, userID); err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Harvest deleted successfully",
+		"id":      intHarvestID,
+	})
+}
+
+// getYield returns the yield per year and tree, variety or meadow, as picked
+// by the group parameter. It can be narrowed down with the meadowId, from and
+// to query parameters.
+func (s *server) getYield(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	group := db.YieldGroup(c.Param("group"))
+	if group != db.YieldByTree && group != db.YieldByVariety && group != db.YieldByMeadow {
+		handlers.RespondInvalidInput(c, "Group must be one of trees, varieties or meadows")
+		return
+	}
+
+	var filter db.YieldFilter
+	params := []struct {
+		key    string
+		target *int
+	}{{"meadowId", &filter.MeadowId}, {"from", &filter.FromYear}, {"to", &filter.ToYear}}
+	for _, param := range params {
+		key, target := param.key, param.target
+		value := c.Query(key)
+		if value == "" {
+			continue
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			handlers.RespondInvalidInput(c, fmt.Sprintf("Invalid %s", key))
+			return
+		}
+		*target = number
+	}
+
+	stats, err := s.harvests.FindYieldForUser(userID, group, filter)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, stats)
+}
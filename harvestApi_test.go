@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+)
+
+func TestYield(t *testing.T) {
+	ts := newTestServer(t)
+	owner, _ := ts.signUp("owner")
+	outsider, _ := ts.signUp("outsider")
+	orchard, field := ts.newMeadow(owner), ts.newMeadow(owner)
+	apple, otherApple := ts.newTree(owner, orchard), ts.newTree(owner, field)
+	pear := id(ts.mustRequest("POST", "/trees", fmt.Sprintf(`{"meadowId": %d, "type": "Pear", "plantDate": "2020-03-01T00:00:00Z", "position": {"x": 2, "y": 2}}`, orchard), owner, http.StatusCreated))
+	trashed := ts.newTree(owner, orchard)
+
+	harvest := func(tree int, date string, quantity string, unit string) {
+		t.Helper()
+		body := fmt.Sprintf(`{"date": "%sT00:00:00Z", "quantity": %s, "unit": %q}`, date, quantity, unit)
+		ts.mustRequest("POST", fmt.Sprintf("/trees/%d/harvests", tree), body, owner, http.StatusCreated)
+	}
+	harvest(apple, "2023-09-01", "20", "kg")
+	harvest(apple, "2023-10-01", "500", "g")
+	harvest(apple, "2024-09-15", "0.01", "t")
+	harvest(otherApple, "2024-09-20", "4", "kg")
+	harvest(pear, "2024-08-30", "10", "lb")
+	harvest(trashed, "2024-09-01", "100", "kg")
+	ts.mustRequest("DELETE", fmt.Sprintf("/trees/%d", trashed), "", owner, http.StatusOK)
+
+	yield := func(path string, token string) []models.YieldStat {
+		t.Helper()
+		w := ts.serve(httptest.NewRequest("GET", path, nil), token)
+		var stats []models.YieldStat
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &stats) != nil {
+			t.Fatalf("GET %s = %d %s", path, w.Code, w.Body)
+		}
+		return stats
+	}
+	type stat struct {
+		year     int
+		key      string
+		harvests int
+		kg       float64
+	}
+	check := func(path string, stats []models.YieldStat, key func(models.YieldStat) string, want []stat) {
+		t.Helper()
+		if len(stats) != len(want) {
+			t.Fatalf("GET %s = %+v, want %d rows", path, stats, len(want))
+		}
+		for i, w := range want {
+			got := stats[i]
+			if got.Year != w.year || key(got) != w.key || got.Harvests != w.harvests || math.Abs(got.QuantityKg-w.kg) > 1e-9 {
+				t.Errorf("GET %s row %d = %d %s %d %.3f kg, want %d %s %d %.3f kg", path, i,
+					got.Year, key(got), got.Harvests, got.QuantityKg, w.year, w.key, w.harvests, w.kg)
+			}
+		}
+	}
+	variety := func(s models.YieldStat) string { return *s.Variety }
+	meadow := func(s models.YieldStat) string { return fmt.Sprint(s.MeadowId) }
+	tree := func(s models.YieldStat) string { return fmt.Sprint(s.TreeId) }
+
+	check("/yield/varieties", yield("/yield/varieties", owner), variety, []stat{
+		{2023, "Apple", 2, 20.5},
+		{2024, "Apple", 2, 14},
+		{2024, "Pear", 1, 4.5359237},
+	})
+	check("/yield/meadows", yield("/yield/meadows?from=2024", owner), meadow, []stat{
+		{2024, fmt.Sprint(orchard), 2, 14.5359237},
+		{2024, fmt.Sprint(field), 1, 4},
+	})
+	check("/yield/trees", yield(fmt.Sprintf("/yield/trees?meadowId=%d&to=2023", orchard), owner), tree, []stat{
+		{2023, fmt.Sprint(apple), 2, 20.5},
+	})
+	if stats := yield("/yield/trees", outsider); len(stats) != 0 {
+		t.Errorf("someone else sees %+v", stats)
+	}
+
+	ts.mustRequest("GET", "/yield/orchards", "", owner, http.StatusBadRequest)
+	ts.mustRequest("GET", "/yield/trees?from=last", "", owner, http.StatusBadRequest)
+	ts.mustRequest("POST", fmt.Sprintf("/trees/%d/harvests", apple), `{"date": "2024-09-01T00:00:00Z", "quantity": 3, "unit": "bushel"}`, owner, http.StatusBadRequest)
+	ts.mustRequest("POST", fmt.Sprintf("/trees/%d/harvests", apple), `{"date": "2024-09-01T00:00:00Z", "quantity": -3, "unit": "kg"}`, owner, http.StatusBadRequest)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/db"
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+	"github.com/Johnhi19/TreeSpotter_backend/storage"
+)
+
+// defaultImageVerifyInterval is how often the stored image files are
+// checked against the images table, unless configured otherwise.
+const defaultImageVerifyInterval = 24 * time.Hour
+
+// imageProblem is a file of an image that is missing or no longer has the
+// content it was uploaded with.
+type imageProblem struct {
+	ImageId int
+	File    string
+	Problem string
+}
+
+// verifyImages checks that all files of all images exist and that the
+// originals still have the SHA-256 recorded at upload. Images uploaded
+// before hashes were recorded are only checked for missing files. It
+// returns how many images were checked and the problems found.
+func verifyImages(images db.ImageStore, blobs storage.BlobStore) (int, []imageProblem, error) {
+	all, err := images.FindAllImages()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var problems []imageProblem
+	for _, image := range all {
+		for variant, key := range image.Variants() {
+			if variant != models.VariantOriginal || image.SHA256 == "" {
+				if _, err := blobs.Stat(key); err != nil {
+					problems = append(problems, fileProblem(image.ID, key, err))
+				}
+				continue
+			}
+
+			hash, err := hashBlob(blobs, key)
+			if err != nil {
+				problems = append(problems, fileProblem(image.ID, key, err))
+			} else if hash != image.SHA256 {
+				problems = append(problems, imageProblem{ImageId: image.ID, File: key, Problem: "content does not match its SHA-256"})
+			}
+		}
+	}
+	return len(all), problems, nil
+}
+
+// verifyImagesPeriodically runs verifyImages every interval and logs the
+// problems it finds. It never returns.
+func verifyImagesPeriodically(images db.ImageStore, blobs storage.BlobStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		<-ticker.C
+
+		checked, problems, err := verifyImages(images, blobs)
+		if err != nil {
+			fmt.Printf("ERROR verifying images: %v\n", err)
+			continue
+		}
+		for _, problem := range problems {
+			fmt.Printf("WARNING: image %d: %s: %s\n", problem.ImageId, problem.File, problem.Problem)
+		}
+		fmt.Printf("Verified %d images, found %d problems\n", checked, len(problems))
+	}
+}
+
+func fileProblem(imageId int, key string, err error) imageProblem {
+	if errors.Is(err, storage.ErrNotFound) {
+		return imageProblem{ImageId: imageId, File: key, Problem: "file is missing"}
+	}
+	return imageProblem{ImageId: imageId, File: key, Problem: fmt.Sprintf("file could not be read: %v", err)}
+}
+
+// hashBlob returns the hex encoded SHA-256 of the blob's content
+func hashBlob(blobs storage.BlobStore, key string) (string, error) {
+	blob, _, err := blobs.Get(key)
+	if err != nil {
+		return "", err
+	}
+	defer blob.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, blob); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
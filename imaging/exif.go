@@ -0,0 +1,164 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// Exif holds the EXIF fields of a photo the app uses. Fields the photo
+// does not have are left zero, except Orientation, which is then 1.
+type Exif struct {
+	TakenAt     time.Time
+	HasLocation bool
+	Latitude    float64
+	Longitude   float64
+	CameraModel string
+	Orientation int
+}
+
+// ReadExif reads the EXIF fields of a JPEG, WebP or HEIC image. It returns
+// false if the image has no EXIF data. Of JPEG images the first few hundred
+// kilobytes are enough, the others have to be complete.
+func ReadExif(data []byte) (Exif, bool) {
+	info := Exif{Orientation: 1}
+
+	switch DetectFormat(data) {
+	case FormatJPEG:
+	case FormatWebP:
+		data = webpExif(data)
+	case FormatHEIC:
+		data = heicExif(data)
+	default:
+		return info, false
+	}
+	if data == nil {
+		return info, false
+	}
+
+	x, err := exif.Decode(bytes.NewReader(data))
+	if err != nil {
+		return info, false
+	}
+
+	if takenAt, err := x.DateTime(); err == nil {
+		info.TakenAt = takenAt
+	}
+	if lat, lon, err := x.LatLong(); err == nil && lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180 {
+		info.HasLocation, info.Latitude, info.Longitude = true, lat, lon
+	}
+	if tag, err := x.Get(exif.Model); err == nil {
+		if model, err := tag.StringVal(); err == nil {
+			info.CameraModel = strings.TrimRight(model, "\x00 ")
+		}
+	}
+	if tag, err := x.Get(exif.Orientation); err == nil {
+		if orientation, err := tag.Int(0); err == nil && orientation >= 1 && orientation <= 8 {
+			info.Orientation = orientation
+		}
+	}
+	return info, true
+}
+
+// StripGPS returns the JPEG image with the entries and values of its EXIF
+// GPS directory overwritten with zeros, so that it no longer tells where it
+// was taken. Everything else, including the other EXIF fields, is kept.
+// Data that is not a JPEG with EXIF GPS data is returned unchanged.
+func StripGPS(data []byte) []byte {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return data
+	}
+
+	// Find the APP1 segment holding the EXIF data among the headers
+	for pos := 2; pos+4 <= len(data) && data[pos] == 0xFF; {
+		marker := data[pos+1]
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if marker == 0xDA || length < 2 || end > len(data) {
+			break
+		}
+		segment := data[pos+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			stripped := bytes.Clone(data)
+			stripGPSFromTIFF(stripped[pos+4+6 : end])
+			return stripped
+		}
+		pos = end
+	}
+	return data
+}
+
+// stripGPSFromTIFF clears the GPS directory of EXIF data in TIFF layout in
+// place. Offsets pointing outside of the data are ignored.
+func stripGPSFromTIFF(tiff []byte) {
+	if len(tiff) < 8 {
+		return
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return
+	}
+
+	// entries returns the offset and number of the entries of the directory
+	// at offset, if they lie within the data
+	entries := func(offset uint32) (int, int, bool) {
+		if uint64(offset)+2 > uint64(len(tiff)) {
+			return 0, 0, false
+		}
+		start, count := int(offset)+2, int(order.Uint16(tiff[offset:]))
+		if start+count*12 > len(tiff) {
+			return 0, 0, false
+		}
+		return start, count, true
+	}
+
+	start, count, ok := entries(order.Uint32(tiff[4:]))
+	if !ok {
+		return
+	}
+	for i := 0; i < count; i++ {
+		entry := tiff[start+i*12:]
+		if order.Uint16(entry) != 0x8825 {
+			continue
+		}
+
+		gpsStart, gpsCount, ok := entries(order.Uint32(entry[8:]))
+		if !ok {
+			return
+		}
+		for j := 0; j < gpsCount; j++ {
+			gpsEntry := tiff[gpsStart+j*12 : gpsStart+j*12+12]
+			// Values of more than 4 bytes are stored elsewhere
+			size := uint64(typeSize(order.Uint16(gpsEntry[2:]))) * uint64(order.Uint32(gpsEntry[4:]))
+			if offset := uint64(order.Uint32(gpsEntry[8:])); size > 4 && offset+size <= uint64(len(tiff)) {
+				clear(tiff[offset : offset+size])
+			}
+			clear(gpsEntry)
+		}
+		order.PutUint16(tiff[gpsStart-2:], 0)
+		return
+	}
+}
+
+// typeSize returns the size in bytes of a value of the given TIFF type
+func typeSize(dataType uint16) int {
+	switch dataType {
+	case 1, 2, 6, 7: // byte, ASCII, signed byte, undefined
+		return 1
+	case 3, 8: // short, signed short
+		return 2
+	case 4, 9, 11: // long, signed long, float
+		return 4
+	case 5, 10, 12: // rational, signed rational, double
+		return 8
+	}
+	return 0
+}
@@ -0,0 +1,208 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"math"
+	"testing"
+	"time"
+)
+
+// tiffEntry is an entry of a TIFF directory with its value as raw bytes
+type tiffEntry struct {
+	tag, dataType uint16
+	count         uint32
+	value         []byte
+}
+
+// testTIFF lays out EXIF data in big endian TIFF: IFD0 with the given
+// entries and pointers to an Exif and a GPS directory. Values of more than
+// 4 bytes follow their directory.
+func testTIFF(ifd0, exifIFD, gpsIFD []tiffEntry) []byte {
+	order := binary.BigEndian
+	size := func(entries []tiffEntry) int {
+		n := 2 + len(entries)*12 + 4
+		for _, entry := range entries {
+			if len(entry.value) > 4 {
+				n += len(entry.value)
+			}
+		}
+		return n
+	}
+	pointer := func(offset int) []byte {
+		return order.AppendUint32(nil, uint32(offset))
+	}
+
+	exifOffset := 8 + size(ifd0) + 2*12
+	gpsOffset := exifOffset + size(exifIFD)
+	ifd0 = append(ifd0, tiffEntry{0x8769, 4, 1, pointer(exifOffset)}, tiffEntry{0x8825, 4, 1, pointer(gpsOffset)})
+
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8}
+	for _, entries := range [][]tiffEntry{ifd0, exifIFD, gpsIFD} {
+		valuesAt := len(tiff) + 2 + len(entries)*12 + 4
+		var values []byte
+
+		tiff = order.AppendUint16(tiff, uint16(len(entries)))
+		for _, entry := range entries {
+			tiff = order.AppendUint16(tiff, entry.tag)
+			tiff = order.AppendUint16(tiff, entry.dataType)
+			tiff = order.AppendUint32(tiff, entry.count)
+			if len(entry.value) > 4 {
+				tiff = order.AppendUint32(tiff, uint32(valuesAt+len(values)))
+				values = append(values, entry.value...)
+			} else {
+				tiff = append(tiff, append(entry.value, make([]byte, 4-len(entry.value))...)...)
+			}
+		}
+		tiff = append(tiff, 0, 0, 0, 0)
+		tiff = append(tiff, values...)
+	}
+	return tiff
+}
+
+func rationals(values ...uint32) []byte {
+	var data []byte
+	for _, value := range values {
+		data = binary.BigEndian.AppendUint32(data, value)
+	}
+	return data
+}
+
+// photoTIFF is the EXIF data of a photo taken with a Pixel 7 at 48°30'N
+// 11°15'36"E on 1 May 2024, to be shown turned by 90 degrees
+func photoTIFF() []byte {
+	return testTIFF(
+		[]tiffEntry{{0x0110, 2, 8, []byte("Pixel 7\x00")}, {0x0112, 3, 1, []byte{0, 6}}},
+		[]tiffEntry{{0x9003, 2, 20, []byte("2024:05:01 10:20:30\x00")}},
+		[]tiffEntry{
+			{0x0001, 2, 2, []byte("N\x00")},
+			{0x0002, 5, 3, rationals(48, 1, 30, 1, 0, 1)},
+			{0x0003, 2, 2, []byte("E\x00")},
+			{0x0004, 5, 3, rationals(11, 1, 15, 1, 36, 1)},
+		})
+}
+
+// testJPEG encodes a blank image and inserts the EXIF data after its start
+// of image marker, if given
+func testJPEG(t *testing.T, tiff []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 30, 20)), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if tiff == nil {
+		return data
+	}
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := binary.BigEndian.AppendUint16([]byte{0xFF, 0xE1}, uint16(len(payload)+2))
+	segment = append(segment, payload...)
+	return append(append(data[:2:2], segment...), data[2:]...)
+}
+
+func TestReadExif(t *testing.T) {
+	info, ok := ReadExif(testJPEG(t, photoTIFF()))
+	if !ok {
+		t.Fatal("ReadExif() found no EXIF data")
+	}
+
+	if want := time.Date(2024, 5, 1, 10, 20, 30, 0, time.Local); !info.TakenAt.Equal(want) {
+		t.Errorf("TakenAt = %s, want %s", info.TakenAt, want)
+	}
+	if !info.HasLocation || math.Abs(info.Latitude-48.5) > 1e-9 || math.Abs(info.Longitude-11.26) > 1e-9 {
+		t.Errorf("location = %t %f %f, want 48.5 11.26", info.HasLocation, info.Latitude, info.Longitude)
+	}
+	if info.CameraModel != "Pixel 7" {
+		t.Errorf("CameraModel = %q, want Pixel 7", info.CameraModel)
+	}
+	if info.Orientation != 6 {
+		t.Errorf("Orientation = %d, want 6", info.Orientation)
+	}
+}
+
+func TestReadExifWithout(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"jpeg without exif", testJPEG(t, nil)},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")},
+		{"unknown", []byte("GIF89a")},
+		{"broken exif", testJPEG(t, []byte("MM\x00\x2a\xff\xff\xff\xff"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, ok := ReadExif(tt.data)
+			if ok || info.Orientation != 1 {
+				t.Errorf("ReadExif() = %+v, %t, want orientation 1 and false", info, ok)
+			}
+		})
+	}
+}
+
+func TestStripGPS(t *testing.T) {
+	data := testJPEG(t, photoTIFF())
+	original := bytes.Clone(data)
+
+	stripped := StripGPS(data)
+	if !bytes.Equal(data, original) {
+		t.Error("StripGPS() changed its input")
+	}
+	if len(stripped) != len(data) {
+		t.Errorf("StripGPS() changed the size from %d to %d bytes", len(data), len(stripped))
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Errorf("stripped image does not decode: %v", err)
+	}
+	if bytes.Contains(stripped, rationals(48, 1, 30, 1, 0, 1)) {
+		t.Error("stripped image still holds the latitude")
+	}
+
+	info, ok := ReadExif(stripped)
+	if !ok {
+		t.Fatal("ReadExif() found no EXIF data after stripping")
+	}
+	if info.HasLocation {
+		t.Errorf("location %f %f survived", info.Latitude, info.Longitude)
+	}
+	if info.CameraModel != "Pixel 7" || info.Orientation != 6 || info.TakenAt.IsZero() {
+		t.Errorf("other fields were lost: %+v", info)
+	}
+}
+
+func TestStripGPSUnchanged(t *testing.T) {
+	withoutGPS := testTIFF([]tiffEntry{{0x0110, 2, 8, []byte("Pixel 7\x00")}}, nil, nil)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"jpeg without exif", testJPEG(t, nil)},
+		{"empty gps directory", testJPEG(t, withoutGPS)},
+		{"png", []byte("\x89PNG\r\n\x1a\n")},
+		{"too short", []byte{0xFF}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if stripped := StripGPS(tt.data); !bytes.Equal(stripped, tt.data) {
+				t.Error("StripGPS() changed the data")
+			}
+		})
+	}
+}
+
+func TestStripGPSTruncated(t *testing.T) {
+	// The GPS directory lies past the end of the cut off EXIF data, so its
+	// offset must not be followed
+	tiff := photoTIFF()
+	data := testJPEG(t, tiff[:len(tiff)-60])
+
+	if stripped := StripGPS(data); len(stripped) != len(data) {
+		t.Errorf("StripGPS() changed the size from %d to %d bytes", len(data), len(stripped))
+	}
+}
@@ -0,0 +1,300 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+
+	"github.com/gen2brain/heic"
+	"golang.org/x/image/webp"
+)
+
+// Formats of the images that can be uploaded
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+	FormatHEIC = "heic"
+)
+
+// OriginalQuality is the JPEG quality of WebP and HEIC images converted on
+// upload, which replace the originals.
+const OriginalQuality = 92
+
+// heicBrands are the ftyp brands of HEIF files holding HEVC coded images
+var heicBrands = map[string]bool{
+	"heic": true, "heix": true, "hevc": true, "hevx": true,
+	"heim": true, "heis": true, "mif1": true, "msf1": true,
+}
+
+// DetectFormat returns the format of the image starting with header, or ""
+// if it is not one of the supported formats.
+func DetectFormat(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte("\xFF\xD8\xFF")):
+		return FormatJPEG
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG
+	case len(header) >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == "WEBP":
+		return FormatWebP
+	case len(header) >= 12 && string(header[4:8]) == "ftyp" && heicBrands[string(header[8:12])]:
+		return FormatHEIC
+	}
+	return ""
+}
+
+// ToJPEG converts a WebP or HEIC image to JPEG. The result is turned
+// upright and carries no EXIF data, so its orientation is 1.
+func ToJPEG(data []byte) ([]byte, error) {
+	format := DetectFormat(data)
+
+	var decodeConfig func(io.Reader) (image.Config, error)
+	var decode func(io.Reader) (image.Image, error)
+	switch format {
+	case FormatWebP:
+		decodeConfig, decode = webp.DecodeConfig, webp.Decode
+	case FormatHEIC:
+		decodeConfig, decode = heic.DecodeConfig, heic.Decode
+	default:
+		return nil, fmt.Errorf("failed to decode image: not WebP or HEIC")
+	}
+
+	config, err := decodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, fmt.Errorf("%dx%d pixels: %w", config.Width, config.Height, ErrTooLarge)
+	}
+
+	img, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	// The HEIC decoder already applies the rotation of the file
+	orientation := 1
+	if format != FormatHEIC {
+		info, _ := ReadExif(data)
+		orientation = info.Orientation
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, orient(flatten(img), orientation), &jpeg.Options{Quality: OriginalQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// webpExif returns the EXIF chunk of a WebP image, or nil
+func webpExif(data []byte) []byte {
+	for pos := 12; pos+8 <= len(data); {
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		start := pos + 8
+		if size < 0 || size > len(data)-start {
+			return nil
+		}
+		if string(data[pos:pos+4]) == "EXIF" {
+			return data[start : start+size]
+		}
+		// Chunks are padded to an even size
+		pos = start + size + size&1
+	}
+	return nil
+}
+
+// heicExif returns the EXIF data of a HEIF image in TIFF layout, or nil. It
+// is stored as an item of type "Exif", found through the item info (iinf)
+// and item location (iloc) boxes of the meta box.
+func heicExif(data []byte) []byte {
+	meta := findBox(data, "meta")
+	if len(meta) < 4 {
+		return nil
+	}
+	// meta is a full box, starting with its version and flags
+	meta = meta[4:]
+
+	id, ok := exifItemID(findBox(meta, "iinf"))
+	if !ok {
+		return nil
+	}
+	item := itemData(findBox(meta, "iloc"), id, data)
+	if len(item) < 4 {
+		return nil
+	}
+
+	// The item starts with the offset of the TIFF header after it
+	offset := int(binary.BigEndian.Uint32(item))
+	if offset < 0 || offset > len(item)-4 {
+		return nil
+	}
+	return item[4+offset:]
+}
+
+// exifItemID returns the ID of the item of type "Exif" listed in the
+// payload of an iinf box
+func exifItemID(iinf []byte) (uint64, bool) {
+	r := &boxReader{data: iinf}
+	version := r.uint(1)
+	r.skip(3)
+	if version == 0 {
+		r.skip(2)
+	} else {
+		r.skip(4)
+	}
+	if r.bad {
+		return 0, false
+	}
+
+	var id uint64
+	found := false
+	eachBox(iinf[r.pos:], func(typ string, infe []byte) bool {
+		if typ != "infe" {
+			return true
+		}
+		r := &boxReader{data: infe}
+		version := r.uint(1)
+		r.skip(3)
+		if version < 2 {
+			// Older entries carry no item type
+			return true
+		}
+		idSize := 2
+		if version > 2 {
+			idSize = 4
+		}
+		itemID := r.uint(idSize)
+		r.skip(2)
+		if !r.bad && r.pos+4 <= len(infe) && string(infe[r.pos:r.pos+4]) == "Exif" {
+			id, found = itemID, true
+			return false
+		}
+		return true
+	})
+	return id, found
+}
+
+// itemData returns the content of item id, located in file through the
+// payload of an iloc box. Only items stored in the file itself are read.
+func itemData(iloc []byte, id uint64, file []byte) []byte {
+	r := &boxReader{data: iloc}
+	version := r.uint(1)
+	r.skip(3)
+	sizes := r.uint(1)
+	offsetSize, lengthSize := int(sizes>>4), int(sizes&15)
+	sizes = r.uint(1)
+	baseOffsetSize, indexSize := int(sizes>>4), int(sizes&15)
+	if version == 0 {
+		indexSize = 0
+	}
+
+	idSize := 2
+	if version == 2 {
+		idSize = 4
+	}
+	count := r.uint(idSize)
+
+	for i := uint64(0); i < count && !r.bad; i++ {
+		itemID := r.uint(idSize)
+		method := uint64(0)
+		if version == 1 || version == 2 {
+			method = r.uint(2) & 15
+		}
+		r.skip(2) // data reference index
+		base := r.uint(baseOffsetSize)
+		extents := r.uint(2)
+
+		var item []byte
+		for j := uint64(0); j < extents && !r.bad; j++ {
+			r.skip(indexSize)
+			offset, length := base+r.uint(offsetSize), r.uint(lengthSize)
+			if itemID != id || method != 0 {
+				continue
+			}
+			if offset > uint64(len(file)) {
+				return nil
+			}
+			// A length of 0 stands for the rest of the file
+			if length == 0 || length > uint64(len(file))-offset {
+				length = uint64(len(file)) - offset
+			}
+			item = append(item, file[offset:offset+length]...)
+		}
+		if itemID == id {
+			return item
+		}
+	}
+	return nil
+}
+
+// findBox returns the payload of the first box of the given type among the
+// ISO base media file format boxes in data, or nil
+func findBox(data []byte, typ string) []byte {
+	var payload []byte
+	eachBox(data, func(t string, p []byte) bool {
+		if t == typ {
+			payload = p
+			return false
+		}
+		return true
+	})
+	return payload
+}
+
+// eachBox calls fn with the type and payload of each box in data, until fn
+// returns false or a box does not fit
+func eachBox(data []byte, fn func(typ string, payload []byte) bool) {
+	for pos := 0; pos+8 <= len(data); {
+		size := uint64(binary.BigEndian.Uint32(data[pos:]))
+		typ := string(data[pos+4 : pos+8])
+		header := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data) - pos)
+		case 1:
+			if pos+16 > len(data) {
+				return
+			}
+			size, header = binary.BigEndian.Uint64(data[pos+8:]), 16
+		}
+		if size < header || size > uint64(len(data)-pos) {
+			return
+		}
+		if !fn(typ, data[pos+int(header):pos+int(size)]) {
+			return
+		}
+		pos += int(size)
+	}
+}
+
+// boxReader reads big endian integers of the given byte sizes from a box.
+// Reading past its end sets bad and returns zeros.
+type boxReader struct {
+	data []byte
+	pos  int
+	bad  bool
+}
+
+func (r *boxReader) uint(size int) uint64 {
+	if size > len(r.data)-r.pos {
+		r.bad = true
+		return 0
+	}
+	var v uint64
+	for _, b := range r.data[r.pos : r.pos+size] {
+		v = v<<8 | uint64(b)
+	}
+	r.pos += size
+	return v
+}
+
+func (r *boxReader) skip(size int) {
+	if size > len(r.data)-r.pos {
+		r.bad = true
+		return
+	}
+	r.pos += size
+}
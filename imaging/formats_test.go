@@ -0,0 +1,203 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		want   string
+	}{
+		{"jpeg", []byte("\xFF\xD8\xFF\xE0\x00\x10JFIF"), FormatJPEG},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), FormatPNG},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), FormatWebP},
+		{"heic", []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00"), FormatHEIC},
+		{"heif", []byte("\x00\x00\x00\x18ftypmif1\x00\x00\x00\x00"), FormatHEIC},
+		{"avif", []byte("\x00\x00\x00\x18ftypavif\x00\x00\x00\x00"), ""},
+		{"mp4", []byte("\x00\x00\x00\x18ftypisom\x00\x00\x00\x00"), ""},
+		{"riff audio", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), ""},
+		{"gif", []byte("GIF89a"), ""},
+		{"truncated jpeg", []byte("\xFF\xD8"), ""},
+		{"truncated heic", []byte("\x00\x00\x00\x18ftyp"), ""},
+		{"empty", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectFormat(tt.header); got != tt.want {
+				t.Errorf("DetectFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// box returns an ISO base media file format box
+func box(typ string, payload ...[]byte) []byte {
+	content := bytes.Join(payload, nil)
+	data := binary.BigEndian.AppendUint32(nil, uint32(8+len(content)))
+	return append(append(data, typ...), content...)
+}
+
+// fullBox returns a box starting with a version and flags
+func fullBox(typ string, version byte, payload ...[]byte) []byte {
+	return box(typ, append([][]byte{{version, 0, 0, 0}}, payload...)...)
+}
+
+func uint16s(values ...uint16) []byte {
+	var data []byte
+	for _, value := range values {
+		data = binary.BigEndian.AppendUint16(data, value)
+	}
+	return data
+}
+
+func uint32s(values ...uint32) []byte {
+	var data []byte
+	for _, value := range values {
+		data = binary.BigEndian.AppendUint32(data, value)
+	}
+	return data
+}
+
+// testHEIC lays out a HEIF file with a coded image item 1 and an Exif item
+// 2 in its mdat box. infeVersion and ilocVersion select the layout of the
+// item info and location boxes.
+func testHEIC(tiff []byte, infeVersion, ilocVersion byte) []byte {
+	ftyp := box("ftyp", []byte("heic"), uint32s(0), []byte("mif1heic"))
+
+	infe := func(id uint32, typ string) []byte {
+		if infeVersion > 2 {
+			return fullBox("infe", infeVersion, uint32s(id), uint16s(0), []byte(typ), []byte("\x00"))
+		}
+		return fullBox("infe", infeVersion, uint16s(uint16(id), 0), []byte(typ), []byte("\x00"))
+	}
+	iinf := fullBox("iinf", 0, uint16s(2), infe(1, "hvc1"), infe(2, "Exif"))
+
+	// The Exif item starts with the offset of the TIFF header after it
+	exifItem := append(uint32s(2), append([]byte("\x00\x00"), tiff...)...)
+	image := []byte("coded image data")
+
+	// Offsets are 4 bytes, lengths 4 bytes and there is no base offset
+	iloc := func(offset uint32) []byte {
+		item := func(id uint16, offset, length uint32) []byte {
+			entry := uint16s(id)
+			if ilocVersion == 1 {
+				entry = append(entry, uint16s(0)...) // construction method
+			}
+			return append(entry, append(uint16s(0, 1), uint32s(offset, length)...)...)
+		}
+		return fullBox("iloc", ilocVersion, []byte{0x44, 0x00}, uint16s(2),
+			item(1, offset, uint32(len(image))), item(2, offset+uint32(len(image)), uint32(len(exifItem))))
+	}
+
+	// The mdat offset depends on the size of the meta box before it, which
+	// does not depend on the offset
+	meta := fullBox("meta", 0, fullBox("hdlr", 0, make([]byte, 20)), iinf, iloc(0))
+	mdatOffset := uint32(len(ftyp) + len(meta) + 8)
+	meta = fullBox("meta", 0, fullBox("hdlr", 0, make([]byte, 20)), iinf, iloc(mdatOffset))
+
+	return bytes.Join([][]byte{ftyp, meta, box("mdat", image, exifItem)}, nil)
+}
+
+func TestHeicExif(t *testing.T) {
+	tiff := photoTIFF()
+
+	tests := []struct {
+		name                     string
+		infeVersion, ilocVersion byte
+	}{
+		{"infe version 2, iloc version 0", 2, 0},
+		{"infe version 2, iloc version 1", 2, 1},
+		{"infe version 3, iloc version 0", 3, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testHEIC(tiff, tt.infeVersion, tt.ilocVersion)
+			if DetectFormat(data) != FormatHEIC {
+				t.Fatal("test file is not detected as HEIC")
+			}
+			if got := heicExif(data); !bytes.Equal(got, tiff) {
+				t.Fatalf("heicExif() = %q, want the TIFF data", got)
+			}
+
+			info, ok := ReadExif(data)
+			if !ok || info.CameraModel != "Pixel 7" || !info.HasLocation || info.Orientation != 6 {
+				t.Errorf("ReadExif() = %+v, %t", info, ok)
+			}
+		})
+	}
+}
+
+func TestHeicExifMalformed(t *testing.T) {
+	valid := testHEIC(photoTIFF(), 2, 0)
+	metaAt := bytes.Index(valid, []byte("meta")) - 4
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"no meta box", box("ftyp", []byte("heic"), uint32s(0))},
+		{"truncated in the meta box", valid[:metaAt+40]},
+		{"box larger than the file", append(uint32s(1<<20), valid[4:]...)},
+		{"meta box cut short", append(bytes.Clone(valid[:metaAt]), box("meta", []byte{0, 0})...)},
+		{"only ftyp", valid[:24]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := heicExif(tt.data); got != nil {
+				t.Errorf("heicExif() = %q, want nil", got)
+			}
+			if _, ok := ReadExif(tt.data); ok {
+				t.Error("ReadExif() found EXIF data")
+			}
+		})
+	}
+}
+
+func TestItemDataExtents(t *testing.T) {
+	file := []byte("0123456789abcdef")
+
+	// Item 5 is stored in two extents; a length of 0 reaches to the end
+	iloc := append([]byte{0, 0, 0, 0, 0x44, 0x00}, uint16s(1, 5, 0, 2)...)
+	iloc = append(iloc, uint32s(2, 3, 12, 0)...)
+
+	if got := itemData(iloc, 5, file); string(got) != "234cdef" {
+		t.Errorf("itemData() = %q, want 234cdef", got)
+	}
+	if got := itemData(iloc, 6, file); got != nil {
+		t.Errorf("itemData() of a missing item = %q, want nil", got)
+	}
+
+	outside := append([]byte{0, 0, 0, 0, 0x44, 0x00}, uint16s(1, 5, 0, 1)...)
+	outside = append(outside, uint32s(100, 4)...)
+	if got := itemData(outside, 5, file); got != nil {
+		t.Errorf("itemData() past the end of the file = %q, want nil", got)
+	}
+}
+
+func TestWebpExif(t *testing.T) {
+	tiff := photoTIFF()
+	chunk := func(typ string, payload []byte) []byte {
+		data := append([]byte(typ), binary.LittleEndian.AppendUint32(nil, uint32(len(payload)))...)
+		data = append(data, payload...)
+		if len(payload)%2 == 1 {
+			data = append(data, 0)
+		}
+		return data
+	}
+	data := append([]byte("RIFF\x00\x00\x00\x00WEBP"), chunk("VP8X", make([]byte, 9))...)
+	data = append(data, chunk("EXIF", tiff)...)
+
+	if got := webpExif(data); !bytes.Equal(got, tiff) {
+		t.Errorf("webpExif() = %q, want the TIFF data", got)
+	}
+	if got := webpExif(data[:len(data)-10]); got != nil {
+		t.Errorf("webpExif() of a cut off chunk = %q, want nil", got)
+	}
+}
@@ -0,0 +1,127 @@
+// Package imaging decodes uploaded images and produces the resized JPEG
+// copies the app shows instead of the originals.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// MaxPixels is the largest image, in pixels, that is decoded. It keeps
+// small files that expand to huge images from exhausting the memory.
+const MaxPixels = 50_000_000
+
+// Quality is the JPEG quality of the resized copies.
+const Quality = 85
+
+// ErrTooLarge is returned for images with more than MaxPixels pixels.
+var ErrTooLarge = errors.New("image is too large")
+
+// Variants decodes the JPEG or PNG image and returns JPEG copies of it that
+// fit in squares of the given sizes, which go from the largest to the
+// smallest. Images are never enlarged, transparent areas become white and
+// the copies are turned upright according to the given EXIF orientation.
+func Variants(r io.ReadSeeker, orientation int, sizes ...int) ([][]byte, error) {
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, fmt.Errorf("%dx%d pixels: %w", config.Width, config.Height, ErrTooLarge)
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind image: %w", err)
+	}
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	variants := make([][]byte, 0, len(sizes))
+	for _, size := range sizes {
+		// Each copy is scaled from the previous, larger one
+		resized := fit(img, size)
+		img = resized
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, orient(resized, orientation), &jpeg.Options{Quality: Quality}); err != nil {
+			return nil, fmt.Errorf("failed to encode image: %w", err)
+		}
+		variants = append(variants, buf.Bytes())
+	}
+	return variants, nil
+}
+
+// fit scales img down to fit in a size x size square, on a white background
+func fit(img image.Image, size int) *image.RGBA {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, max(1, height*size/width)
+		} else {
+			width, height = max(1, width*size/height), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+	return dst
+}
+
+// flatten copies img onto a white background of the same size
+func flatten(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Over)
+	return dst
+}
+
+// orient turns img upright, given its EXIF orientation
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation == 1 {
+		return img
+	}
+
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		for x := 0; x < dstW; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored
+				sx, sy = w-1-x, y
+			case 3: // rotated by 180°
+				sx, sy = w-1-x, h-1-y
+			case 4: // upside down mirrored
+				sx, sy = x, h-1-y
+			case 5: // mirrored and rotated by 90° counterclockwise
+				sx, sy = y, x
+			case 6: // rotated by 90° counterclockwise
+				sx, sy = y, h-1-x
+			case 7: // mirrored and rotated by 90° clockwise
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotated by 90° clockwise
+				sx, sy = w-1-y, x
+			}
+			dst.SetRGBA(x, y, img.RGBAAt(sx, sy))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/Johnhi19/TreeSpotter_backend/db"
+	"github.com/Johnhi19/TreeSpotter_backend/handlers"
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *server) getInspectionsOfTree(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intTreeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	inspections, err := s.inspections.FindInspectionsForTree(intTreeID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, inspections)
+}
+
+func (s *server) findInspectionByID(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intTreeID, intInspectionID, ok := treeChildParams(c, "inspectionId")
+	if !ok {
+		return
+	}
+
+	inspection, err := s.inspections.FindOneInspectionForUser(intInspectionID, intTreeID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, inspection)
+}
+
+func (s *server) insertInspection(c *gin.Context) {
+	var inspection models.TreeInspection
+
+	userID := c.GetInt("user_id")
+
+	intTreeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	if err := c.ShouldBindJSON(&inspection); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+	inspection.TreeId = intTreeID
+
+	if err := inspection.Validate(); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	insertedID, err := s.inspections.InsertInspectionForUser(inspection, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Inspection inserted successfully",
+		"id":      insertedID,
+	})
+}
+
+func (s *server) updateInspection(c *gin.Context) {
+	var inspection models.TreeInspection
+
+	userID := c.GetInt("user_id")
+
+	intTreeID, intInspectionID, ok := treeChildParams(c, "inspectionId")
+	if !ok {
+		return
+	}
+
+	if err := c.ShouldBindJSON(&inspection); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+	inspection.ID = intInspectionID
+	inspection.TreeId = intTreeID
+
+	if err := inspection.Validate(); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	if err := s.inspections.UpdateInspectionForUser(inspection, userID); err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Inspection updated successfully",
+	})
+}
+
+func (s *server) removeInspection(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intTreeID, intInspectionID, ok := treeChildParams(c, "inspectionId")
+	if !ok {
+		return
+	}
+
+	if err := s.inspections.DeleteInspectionForUser(intInspectionID, intTreeID, userID); err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Inspection deleted successfully",
+		"id":      intInspectionID,
+	})
+}
+
+// treeChildParams reads the tree ID and the ID of one of its inspections,
+// harvests, ... from the URL. It has already responded when ok is false.
+func treeChildParams(c *gin.Context, childParam string) (treeId int, childId int, ok bool) {
+	treeId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return 0, 0, false
+	}
+	childId, err = strconv.Atoi(c.Param(childParam))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return 0, 0, false
+	}
+	return treeId, childId, true
+}
+
+// parseTreeFilter reads the condition filters of GET /meadows/:id/trees.
+func parseTreeFilter(c *gin.Context) (db.TreeFilter, error) {
+	filter := db.TreeFilter{
+		Disease: c.Query("disease"),
+		Pest:    c.Query("pest"),
+	}
+
+	var err error
+	if filter.MinVitality, err = parseVitality(c, "minVitality"); err != nil {
+		return filter, err
+	}
+	if filter.MaxVitality, err = parseVitality(c, "maxVitality"); err != nil {
+		return filter, err
+	}
+
+	if filter.Disease != "" && !slices.Contains(models.Diseases, filter.Disease) {
+		return filter, fmt.Errorf("unknown disease %q", filter.Disease)
+	}
+	if filter.Pest != "" && !slices.Contains(models.Pests, filter.Pest) {
+		return filter, fmt.Errorf("unknown pest %q", filter.Pest)
+	}
+	return filter, nil
+}
+
+func parseVitality(c *gin.Context, key string) (*int, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	vitality, err := strconv.Atoi(value)
+	if err != nil || vitality < models.MinVitality || vitality > models.MaxVitality {
+		return nil, fmt.Errorf("%s must be a number between %d and %d", key, models.MinVitality, models.MaxVitality)
+	}
+	return &vitality, nil
+}
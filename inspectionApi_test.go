@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestInspections(t *testing.T) {
+	ts := newTestServer(t)
+	owner, _ := ts.signUp("owner")
+	meadow := ts.newMeadow(owner)
+	apple, pear := ts.newTree(owner, meadow), ts.newTree(owner, meadow)
+	appleImage, pearImage := ts.newImage(owner, apple, 10), ts.newImage(owner, pear, 20)
+
+	inspect := func(tree int, body string, want int) int {
+		t.Helper()
+		return id(ts.mustRequest("POST", fmt.Sprintf("/trees/%d/inspections", tree), body, owner, want))
+	}
+	inspect(apple, fmt.Sprintf(`{"date": "2024-05-01T00:00:00Z", "vitality": 4, "diseases": ["apple_scab"], "imageIds": [%d]}`, appleImage), http.StatusCreated)
+	latest := inspect(apple, `{"date": "2024-06-01T00:00:00Z", "vitality": 2, "pests": ["aphids"]}`, http.StatusCreated)
+	// An inspection recorded late does not replace the latest condition
+	inspect(apple, `{"date": "2023-06-01T00:00:00Z", "vitality": 5}`, http.StatusCreated)
+
+	if inspections := ts.list(fmt.Sprintf("/trees/%d/inspections", apple), owner); len(inspections) != 3 {
+		t.Errorf("the tree has %d inspections, want 3", len(inspections))
+	}
+	condition, _ := ts.mustRequest("GET", fmt.Sprintf("/trees/%d", apple), "", owner, http.StatusOK)["condition"].(map[string]any)
+	if condition["inspectionId"] != float64(latest) || condition["vitality"] != float64(2) {
+		t.Errorf("condition = %v, want that of inspection %d", condition, latest)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"vitality too high", `{"date": "2024-06-01T00:00:00Z", "vitality": 6}`, http.StatusBadRequest},
+		{"unknown disease", `{"date": "2024-06-01T00:00:00Z", "vitality": 3, "diseases": ["plague"]}`, http.StatusBadRequest},
+		{"no date", `{"vitality": 3}`, http.StatusBadRequest},
+		{"image of another tree", fmt.Sprintf(`{"date": "2024-06-01T00:00:00Z", "vitality": 3, "imageIds": [%d]}`, pearImage), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if status, response := ts.request("POST", fmt.Sprintf("/trees/%d/inspections", apple), tt.body, owner); status != tt.status {
+			t.Errorf("%s: POST = %d %v, want %d", tt.name, status, response, tt.status)
+		}
+	}
+
+	// Filters look at the latest inspection and leave out trees that were
+	// never inspected
+	filters := []struct {
+		query string
+		want  []int
+	}{
+		{"", []int{apple, pear}},
+		{"minVitality=0", []int{apple}},
+		{"maxVitality=2", []int{apple}},
+		{"minVitality=3", []int{}},
+		{"pest=aphids", []int{apple}},
+		{"disease=apple_scab", []int{}},
+	}
+	for _, f := range filters {
+		trees := []int{}
+		for _, tree := range ts.list(fmt.Sprintf("/meadows/%d/trees?%s", meadow, f.query), owner) {
+			trees = append(trees, id(tree))
+		}
+		slices.Sort(trees)
+		if !slices.Equal(trees, f.want) {
+			t.Errorf("trees with %q = %v, want %v", f.query, trees, f.want)
+		}
+	}
+	ts.mustRequest("GET", fmt.Sprintf("/meadows/%d/trees?disease=plague", meadow), "", owner, http.StatusBadRequest)
+
+	// Deleting the latest inspection brings back the one before
+	ts.mustRequest("DELETE", fmt.Sprintf("/trees/%d/inspections/%d", apple, latest), "", owner, http.StatusOK)
+	condition, _ = ts.mustRequest("GET", fmt.Sprintf("/trees/%d", apple), "", owner, http.StatusOK)["condition"].(map[string]any)
+	if condition["vitality"] != float64(4) {
+		t.Errorf("condition after deleting the latest inspection = %v, want vitality 4", condition)
+	}
+}
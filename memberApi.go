@@ -0,0 +1,203 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Johnhi19/TreeSpotter_backend/handlers"
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *server) getMembersOfMeadow(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intMeadowID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	members, err := s.members.FindMembersForMeadow(intMeadowID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, members)
+}
+
+// updateMember changes the role of a member. The body is {"role": "..."}.
+func (s *server) updateMember(c *gin.Context) {
+	var body struct {
+		Role models.Role `json:"role" binding:"required"`
+	}
+
+	userID := c.GetInt("user_id")
+
+	intMeadowID, intMemberID, ok := treeChildParams(c, "userId")
+	if !ok {
+		return
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+	if err := body.Role.Validate(); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	if err := s.members.UpdateMemberForUser(intMeadowID, intMemberID, body.Role, userID); err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Member updated successfully",
+	})
+}
+
+// removeMember removes a member from the meadow. Members can also use it to
+// leave a meadow with their own ID.
+func (s *server) removeMember(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intMeadowID, intMemberID, ok := treeChildParams(c, "userId")
+	if !ok {
+		return
+	}
+
+	if err := s.members.DeleteMemberForUser(intMeadowID, intMemberID, userID); err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Member removed successfully",
+	})
+}
+
+func (s *server) getInvitationsOfMeadow(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intMeadowID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	invitations, err := s.members.FindInvitationsForMeadow(intMeadowID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, invitations)
+}
+
+// insertInvitation invites an email address to the meadow. The body is
+// {"email": "...", "role": "..."}.
+func (s *server) insertInvitation(c *gin.Context) {
+	var invitation models.Invitation
+
+	userID := c.GetInt("user_id")
+
+	intMeadowID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	if err := c.ShouldBindJSON(&invitation); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+	invitation.MeadowId = intMeadowID
+
+	if err := invitation.Validate(); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	insertedID, err := s.members.InsertInvitationForUser(invitation, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Invitation sent successfully",
+		"id":      insertedID,
+	})
+}
+
+func (s *server) removeInvitation(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intMeadowID, intInvitationID, ok := treeChildParams(c, "invitationId")
+	if !ok {
+		return
+	}
+
+	if err := s.members.DeleteInvitationForUser(intInvitationID, intMeadowID, userID); err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Invitation revoked successfully",
+	})
+}
+
+// getMyInvitations lists the pending invitations addressed to the user's
+// email address.
+func (s *server) getMyInvitations(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	invitations, err := s.members.FindInvitationsForUser(userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, invitations)
+}
+
+func (s *server) acceptInvitation(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intInvitationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	meadowId, err := s.members.AcceptInvitationForUser(intInvitationID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Invitation accepted successfully",
+		"meadowId": meadowId,
+	})
+}
+
+func (s *server) declineInvitation(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intInvitationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	if err := s.members.DeclineInvitationForUser(intInvitationID, userID); err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Invitation declined successfully",
+	})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestInvitations(t *testing.T) {
+	ts := newTestServer(t)
+	owner, _ := ts.signUp("owner")
+	anna, _ := ts.signUp("anna")
+	ts.verifyEmail()
+	outsider, _ := ts.signUp("outsider")
+	ts.verifyEmail()
+
+	meadow := id(ts.mustRequest("POST", "/meadows", `{"name": "Orchard", "location": "Hill", "size": [10, 10]}`, owner, http.StatusCreated))
+	invite := func(email string, role string) int {
+		return id(ts.mustRequest("POST", fmt.Sprintf("/meadows/%d/invitations", meadow), fmt.Sprintf(`{"email": %q, "role": %q}`, email, role), owner, http.StatusCreated))
+	}
+
+	invitation := invite("anna@example.com", "editor")
+	if invitations := ts.list("/invitations", outsider); len(invitations) != 0 {
+		t.Errorf("someone else sees %d invitations", len(invitations))
+	}
+	ts.mustRequest("POST", fmt.Sprintf("/invitations/%d/accept", invitation), "", outsider, http.StatusNotFound)
+
+	invitations := ts.list("/invitations", anna)
+	if len(invitations) != 1 || id(invitations[0]) != invitation || invitations[0]["role"] != "editor" {
+		t.Fatalf("GET /invitations = %v, want the invitation as editor", invitations)
+	}
+	ts.mustRequest("POST", fmt.Sprintf("/invitations/%d/accept", invitation), "", anna, http.StatusOK)
+	ts.mustRequest("POST", fmt.Sprintf("/invitations/%d/accept", invitation), "", anna, http.StatusNotFound)
+
+	// The editor may change trees but not manage the members
+	ts.mustRequest("POST", "/trees", fmt.Sprintf(`{"meadowId": %d, "type": "Apple", "plantDate": "2020-03-01T00:00:00Z", "position": {"x": 1, "y": 1}}`, meadow), anna, http.StatusCreated)
+	ts.mustRequest("POST", fmt.Sprintf("/meadows/%d/invitations", meadow), `{"email": "x@example.com", "role": "viewer"}`, anna, http.StatusForbidden)
+	ts.mustRequest("DELETE", fmt.Sprintf("/meadows/%d", meadow), "", anna, http.StatusForbidden)
+	if members := ts.list(fmt.Sprintf("/meadows/%d/members", meadow), anna); len(members) != 2 {
+		t.Errorf("the meadow has %d members, want 2", len(members))
+	}
+
+	// Declined invitations are gone
+	declined := invite("outsider@example.com", "viewer")
+	ts.mustRequest("POST", fmt.Sprintf("/invitations/%d/decline", declined), "", outsider, http.StatusOK)
+	ts.mustRequest("POST", fmt.Sprintf("/invitations/%d/accept", declined), "", outsider, http.StatusNotFound)
+	ts.mustRequest("GET", fmt.Sprintf("/meadows/%d", meadow), "", outsider, http.StatusNotFound)
+}
+
+func TestInvitationNeedsVerifiedEmail(t *testing.T) {
+	ts := newTestServer(t)
+	owner, _ := ts.signUp("owner")
+	meadow := id(ts.mustRequest("POST", "/meadows", `{"name": "Orchard", "location": "Hill", "size": [10, 10]}`, owner, http.StatusCreated))
+	invite := func(email string) int {
+		return id(ts.mustRequest("POST", fmt.Sprintf("/meadows/%d/invitations", meadow), fmt.Sprintf(`{"email": %q, "role": "owner"}`, email), owner, http.StatusCreated))
+	}
+	invitations := []int{invite("anna@example.com"), invite("ben@example.com")}
+
+	// Someone registers with an invited address, and someone else changes
+	// their verified address to one, without receiving mail there
+	registered, _ := ts.signUp("anna")
+	changed, _ := ts.signUp("mallory")
+	ts.verifyEmail()
+	ts.mustRequest("PUT", "/me", `{"username": "mallory", "email": "ben@example.com"}`, changed, http.StatusOK)
+
+	for name, token := range map[string]string{"registered": registered, "changed": changed} {
+		if listed := ts.list("/invitations", token); len(listed) != 0 {
+			t.Errorf("%s: an unverified address sees %d invitations", name, len(listed))
+		}
+		for _, invitation := range invitations {
+			if status, response := ts.request("POST", fmt.Sprintf("/invitations/%d/accept", invitation), "", token); status != http.StatusNotFound {
+				t.Errorf("%s: accepting invitation %d = %d %v, want 404", name, invitation, status, response)
+			}
+			if status, response := ts.request("POST", fmt.Sprintf("/invitations/%d/decline", invitation), "", token); status != http.StatusNotFound {
+				t.Errorf("%s: declining invitation %d = %d %v, want 404", name, invitation, status, response)
+			}
+		}
+		if status, _ := ts.request("GET", fmt.Sprintf("/meadows/%d", meadow), "", token); status != http.StatusNotFound {
+			t.Errorf("%s: GET /meadows/%d = %d, want 404", name, meadow, status)
+		}
+	}
+
+	// Once the new address is verified, the invitation can be accepted
+	ts.verifyEmail()
+	ts.mustRequest("POST", fmt.Sprintf("/invitations/%d/accept", invitations[1]), "", changed, http.StatusOK)
+}
@@ -6,25 +6,30 @@ import (
 	"os"
 	"strings"
 
+	"github.com/Johnhi19/TreeSpotter_backend/db"
+	"github.com/Johnhi19/TreeSpotter_backend/signing"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware accepts requests with a valid access token whose session
+// has not been revoked, and stores the user as user_id and the session as
+// session_id.
+func AuthMiddleware(tokens db.TokenStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing Authorization header"})
+			c.JSON(http.StatusUnauthorized, gin.H{"code": "MISSING_TOKEN", "error": "Missing Authorization header"})
 			c.Abort()
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token format"})
+			c.JSON(http.StatusUnauthorized, gin.H{"code": "INVALID_TOKEN", "error": "Invalid token format"})
 			c.Abort()
 			return
 		}
@@ -41,13 +46,58 @@ func AuthMiddleware() gin.HandlerFunc {
 		if err != nil || !token.Valid {
 			fmt.Println("Token error:", err)
 			fmt.Println("Token valid:", token.Valid)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			c.JSON(http.StatusUnauthorized, gin.H{"code": "INVALID_TOKEN", "error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
 		claims := token.Claims.(jwt.MapClaims)
-		userID := int(claims["user_id"].(float64))
+		userID, _ := claims["user_id"].(float64)
+		sessionID, _ := claims["sid"].(string)
+		if userID == 0 || sessionID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"code": "INVALID_TOKEN", "error": "Invalid or expired token"})
+			c.Abort()
+			return
+		}
+
+		// Logging out revokes the session before its access tokens expire
+		active, err := tokens.IsTokenFamilyActive(sessionID)
+		if err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"code": "DATABASE_ISSUE", "error": "Internal server error"})
+			c.Abort()
+			return
+		}
+		if !active {
+			c.JSON(http.StatusUnauthorized, gin.H{"code": "INVALID_TOKEN", "error": "Session has been logged out"})
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", int(userID))
+		c.Set("session_id", sessionID)
+
+		c.Next()
+	}
+}
+
+// SignedURLOrAuth accepts requests for URLs signed by signer as the user
+// they were issued to, and hands all other requests to AuthMiddleware.
+func SignedURLOrAuth(signer *signing.Signer, tokens db.TokenStore) gin.HandlerFunc {
+	auth := AuthMiddleware(tokens)
+
+	return func(c *gin.Context) {
+		if !signing.Signed(c.Request.URL.Query()) {
+			auth(c)
+			return
+		}
+
+		userID, err := signer.Verify(c.Request.URL.Path, c.Request.URL.Query())
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"code": "INVALID_SIGNATURE", "error": "Invalid or expired signed URL"})
+			c.Abort()
+			return
+		}
 		c.Set("user_id", userID)
 
 		c.Next()
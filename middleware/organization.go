@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrganizationHeader selects the organization a request acts in.
+const OrganizationHeader = "X-Organization-ID"
+
+// OrganizationContext stores the organization a request acts in as
+// organization_id: the :id of routes under /organizations/:id, or else the
+// X-Organization-ID header. Requests with neither act in the user's personal
+// scope and leave organization_id unset. Whether the user belongs to the
+// organization is up to the store.
+func OrganizationContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value := c.GetHeader(OrganizationHeader)
+		if strings.HasPrefix(c.FullPath(), "/organizations/:id") {
+			value = c.Param("id")
+		}
+		if value == "" {
+			c.Next()
+			return
+		}
+
+		organizationID, err := strconv.Atoi(value)
+		if err != nil || organizationID < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_INPUT", "error": "Invalid organization ID"})
+			c.Abort()
+			return
+		}
+		c.Set("organization_id", organizationID)
+
+		c.Next()
+	}
+}
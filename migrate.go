@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/Johnhi19/TreeSpotter_backend/db"
+)
+
+const migrateUsage = `usage: treespotter-backend migrate <command>
+
+commands:
+  up               apply all pending migrations
+  down <n>         roll back the last n migrations
+  down -all        roll back every migration, dropping all data
+  goto <version>   migrate up or down to the given version
+  force <version>  mark the given version as applied without running it
+  version          print the current schema version
+  varieties [-dry-run]
+                   link the types of existing trees to the variety catalog`
+
+// runMigrate implements the `migrate` subcommand and returns the exit code.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	if args[0] == "varieties" {
+		return runVarietyMigration(args[1:])
+	}
+
+	var err error
+
+	switch args[0] {
+	case "up":
+		err = db.MigrateUp()
+	case "down":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "down expects a number of steps, or -all to roll back everything")
+			return 2
+		}
+		if args[1] == "-all" {
+			err = db.MigrateDownAll()
+			break
+		}
+		steps, convErr := strconv.Atoi(args[1])
+		if convErr != nil || steps < 1 {
+			fmt.Fprintln(os.Stderr, "down expects a positive number of steps")
+			return 2
+		}
+		err = db.MigrateDown(steps)
+	case "goto":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		version, convErr := strconv.ParseUint(args[1], 10, 32)
+		if convErr != nil {
+			fmt.Fprintln(os.Stderr, "goto expects a version number")
+			return 2
+		}
+		err = db.MigrateTo(uint(version))
+	case "force":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			fmt.Fprintln(os.Stderr, "force expects a version number")
+			return 2
+		}
+		err = db.MigrateForce(version)
+	case "version":
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		return 1
+	}
+
+	version, dirty, err := db.MigrationVersion()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		return 1
+	}
+	fmt.Printf("Schema version: %d (dirty: %t)\n", version, dirty)
+	return 0
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Johnhi19/TreeSpotter_backend/catalog"
+	"github.com/Johnhi19/TreeSpotter_backend/db"
+)
+
+// runVarietyMigration implements `migrate varieties`, which links the
+// free-text types of existing trees to the variety catalog and reports the
+// types it could not match, so they can be fixed by hand. Only exact
+// matches are linked; close ones are suggested.
+func runVarietyMigration(args []string) int {
+	flags := flag.NewFlagSet("migrate varieties", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only report what would be linked")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	varieties, err := catalog.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		return 1
+	}
+
+	conn := db.Connect(false)
+	defer db.Disconnect(conn)
+
+	return migrateVarieties(db.NewMySQLStore(conn), varieties, *dryRun)
+}
+
+func migrateVarieties(store db.VarietyStore, varieties *catalog.Catalog, dryRun bool) int {
+	counts, err := store.CountUnlinkedTreeTypes()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		return 1
+	}
+
+	linkedTrees, unmatchedTrees := 0, 0
+	unmatched := []db.TypeCount{}
+
+	for _, count := range counts {
+		variety, ok := varieties.Match(count.Type)
+		if !ok {
+			unmatched = append(unmatched, count)
+			unmatchedTrees += count.Trees
+			continue
+		}
+
+		if dryRun {
+			linkedTrees += count.Trees
+		} else {
+			linked, err := store.LinkTreeType(count.Type, variety.ID, variety.Name)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "ERROR:", err)
+				return 1
+			}
+			linkedTrees += int(linked)
+		}
+		fmt.Printf("%q (%d trees) -> %s (%d)\n", count.Type, count.Trees, variety.Name, variety.ID)
+	}
+
+	if len(unmatched) > 0 {
+		fmt.Println()
+		fmt.Println("Unmatched types:")
+		for _, count := range unmatched {
+			suggestion := ""
+			if matches := varieties.Search(count.Type, 1); len(matches) > 0 {
+				suggestion = fmt.Sprintf(", did you mean %s (%d)?", matches[0].Variety.Name, matches[0].Variety.ID)
+			}
+			fmt.Printf("  %q (%d trees)%s\n", count.Type, count.Trees, suggestion)
+		}
+	}
+
+	verb := "Linked"
+	if dryRun {
+		verb = "Would link"
+	}
+	fmt.Printf("\n%s %d trees to varieties, %d trees of %d types are unmatched\n", verb, linkedTrees, unmatchedTrees, len(unmatched))
+	return 0
+}
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// treeCSVHeader is the header of exported tree CSV files. Imports need the
+// type, plantDate, x and y columns; varietyId, latitude and longitude are
+// optional.
+var treeCSVHeader = []string{"id", "type", "varietyId", "plantDate", "x", "y", "latitude", "longitude"}
+
+// RowError is the validation error of one row of a CSV import. Rows are
+// counted including the header, so the first tree is in row 2.
+type RowError struct {
+	Row    int    `json:"row"`
+	Column string `json:"column,omitempty"`
+	Error  string `json:"error"`
+}
+
+// WriteTreesCSV writes the trees in the format ParseTreesCSV reads.
+func WriteTreesCSV(w io.Writer, trees []Tree) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(treeCSVHeader); err != nil {
+		return err
+	}
+
+	for _, tree := range trees {
+		lat, lon := "", ""
+		if tree.Coordinates != nil {
+			lat = strconv.FormatFloat(tree.Coordinates.Lat, 'f', -1, 64)
+			lon = strconv.FormatFloat(tree.Coordinates.Lon, 'f', -1, 64)
+		}
+		varietyId := ""
+		if tree.VarietyId != nil {
+			varietyId = strconv.Itoa(*tree.VarietyId)
+		}
+		record := []string{
+			strconv.Itoa(tree.ID),
+			tree.Type,
+			varietyId,
+			tree.PlantDate.Format(time.DateOnly),
+			strconv.Itoa(tree.Position.X),
+			strconv.Itoa(tree.Position.Y),
+			lat,
+			lon,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// ParseTreesCSV reads trees for the meadow from a CSV file with a header
+// row. Columns are matched by name regardless of case, spaces and
+// underscores; unknown columns are returned as ignored. Each tree is passed
+// to resolve, if given, which ties it to the variety catalog. Every invalid
+// row is reported, and an error is only returned if the file cannot be read
+// as CSV at all.
+func ParseTreesCSV(r io.Reader, meadow Meadow, resolve func(*Tree) error) ([]Tree, []RowError, []string, error) {
+	trees := []Tree{}
+	rowErrors := []RowError{}
+	ignored := []string{}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, nil, fmt.Errorf("the CSV file is empty")
+	}
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		key := csvColumnKey(name)
+		switch key {
+		case "type", "varietyid", "plantdate", "x", "y", "latitude", "longitude":
+			columns[key] = i
+		case "id":
+			// Exported IDs are not reused, new trees get new IDs
+		default:
+			ignored = append(ignored, name)
+		}
+	}
+	for _, required := range []string{"type", "plantdate", "x", "y"} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, nil, fmt.Errorf("the CSV header lacks the %s column", required)
+		}
+	}
+
+	row := 1
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		row++
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rowErrors = append(rowErrors, RowError{Row: row, Error: parseErr.Err.Error()})
+				continue
+			}
+			return nil, nil, nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+
+		tree, rowErr := parseTreeRecord(record, columns, meadow, resolve)
+		if rowErr != nil {
+			rowErr.Row = row
+			rowErrors = append(rowErrors, *rowErr)
+			continue
+		}
+		trees = append(trees, tree)
+	}
+
+	return trees, rowErrors, ignored, nil
+}
+
+func parseTreeRecord(record []string, columns map[string]int, meadow Meadow, resolve func(*Tree) error) (Tree, *RowError) {
+	field := func(key string) string {
+		i, ok := columns[key]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	tree := Tree{MeadowId: meadow.ID, Type: field("type")}
+
+	if value := field("varietyid"); value != "" {
+		varietyId, err := strconv.Atoi(value)
+		if err != nil {
+			return tree, &RowError{Column: "varietyId", Error: "varietyId must be a whole number"}
+		}
+		tree.VarietyId = &varietyId
+	}
+
+	plantDate := field("plantdate")
+	if plantDate == "" {
+		return tree, &RowError{Column: "plantDate", Error: "plantDate is required"}
+	}
+	date, err := parseDate(plantDate)
+	if err != nil {
+		return tree, &RowError{Column: "plantDate", Error: err.Error()}
+	}
+	tree.PlantDate = date
+
+	if tree.Position.X, err = strconv.Atoi(field("x")); err != nil {
+		return tree, &RowError{Column: "x", Error: "x must be a whole number"}
+	}
+	if tree.Position.Y, err = strconv.Atoi(field("y")); err != nil {
+		return tree, &RowError{Column: "y", Error: "y must be a whole number"}
+	}
+
+	lat, lon := field("latitude"), field("longitude")
+	if lat != "" || lon != "" {
+		var point LatLon
+		if point.Lat, err = strconv.ParseFloat(lat, 64); err != nil {
+			return tree, &RowError{Column: "latitude", Error: "latitude must be a number"}
+		}
+		if point.Lon, err = strconv.ParseFloat(lon, 64); err != nil {
+			return tree, &RowError{Column: "longitude", Error: "longitude must be a number"}
+		}
+		tree.Coordinates = &point
+	}
+
+	if err := tree.Validate(); err != nil {
+		return tree, &RowError{Error: err.Error()}
+	}
+	if meadow.Boundary != nil && tree.Coordinates != nil && !meadow.Boundary.Contains(*tree.Coordinates) {
+		return tree, &RowError{Error: "tree lies outside the meadow's boundary"}
+	}
+	if resolve != nil {
+		if err := resolve(&tree); err != nil {
+			return tree, &RowError{Column: "varietyId", Error: err.Error()}
+		}
+	}
+	return tree, nil
+}
+
+// csvColumnKey normalizes a header name, so "Plant Date" and "plant_date"
+// both match plantDate. A byte order mark left by spreadsheet tools is
+// dropped.
+func csvColumnKey(name string) string {
+	name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(name)
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTreesCSV(t *testing.T) {
+	input := "\ufeffID,Type,Plant Date,x,y,variety_id,Latitude,longitude,Notes\n" +
+		"17,Apple,2020-03-01,1,2,,48.5005,11.0005,south row\n" +
+		"18, Pear ,2021-04-02T10:00:00Z,3,4,5,,,\n"
+
+	trees, rowErrors, ignored, err := ParseTreesCSV(strings.NewReader(input), Meadow{ID: 9, Boundary: square}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rowErrors) != 0 {
+		t.Fatalf("unexpected row errors: %v", rowErrors)
+	}
+	if !slices.Equal(ignored, []string{"Notes"}) {
+		t.Errorf("ignored = %v, want [Notes]", ignored)
+	}
+	if len(trees) != 2 {
+		t.Fatalf("got %d trees, want 2", len(trees))
+	}
+
+	apple, pear := trees[0], trees[1]
+	if apple.ID != 0 || apple.MeadowId != 9 || apple.Type != "Apple" || apple.Position != (Position{X: 1, Y: 2}) {
+		t.Errorf("apple = %+v", apple)
+	}
+	if apple.Coordinates == nil || *apple.Coordinates != (LatLon{Lat: 48.5005, Lon: 11.0005}) {
+		t.Errorf("apple coordinates = %v", apple.Coordinates)
+	}
+	if pear.Type != "Pear" || pear.VarietyId == nil || *pear.VarietyId != 5 || pear.Coordinates != nil {
+		t.Errorf("pear = %+v", pear)
+	}
+	if !pear.PlantDate.Equal(time.Date(2021, 4, 2, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("pear plant date = %s", pear.PlantDate)
+	}
+}
+
+func TestParseTreesCSVRowErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		row    string
+		column string
+	}{
+		{"missing plant date", "Apple,,1,2,,,", "plantDate"},
+		{"invalid plant date", "Apple,1.3.2020,1,2,,,", "plantDate"},
+		{"x not a number", "Apple,2020-03-01,a,2,,,", "x"},
+		{"y not a whole number", "Apple,2020-03-01,1,2.5,,,", "y"},
+		{"variety not a number", "Apple,2020-03-01,1,2,x,,", "varietyId"},
+		{"latitude without longitude", "Apple,2020-03-01,1,2,,48.5,", "longitude"},
+		{"latitude not a number", "Apple,2020-03-01,1,2,,north,11", "latitude"},
+		{"latitude out of range", "Apple,2020-03-01,1,2,,95,11", ""},
+		{"outside the boundary", "Apple,2020-03-01,1,2,,48.6,11.0005", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := "type,plantDate,x,y,varietyId,latitude,longitude\n" +
+				"Pear,2020-03-01,0,0,,,\n" + tt.row + "\n"
+			trees, rowErrors, _, err := ParseTreesCSV(strings.NewReader(input), Meadow{Boundary: square}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(trees) != 1 {
+				t.Errorf("got %d trees, want only the valid one", len(trees))
+			}
+			if len(rowErrors) != 1 || rowErrors[0].Row != 3 || rowErrors[0].Column != tt.column {
+				t.Errorf("row errors = %+v, want one in row 3, column %q", rowErrors, tt.column)
+			}
+		})
+	}
+}
+
+func TestParseTreesCSVFileErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		contains string
+	}{
+		{"empty", "", "empty"},
+		{"missing column", "type,plantDate,x\nApple,2020-03-01,1\n", "lacks the y column"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, err := ParseTreesCSV(strings.NewReader(tt.input), Meadow{}, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.contains)
+			}
+		})
+	}
+}
+
+func TestTreesCSVRoundTrip(t *testing.T) {
+	varietyId := 4
+	trees := []Tree{
+		{ID: 1, MeadowId: 2, Type: "Apple, Boskoop", PlantDate: time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC), Position: Position{X: 1, Y: -2}, VarietyId: &varietyId},
+		{ID: 2, MeadowId: 2, Type: "Pear", PlantDate: time.Date(2021, 4, 2, 0, 0, 0, 0, time.UTC), Coordinates: &LatLon{Lat: 48.5005, Lon: 11.0005}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteTreesCSV(&buf, trees); err != nil {
+		t.Fatal(err)
+	}
+	parsed, rowErrors, ignored, err := ParseTreesCSV(&buf, Meadow{ID: 2}, nil)
+	if err != nil || len(rowErrors) != 0 || len(ignored) != 0 {
+		t.Fatalf("ParseTreesCSV() = %v, %v, %v", err, rowErrors, ignored)
+	}
+	if len(parsed) != len(trees) {
+		t.Fatalf("got %d trees, want %d", len(parsed), len(trees))
+	}
+
+	for i, tree := range parsed {
+		want := trees[i]
+		if tree.Type != want.Type || tree.Position != want.Position || !tree.PlantDate.Equal(want.PlantDate) ||
+			(tree.VarietyId == nil) != (want.VarietyId == nil) || (tree.Coordinates == nil) != (want.Coordinates == nil) {
+			t.Errorf("tree %d = %+v, want %+v", i, tree, want)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package models
+
+// DeletionReport lists everything a cascading delete removed. Stores list
+// the files of deleted images in Files; once they are removed, Files keeps
+// those that were and FailedFiles those that could not be.
+type DeletionReport struct {
+	MeadowIds   []int    `json:"meadowIds"`
+	TreeIds     []int    `json:"treeIds"`
+	ImageIds    []int    `json:"imageIds"`
+	Files       []string `json:"files"`
+	FailedFiles []string `json:"failedFiles,omitempty"`
+}
+
+// AccountMeadow is a personal meadow that deleting its only owner's account
+// deletes for good, with all of its trees and images, including those in
+// the trash.
+type AccountMeadow struct {
+	Meadow Meadow
+	Trees  []Tree
+	Images []Image
+}
@@ -0,0 +1,159 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// earthRadius is the WGS84 equatorial radius in meters.
+const earthRadius = 6378137.0
+
+// LatLon is a WGS84 coordinate in degrees.
+type LatLon struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// Polygon is a closed boundary given by its corners in order. The last
+// corner connects back to the first one and is not repeated.
+type Polygon []LatLon
+
+// GridOrigin places a meadow's X/Y grid on the map. The grid's origin lies
+// at Origin, its Y axis points Bearing degrees clockwise from north, its X
+// axis 90 degrees further clockwise, and one grid unit is CellSize meters.
+type GridOrigin struct {
+	Origin   LatLon  `json:"origin"`
+	Bearing  float64 `json:"bearing"`
+	CellSize float64 `json:"cellSize"`
+}
+
+func (p LatLon) Validate() error {
+	if math.IsNaN(p.Lat) || p.Lat < -90 || p.Lat > 90 {
+		return fmt.Errorf("latitude %v is out of range", p.Lat)
+	}
+	if math.IsNaN(p.Lon) || p.Lon < -180 || p.Lon > 180 {
+		return fmt.Errorf("longitude %v is out of range", p.Lon)
+	}
+	return nil
+}
+
+func (p Polygon) Validate() error {
+	if len(p) < 3 {
+		return fmt.Errorf("a boundary needs at least 3 corners")
+	}
+	for _, corner := range p {
+		if err := corner.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Contains reports whether the point lies inside the polygon. Points on the
+// edge may go either way. Meadows are small enough to treat degrees as
+// planar coordinates.
+func (p Polygon) Contains(point LatLon) bool {
+	inside := false
+	for i, j := 0, len(p)-1; i < len(p); j, i = i, i+1 {
+		a, b := p[i], p[j]
+		if (a.Lat > point.Lat) != (b.Lat > point.Lat) &&
+			point.Lon < (b.Lon-a.Lon)*(point.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Area returns the area enclosed by the polygon in square meters, measured
+// on a sphere with the WGS84 equatorial radius.
+func (p Polygon) Area() float64 {
+	if len(p) < 3 {
+		return 0
+	}
+
+	sum := 0.0
+	for i := range p {
+		prev := p[(i+len(p)-1)%len(p)]
+		next := p[(i+1)%len(p)]
+		sum += radians(next.Lon-prev.Lon) * math.Sin(radians(p[i].Lat))
+	}
+	return math.Abs(sum) * earthRadius * earthRadius / 2
+}
+
+func (p *Polygon) Scan(value any) error {
+	if value == nil {
+		*p = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to convert boundary to []byte")
+	}
+	return json.Unmarshal(bytes, p)
+}
+
+func (p Polygon) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+	return json.Marshal([]LatLon(p))
+}
+
+func (g GridOrigin) Validate() error {
+	if err := g.Origin.Validate(); err != nil {
+		return err
+	}
+	if g.CellSize <= 0 {
+		return fmt.Errorf("cellSize must be positive")
+	}
+	return nil
+}
+
+// ToLatLon converts a grid position to coordinates.
+func (g GridOrigin) ToLatLon(pos Position) LatLon {
+	sin, cos := math.Sincos(radians(g.Bearing))
+	x, y := float64(pos.X)*g.CellSize, float64(pos.Y)*g.CellSize
+
+	east := x*cos + y*sin
+	north := -x*sin + y*cos
+
+	return LatLon{
+		Lat: g.Origin.Lat + degrees(north/earthRadius),
+		Lon: g.Origin.Lon + degrees(east/(earthRadius*math.Cos(radians(g.Origin.Lat)))),
+	}
+}
+
+// ToPosition converts coordinates to the nearest grid position.
+func (g GridOrigin) ToPosition(point LatLon) Position {
+	sin, cos := math.Sincos(radians(g.Bearing))
+
+	north := radians(point.Lat-g.Origin.Lat) * earthRadius
+	east := radians(point.Lon-g.Origin.Lon) * earthRadius * math.Cos(radians(g.Origin.Lat))
+
+	return Position{
+		X: int(math.Round((east*cos - north*sin) / g.CellSize)),
+		Y: int(math.Round((east*sin + north*cos) / g.CellSize)),
+	}
+}
+
+func (g *GridOrigin) Scan(value any) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to convert grid to []byte")
+	}
+	return json.Unmarshal(bytes, g)
+}
+
+func (g GridOrigin) Value() (driver.Value, error) {
+	return json.Marshal(g)
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+// square is a meadow of about 111 by 73 meters at 48.5° north
+var square = Polygon{
+	{Lat: 48.500, Lon: 11.000},
+	{Lat: 48.500, Lon: 11.001},
+	{Lat: 48.501, Lon: 11.001},
+	{Lat: 48.501, Lon: 11.000},
+}
+
+func TestPolygonContains(t *testing.T) {
+	tests := []struct {
+		name  string
+		point LatLon
+		want  bool
+	}{
+		{"center", LatLon{Lat: 48.5005, Lon: 11.0005}, true},
+		{"near a corner", LatLon{Lat: 48.50001, Lon: 11.00099}, true},
+		{"north of it", LatLon{Lat: 48.502, Lon: 11.0005}, false},
+		{"west of it", LatLon{Lat: 48.5005, Lon: 10.999}, false},
+		{"far away", LatLon{Lat: -33.9, Lon: 151.2}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := square.Contains(tt.point); got != tt.want {
+				t.Errorf("Contains(%v) = %t, want %t", tt.point, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolygonArea(t *testing.T) {
+	// 0.001° of latitude is 111.32 m, 0.001° of longitude 111.32 m * cos(48.5°)
+	want := 111.32 * 111.32 * math.Cos(radians(48.5005))
+	if got := square.Area(); math.Abs(got-want) > want*0.01 {
+		t.Errorf("Area() = %.1f, want about %.1f", got, want)
+	}
+
+	reversed := Polygon{square[3], square[2], square[1], square[0]}
+	if got, want := reversed.Area(), square.Area(); math.Abs(got-want) > 1e-6 {
+		t.Errorf("Area() of the reversed polygon = %f, want %f", got, want)
+	}
+
+	if got := (Polygon{square[0], square[1]}).Area(); got != 0 {
+		t.Errorf("Area() of a line = %f, want 0", got)
+	}
+}
+
+func TestPolygonValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		polygon Polygon
+		valid   bool
+	}{
+		{"square", square, true},
+		{"two corners", square[:2], false},
+		{"latitude out of range", Polygon{{Lat: 91, Lon: 0}, {Lat: 0, Lon: 1}, {Lat: 1, Lon: 1}}, false},
+		{"longitude out of range", Polygon{{Lat: 0, Lon: 0}, {Lat: 0, Lon: 181}, {Lat: 1, Lon: 1}}, false},
+		{"not a number", Polygon{{Lat: math.NaN(), Lon: 0}, {Lat: 0, Lon: 1}, {Lat: 1, Lon: 1}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.polygon.Validate(); (err == nil) != tt.valid {
+				t.Errorf("Validate() = %v, want valid %t", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestGridOriginDistances(t *testing.T) {
+	tests := []struct {
+		name     string
+		grid     GridOrigin
+		position Position
+		// meters north and east of the origin
+		north, east float64
+	}{
+		{"origin", GridOrigin{Origin: square[0], CellSize: 5}, Position{X: 0, Y: 0}, 0, 0},
+		{"north along Y", GridOrigin{Origin: square[0], CellSize: 5}, Position{X: 0, Y: 4}, 20, 0},
+		{"east along X", GridOrigin{Origin: square[0], CellSize: 5}, Position{X: 3, Y: 0}, 0, 15},
+		{"turned east", GridOrigin{Origin: square[0], Bearing: 90, CellSize: 2}, Position{X: 0, Y: 10}, 0, 20},
+		{"turned south", GridOrigin{Origin: square[0], Bearing: 180, CellSize: 1}, Position{X: 7, Y: 7}, -7, -7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			point := tt.grid.ToLatLon(tt.position)
+			north := radians(point.Lat-tt.grid.Origin.Lat) * earthRadius
+			east := radians(point.Lon-tt.grid.Origin.Lon) * earthRadius * math.Cos(radians(tt.grid.Origin.Lat))
+			if math.Abs(north-tt.north) > 0.01 || math.Abs(east-tt.east) > 0.01 {
+				t.Errorf("ToLatLon(%v) lies %.2f m north and %.2f m east, want %.2f and %.2f", tt.position, north, east, tt.north, tt.east)
+			}
+
+			if got := tt.grid.ToPosition(point); got != tt.position {
+				t.Errorf("ToPosition(ToLatLon(%v)) = %v", tt.position, got)
+			}
+		})
+	}
+}
+
+func TestGridOriginValidate(t *testing.T) {
+	if err := (GridOrigin{Origin: square[0], CellSize: 1}).Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+	if err := (GridOrigin{Origin: square[0]}).Validate(); err == nil {
+		t.Error("Validate() without a cell size succeeded")
+	}
+	if err := (GridOrigin{Origin: LatLon{Lat: 100}, CellSize: 1}).Validate(); err == nil {
+		t.Error("Validate() with an invalid origin succeeded")
+	}
+}
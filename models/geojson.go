@@ -0,0 +1,322 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// FeatureCollection is a GeoJSON (RFC 7946) feature collection. A meadow is
+// exported as one Polygon feature and its trees as Point features, told
+// apart by their "kind" property.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+type Feature struct {
+	Type       string          `json:"type"`
+	Geometry   *Geometry       `json:"geometry"`
+	Properties json.RawMessage `json:"properties"`
+}
+
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// FeatureError is the validation error of one feature of an import.
+type FeatureError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// Feature kinds.
+const (
+	KindMeadow = "meadow"
+	KindTree   = "tree"
+)
+
+type meadowProperties struct {
+	Kind     string      `json:"kind"`
+	ID       int         `json:"id,omitempty"`
+	Name     string      `json:"name"`
+	Location string      `json:"location"`
+	Size     IntSlize    `json:"size"`
+	Area     float64     `json:"areaSquareMeters,omitempty"`
+	Grid     *GridOrigin `json:"grid,omitempty"`
+}
+
+type treeProperties struct {
+	Kind      string    `json:"kind"`
+	ID        int       `json:"id,omitempty"`
+	Type      string    `json:"type"`
+	VarietyId *int      `json:"varietyId,omitempty"`
+	PlantDate string    `json:"plantDate"`
+	Position  *Position `json:"position,omitempty"`
+}
+
+// MeadowFeatureCollection exports the meadow with its trees. Trees without
+// coordinates are placed through the meadow's grid if it has one and are
+// exported without a geometry otherwise, as is a meadow without boundary.
+func MeadowFeatureCollection(meadow Meadow, trees []Tree) (FeatureCollection, error) {
+	fc := FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+
+	size := meadow.Size
+	if size == nil {
+		size = IntSlize{}
+	}
+	feature, err := newFeature(polygonGeometry(meadow.Boundary), meadowProperties{
+		Kind:     KindMeadow,
+		ID:       meadow.ID,
+		Name:     meadow.Name,
+		Location: meadow.Location,
+		Size:     size,
+		Area:     meadow.Area,
+		Grid:     meadow.Grid,
+	})
+	if err != nil {
+		return fc, err
+	}
+	fc.Features = append(fc.Features, feature)
+
+	for _, tree := range trees {
+		point := tree.Coordinates
+		if point == nil && meadow.Grid != nil {
+			converted := meadow.Grid.ToLatLon(tree.Position)
+			point = &converted
+		}
+		position := tree.Position
+		feature, err := newFeature(pointGeometry(point), treeProperties{
+			Kind:      KindTree,
+			ID:        tree.ID,
+			Type:      tree.Type,
+			VarietyId: tree.VarietyId,
+			PlantDate: tree.PlantDate.Format(time.RFC3339),
+			Position:  &position,
+		})
+		if err != nil {
+			return fc, err
+		}
+		fc.Features = append(fc.Features, feature)
+	}
+	return fc, nil
+}
+
+// ParseMeadowFeatureCollection reads a meadow and its trees from a feature
+// collection. It expects exactly one meadow, given as a Polygon feature or
+// by its "kind" property, while all other features are trees. IDs in the
+// properties are ignored. Trees without a position are placed through the
+// meadow's grid if it has one, and trees are passed to resolve, if given, to
+// tie them to the variety catalog. Every invalid feature is reported.
+func ParseMeadowFeatureCollection(fc FeatureCollection, resolve func(*Tree) error) (Meadow, []Tree, []FeatureError) {
+	var meadow Meadow
+	trees := []Tree{}
+	errs := []FeatureError{}
+
+	if fc.Type != "FeatureCollection" {
+		return meadow, nil, []FeatureError{{Index: -1, Error: "expected a FeatureCollection"}}
+	}
+
+	meadowIndex := -1
+	for i, feature := range fc.Features {
+		if !isMeadowFeature(feature) {
+			continue
+		}
+		if meadowIndex >= 0 {
+			errs = append(errs, FeatureError{Index: i, Error: fmt.Sprintf("only one meadow can be imported, found another one at %d", meadowIndex)})
+			continue
+		}
+		meadowIndex = i
+
+		parsed, err := parseMeadowFeature(feature)
+		if err != nil {
+			errs = append(errs, FeatureError{Index: i, Error: err.Error()})
+			continue
+		}
+		meadow = parsed
+	}
+	if meadowIndex < 0 {
+		errs = append(errs, FeatureError{Index: -1, Error: "no meadow feature found"})
+	}
+
+	for i, feature := range fc.Features {
+		if i == meadowIndex || isMeadowFeature(feature) {
+			continue
+		}
+		tree, err := parseTreeFeature(feature, meadow, resolve)
+		if err != nil {
+			errs = append(errs, FeatureError{Index: i, Error: err.Error()})
+			continue
+		}
+		trees = append(trees, tree)
+	}
+
+	return meadow, trees, errs
+}
+
+func isMeadowFeature(feature Feature) bool {
+	var props struct {
+		Kind string `json:"kind"`
+	}
+	if len(feature.Properties) > 0 {
+		_ = json.Unmarshal(feature.Properties, &props)
+	}
+	if props.Kind != "" {
+		return props.Kind == KindMeadow
+	}
+	return feature.Geometry != nil && feature.Geometry.Type == "Polygon"
+}
+
+func parseMeadowFeature(feature Feature) (Meadow, error) {
+	var props meadowProperties
+	if err := parseProperties(feature, &props); err != nil {
+		return Meadow{}, err
+	}
+
+	meadow := Meadow{Name: props.Name, Location: props.Location, Size: props.Size, Grid: props.Grid}
+	if meadow.Size == nil {
+		meadow.Size = IntSlize{}
+	}
+	if feature.Geometry != nil {
+		if feature.Geometry.Type != "Polygon" {
+			return meadow, fmt.Errorf("a meadow must be a Polygon, not a %s", feature.Geometry.Type)
+		}
+		var rings [][][]float64
+		if err := json.Unmarshal(feature.Geometry.Coordinates, &rings); err != nil || len(rings) == 0 {
+			return meadow, fmt.Errorf("invalid Polygon coordinates")
+		}
+		// Only the outer ring is kept, holes are not supported
+		for _, position := range rings[0] {
+			point, err := parsePosition(position)
+			if err != nil {
+				return meadow, err
+			}
+			meadow.Boundary = append(meadow.Boundary, point)
+		}
+		if n := len(meadow.Boundary); n > 1 && meadow.Boundary[0] == meadow.Boundary[n-1] {
+			meadow.Boundary = meadow.Boundary[:n-1]
+		}
+	}
+	meadow.Area = meadow.Boundary.Area()
+
+	return meadow, meadow.Validate()
+}
+
+func parseTreeFeature(feature Feature, meadow Meadow, resolve func(*Tree) error) (Tree, error) {
+	var props treeProperties
+	if err := parseProperties(feature, &props); err != nil {
+		return Tree{}, err
+	}
+
+	tree := Tree{Type: props.Type, VarietyId: props.VarietyId}
+	if props.PlantDate == "" {
+		return tree, fmt.Errorf("plantDate is required")
+	}
+	plantDate, err := parseDate(props.PlantDate)
+	if err != nil {
+		return tree, err
+	}
+	tree.PlantDate = plantDate
+
+	if feature.Geometry != nil {
+		if feature.Geometry.Type != "Point" {
+			return tree, fmt.Errorf("a tree must be a Point, not a %s", feature.Geometry.Type)
+		}
+		var position []float64
+		if err := json.Unmarshal(feature.Geometry.Coordinates, &position); err != nil {
+			return tree, fmt.Errorf("invalid Point coordinates")
+		}
+		point, err := parsePosition(position)
+		if err != nil {
+			return tree, err
+		}
+		tree.Coordinates = &point
+	}
+
+	switch {
+	case props.Position != nil:
+		tree.Position = *props.Position
+	case tree.Coordinates != nil && meadow.Grid != nil:
+		tree.Position = meadow.Grid.ToPosition(*tree.Coordinates)
+	case tree.Coordinates == nil:
+		return tree, fmt.Errorf("a tree needs a Point geometry or a position")
+	}
+
+	if err := tree.Validate(); err != nil {
+		return tree, err
+	}
+	if meadow.Boundary != nil && tree.Coordinates != nil && !meadow.Boundary.Contains(*tree.Coordinates) {
+		return tree, fmt.Errorf("tree lies outside the meadow's boundary")
+	}
+	if resolve != nil {
+		if err := resolve(&tree); err != nil {
+			return tree, err
+		}
+	}
+	return tree, nil
+}
+
+func parseProperties(feature Feature, props any) error {
+	if feature.Type != "Feature" {
+		return fmt.Errorf("expected a Feature, not %q", feature.Type)
+	}
+	if len(feature.Properties) == 0 || string(feature.Properties) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(feature.Properties, props); err != nil {
+		return fmt.Errorf("invalid properties: %v", err)
+	}
+	return nil
+}
+
+// parsePosition reads a GeoJSON position, which is longitude first.
+func parsePosition(position []float64) (LatLon, error) {
+	if len(position) < 2 {
+		return LatLon{}, fmt.Errorf("a position needs a longitude and a latitude")
+	}
+	point := LatLon{Lat: position[1], Lon: position[0]}
+	return point, point.Validate()
+}
+
+// parseDate accepts a plain date as well as an RFC 3339 time.
+func parseDate(value string) (time.Time, error) {
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		return date, nil
+	}
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return date, fmt.Errorf("invalid date %q, expected 2006-01-02 or an RFC 3339 time", value)
+	}
+	return date, nil
+}
+
+func newFeature(geometry *Geometry, props any) (Feature, error) {
+	raw, err := json.Marshal(props)
+	if err != nil {
+		return Feature{}, fmt.Errorf("failed to marshal feature properties: %w", err)
+	}
+	return Feature{Type: "Feature", Geometry: geometry, Properties: raw}, nil
+}
+
+func pointGeometry(point *LatLon) *Geometry {
+	if point == nil {
+		return nil
+	}
+	coordinates, _ := json.Marshal([]float64{point.Lon, point.Lat})
+	return &Geometry{Type: "Point", Coordinates: coordinates}
+}
+
+// polygonGeometry closes the boundary's ring, as GeoJSON requires.
+func polygonGeometry(boundary Polygon) *Geometry {
+	if len(boundary) == 0 {
+		return nil
+	}
+	ring := make([][]float64, 0, len(boundary)+1)
+	for _, corner := range boundary {
+		ring = append(ring, []float64{corner.Lon, corner.Lat})
+	}
+	ring = append(ring, []float64{boundary[0].Lon, boundary[0].Lat})
+	coordinates, _ := json.Marshal([][][]float64{ring})
+	return &Geometry{Type: "Polygon", Coordinates: coordinates}
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseCollection(t *testing.T, raw string) FeatureCollection {
+	t.Helper()
+	var fc FeatureCollection
+	if err := json.Unmarshal([]byte(raw), &fc); err != nil {
+		t.Fatalf("invalid test collection: %v", err)
+	}
+	return fc
+}
+
+const squareFeature = `{"type": "Feature",
+	"geometry": {"type": "Polygon", "coordinates": [[[11.000, 48.500], [11.001, 48.500], [11.001, 48.501], [11.000, 48.501], [11.000, 48.500]]]},
+	"properties": {"name": "Orchard", "location": "Hill", "grid": {"origin": {"lat": 48.5, "lon": 11}, "bearing": 0, "cellSize": 5}}}`
+
+func TestParseMeadowFeatureCollection(t *testing.T) {
+	fc := parseCollection(t, `{"type": "FeatureCollection", "features": [`+squareFeature+`,
+		{"type": "Feature", "geometry": {"type": "Point", "coordinates": [11.0005, 48.5005]}, "properties": {"type": "Apple", "plantDate": "2020-03-01"}},
+		{"type": "Feature", "geometry": null, "properties": {"kind": "tree", "type": "Pear", "plantDate": "2021-04-02T10:00:00Z", "position": {"x": 2, "y": 3}}}
+	]}`)
+
+	meadow, trees, errs := ParseMeadowFeatureCollection(fc, nil)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if meadow.Name != "Orchard" || meadow.Location != "Hill" {
+		t.Errorf("meadow = %q at %q, want Orchard at Hill", meadow.Name, meadow.Location)
+	}
+	if len(meadow.Boundary) != 4 {
+		t.Errorf("boundary has %d corners, want the closing one dropped", len(meadow.Boundary))
+	}
+	if meadow.Area <= 0 {
+		t.Errorf("area = %f, want it derived from the boundary", meadow.Area)
+	}
+
+	if len(trees) != 2 {
+		t.Fatalf("got %d trees, want 2", len(trees))
+	}
+	apple, pear := trees[0], trees[1]
+	if apple.Coordinates == nil || *apple.Coordinates != (LatLon{Lat: 48.5005, Lon: 11.0005}) {
+		t.Errorf("apple coordinates = %v, want longitude first", apple.Coordinates)
+	}
+	if want := meadow.Grid.ToPosition(*apple.Coordinates); apple.Position != want {
+		t.Errorf("apple position = %v, want %v from the grid", apple.Position, want)
+	}
+	if !apple.PlantDate.Equal(time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("apple plant date = %s", apple.PlantDate)
+	}
+	if pear.Coordinates != nil || pear.Position != (Position{X: 2, Y: 3}) {
+		t.Errorf("pear = %v at %v, want position 2/3 without coordinates", pear.Coordinates, pear.Position)
+	}
+}
+
+func TestParseMeadowFeatureCollectionErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		features string
+		index    int
+		contains string
+	}{
+		{"no meadow", `{"type": "Feature", "geometry": {"type": "Point", "coordinates": [11, 48]}, "properties": {"plantDate": "2020-01-01"}}`,
+			-1, "no meadow"},
+		{"two meadows", squareFeature + `, ` + squareFeature, 1, "only one meadow"},
+		{"meadow not a polygon", `{"type": "Feature", "geometry": {"type": "Point", "coordinates": [11, 48]}, "properties": {"kind": "meadow"}}`,
+			0, "must be a Polygon"},
+		{"tree without plant date", squareFeature + `, {"type": "Feature", "geometry": {"type": "Point", "coordinates": [11.0005, 48.5005]}, "properties": {}}`,
+			1, "plantDate is required"},
+		{"tree with invalid date", squareFeature + `, {"type": "Feature", "geometry": {"type": "Point", "coordinates": [11.0005, 48.5005]}, "properties": {"plantDate": "01.03.2020"}}`,
+			1, "invalid date"},
+		{"tree outside the boundary", squareFeature + `, {"type": "Feature", "geometry": {"type": "Point", "coordinates": [12, 48.5005]}, "properties": {"plantDate": "2020-01-01"}}`,
+			1, "outside the meadow"},
+		{"tree latitude out of range", squareFeature + `, {"type": "Feature", "geometry": {"type": "Point", "coordinates": [11, 95]}, "properties": {"plantDate": "2020-01-01"}}`,
+			1, "latitude"},
+		{"tree without a place", squareFeature + `, {"type": "Feature", "geometry": null, "properties": {"kind": "tree", "plantDate": "2020-01-01"}}`,
+			1, "needs a Point geometry or a position"},
+		{"tree as a line", squareFeature + `, {"type": "Feature", "geometry": {"type": "LineString", "coordinates": [[11, 48], [11, 49]]}, "properties": {"plantDate": "2020-01-01"}}`,
+			1, "must be a Point"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc := parseCollection(t, `{"type": "FeatureCollection", "features": [`+tt.features+`]}`)
+			_, _, errs := ParseMeadowFeatureCollection(fc, nil)
+			if len(errs) != 1 {
+				t.Fatalf("got errors %v, want exactly one", errs)
+			}
+			if errs[0].Index != tt.index || !strings.Contains(errs[0].Error, tt.contains) {
+				t.Errorf("got error %+v, want %q at index %d", errs[0], tt.contains, tt.index)
+			}
+		})
+	}
+}
+
+func TestParseMeadowFeatureCollectionResolve(t *testing.T) {
+	fc := parseCollection(t, `{"type": "FeatureCollection", "features": [`+squareFeature+`,
+		{"type": "Feature", "geometry": null, "properties": {"kind": "tree", "type": "Apple", "plantDate": "2020-01-01", "position": {"x": 1, "y": 1}}},
+		{"type": "Feature", "geometry": null, "properties": {"kind": "tree", "type": "Unknown", "plantDate": "2020-01-01", "position": {"x": 2, "y": 2}}}
+	]}`)
+
+	_, trees, errs := ParseMeadowFeatureCollection(fc, func(tree *Tree) error {
+		if tree.Type != "Apple" {
+			return errors.New("unknown variety")
+		}
+		id := 7
+		tree.VarietyId = &id
+		return nil
+	})
+	if len(trees) != 1 || trees[0].VarietyId == nil || *trees[0].VarietyId != 7 {
+		t.Errorf("trees = %+v, want the apple tied to variety 7", trees)
+	}
+	if len(errs) != 1 || errs[0].Index != 2 {
+		t.Errorf("errors = %v, want the unknown variety at index 2", errs)
+	}
+}
+
+func TestMeadowFeatureCollectionRoundTrip(t *testing.T) {
+	grid := &GridOrigin{Origin: square[0], CellSize: 5}
+	meadow := Meadow{ID: 3, Name: "Orchard", Size: IntSlize{20, 30}, Boundary: square, Grid: grid}
+	trees := []Tree{
+		{ID: 1, Type: "Apple", PlantDate: time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC), Position: Position{X: 2, Y: 2}},
+		{ID: 2, Type: "Pear", PlantDate: time.Date(2021, 4, 2, 0, 0, 0, 0, time.UTC), Coordinates: &LatLon{Lat: 48.5008, Lon: 11.0002}, Position: Position{X: 3, Y: 18}},
+	}
+
+	fc, err := MeadowFeatureCollection(meadow, trees)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(fc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, parsedTrees, errs := ParseMeadowFeatureCollection(parseCollection(t, string(raw)), nil)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if parsed.Name != meadow.Name || len(parsed.Boundary) != len(square) || *parsed.Grid != *grid {
+		t.Errorf("meadow = %+v, want %+v", parsed, meadow)
+	}
+	if len(parsedTrees) != len(trees) {
+		t.Fatalf("got %d trees, want %d", len(parsedTrees), len(trees))
+	}
+	for i, tree := range parsedTrees {
+		if tree.Type != trees[i].Type || tree.Position != trees[i].Position || !tree.PlantDate.Equal(trees[i].PlantDate) {
+			t.Errorf("tree %d = %+v, want %+v", i, tree, trees[i])
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// UnitKilograms converts the units harvests are weighed in to kilograms,
+// which is what yield statistics are reported in.
+var UnitKilograms = map[string]float64{
+	"g":  0.001,
+	"kg": 1,
+	"t":  1000,
+	"lb": 0.45359237,
+}
+
+// Grades are the quality grades a harvest can be sorted into. A harvest
+// without a grade is left ungraded.
+var Grades = []string{
+	"premium",
+	"class_1",
+	"class_2",
+	"processing",
+}
+
+// Harvest is the weighed yield of one tree on one day.
+type Harvest struct {
+	ID       int       `json:"id"`
+	TreeId   int       `json:"treeId"`
+	Date     time.Time `json:"date"`
+	Quantity float64   `json:"quantity"`
+	Unit     string    `json:"unit"`
+	Grade    string    `json:"grade"`
+	Notes    string    `json:"notes"`
+}
+
+// YieldStat is the summed yield of one tree, variety or meadow in a year.
+// Only the field of the grouping is set, apart from trees, which also
+// carry their meadow and variety.
+type YieldStat struct {
+	Year       int     `json:"year"`
+	TreeId     int     `json:"treeId,omitempty"`
+	MeadowId   int     `json:"meadowId,omitempty"`
+	Variety    *string `json:"variety,omitempty"`
+	Harvests   int     `json:"harvests"`
+	QuantityKg float64 `json:"quantityKg"`
+}
+
+func (h Harvest) Validate() error {
+	if h.Date.IsZero() {
+		return fmt.Errorf("date is required")
+	}
+	if h.Quantity <= 0 {
+		return fmt.Errorf("quantity must be positive")
+	}
+	if _, ok := UnitKilograms[h.Unit]; !ok {
+		return fmt.Errorf("unknown unit %q", h.Unit)
+	}
+	if h.Grade != "" && !slices.Contains(Grades, h.Grade) {
+		return fmt.Errorf("unknown grade %q", h.Grade)
+	}
+	return nil
+}
+
+// QuantityKg is the harvested quantity in kilograms.
+func (h Harvest) QuantityKg() float64 {
+	return h.Quantity * UnitKilograms[h.Unit]
+}
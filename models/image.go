@@ -2,9 +2,59 @@ package models
 
 import "time"
 
+// Variants of an image: the uploaded file and its resized JPEG copies
+const (
+	VariantOriginal  = "original"
+	VariantMedium    = "medium"
+	VariantThumbnail = "thumbnail"
+)
+
 type Image struct {
-	ID          int       `json:"id"`
-	Path        string    `json:"path"`
-	Description string    `json:"description"`
-	Datetime    time.Time `json:"datetime"`
+	ID            int               `json:"id"`
+	TreeId        int               `json:"treeId"`
+	Path          string            `json:"path"`
+	ThumbnailPath string            `json:"-"` // clients load the resized copies through URLs
+	MediumPath    string            `json:"-"`
+	SHA256        string            `json:"sha256,omitempty"`
+	UploadSHA256  string            `json:"-"`
+	Description   string            `json:"description"`
+	Datetime      time.Time         `json:"datetime"`
+	URL           string            `json:"url,omitempty"`
+	URLs          map[string]string `json:"urls,omitempty"`
+	Metadata      *ImageMetadata    `json:"metadata,omitempty"`
+	DeletedAt     *time.Time        `json:"deletedAt,omitempty"`
+}
+
+// ImageMetadata holds what the EXIF data of an uploaded photo tells about
+// it. Its capture time becomes the image's Datetime instead.
+type ImageMetadata struct {
+	Coordinates *LatLon `json:"coordinates,omitempty"`
+	CameraModel string  `json:"cameraModel,omitempty"`
+	Orientation int     `json:"orientation,omitempty"`
+}
+
+// Variants returns the paths of the image's files by variant. Images
+// uploaded before variants were generated only have the original.
+func (i Image) Variants() map[string]string {
+	variants := map[string]string{VariantOriginal: i.Path}
+	if i.MediumPath != "" {
+		variants[VariantMedium] = i.MediumPath
+	}
+	if i.ThumbnailPath != "" {
+		variants[VariantThumbnail] = i.ThumbnailPath
+	}
+	return variants
+}
+
+// ImageUploadResult reports what became of one file of an upload. Status
+// is the HTTP status the file would have been answered with on its own;
+// Code and Error are set if it was not stored.
+type ImageUploadResult struct {
+	File      string `json:"file"`
+	Status    int    `json:"status"`
+	ID        int    `json:"id,omitempty"`
+	Path      string `json:"path,omitempty"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+	Code      string `json:"code,omitempty"`
+	Error     string `json:"error,omitempty"`
 }
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Diseases and Pests are the fixed vocabularies inspections are recorded in.
+var (
+	Diseases = []string{
+		"apple_scab",
+		"bacterial_canker",
+		"brown_rot",
+		"collar_rot",
+		"fire_blight",
+		"leaf_curl",
+		"mistletoe",
+		"nectria_canker",
+		"powdery_mildew",
+		"rust",
+		"shot_hole",
+		"wood_rot",
+	}
+	Pests = []string{
+		"aphids",
+		"codling_moth",
+		"plum_moth",
+		"sawfly",
+		"scale_insects",
+		"spider_mites",
+		"voles",
+		"winter_moth",
+		"woolly_aphid",
+	}
+)
+
+// Vitality scores range from dead to fully vital.
+const (
+	MinVitality = 0
+	MaxVitality = 5
+)
+
+// TreeInspection is one recorded health check of a tree.
+type TreeInspection struct {
+	ID        int         `json:"id"`
+	TreeId    int         `json:"treeId"`
+	Date      time.Time   `json:"date"`
+	Inspector string      `json:"inspector"`
+	Vitality  int         `json:"vitality"`
+	Diseases  StringSlice `json:"diseases"`
+	Pests     StringSlice `json:"pests"`
+	Notes     string      `json:"notes"`
+	ImageIds  IntSlize    `json:"imageIds"`
+}
+
+// TreeCondition is the outcome of a tree's latest inspection.
+type TreeCondition struct {
+	InspectionId int         `json:"inspectionId"`
+	Date         time.Time   `json:"date"`
+	Vitality     int         `json:"vitality"`
+	Diseases     StringSlice `json:"diseases"`
+	Pests        StringSlice `json:"pests"`
+}
+
+func (i TreeInspection) Validate() error {
+	if i.Date.IsZero() {
+		return fmt.Errorf("date is required")
+	}
+	if i.Vitality < MinVitality || i.Vitality > MaxVitality {
+		return fmt.Errorf("vitality must be between %d and %d", MinVitality, MaxVitality)
+	}
+	if err := i.Diseases.validate(Diseases, "disease"); err != nil {
+		return err
+	}
+	return i.Pests.validate(Pests, "pest")
+}
+
+type StringSlice []string
+
+func (s *StringSlice) Scan(value any) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to convert DB value to []byte")
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+func (s StringSlice) Value() (driver.Value, error) {
+	if s == nil {
+		return json.Marshal([]string{})
+	}
+	return json.Marshal([]string(s))
+}
+
+func (s StringSlice) validate(vocabulary []string, name string) error {
+	for _, value := range s {
+		if !slices.Contains(vocabulary, value) {
+			return fmt.Errorf("unknown %s %q", name, value)
+		}
+	}
+	return nil
+}
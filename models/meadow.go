@@ -4,16 +4,39 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"time"
 )
 
 type IntSlize []int
 
 type Meadow struct {
-	ID       int      `json:"id"`
-	Location string   `json:"location"`
-	Name     string   `json:"name"`
-	Size     IntSlize `json:"size"`
-	TreeIds  IntSlize `json:"treeIds"`
+	ID        int          `json:"id"`
+	Location  string       `json:"location"`
+	Name      string       `json:"name"`
+	Size      IntSlize     `json:"size"`
+	TreeIds   IntSlize     `json:"treeIds"`
+	Boundary  Polygon      `json:"boundary,omitempty"`
+	Area      float64      `json:"areaSquareMeters,omitempty"`
+	Grid      *GridOrigin  `json:"grid,omitempty"`
+	Role      Role         `json:"role,omitempty"`
+	Scope     *MeadowScope `json:"scope,omitempty"`
+	DeletedAt *time.Time   `json:"deletedAt,omitempty"`
+}
+
+// Validate checks the optional boundary and grid. The area is derived from
+// the boundary and ignored on writes.
+func (m Meadow) Validate() error {
+	if m.Boundary != nil {
+		if err := m.Boundary.Validate(); err != nil {
+			return fmt.Errorf("boundary: %w", err)
+		}
+	}
+	if m.Grid != nil {
+		if err := m.Grid.Validate(); err != nil {
+			return fmt.Errorf("grid: %w", err)
+		}
+	}
+	return nil
 }
 
 func (s *IntSlize) Scan(value any) error {
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"fmt"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Role is what a member may do with a meadow and everything on it. Viewers
+// read, editors also change trees, images, inspections, harvests and tasks,
+// and owners also delete the meadow and manage its members.
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleOwner  Role = "owner"
+)
+
+// Roles lists the roles from the least to the most privileged.
+var Roles = []Role{RoleViewer, RoleEditor, RoleOwner}
+
+func (r Role) Validate() error {
+	if !slices.Contains(Roles, r) {
+		return fmt.Errorf("invalid role %q, expected one of %v", r, Roles)
+	}
+	return nil
+}
+
+// AtLeast reports whether the role includes everything other may do.
+func (r Role) AtLeast(other Role) bool {
+	return slices.Index(Roles, r) >= slices.Index(Roles, other)
+}
+
+// RolesAtLeast lists the roles that include everything role may do.
+func RolesAtLeast(role Role) []Role {
+	return Roles[slices.Index(Roles, role):]
+}
+
+// MeadowMember is a user's membership of a meadow.
+type MeadowMember struct {
+	MeadowId int       `json:"meadowId"`
+	UserId   int       `json:"userId"`
+	Username string    `json:"username"`
+	Role     Role      `json:"role"`
+	Since    time.Time `json:"since"`
+}
+
+// Invitation invites whoever has an account with the email address to join
+// a meadow with the role. It is pending until it is accepted or declined.
+type Invitation struct {
+	ID         int       `json:"id"`
+	MeadowId   int       `json:"meadowId"`
+	MeadowName string    `json:"meadowName"`
+	Email      string    `json:"email"`
+	Role       Role      `json:"role"`
+	InvitedBy  string    `json:"invitedBy"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Validate checks the email address and role and normalizes the address to
+// lower case, as addresses are matched regardless of case.
+func (i *Invitation) Validate() error {
+	address, err := mail.ParseAddress(i.Email)
+	if err != nil || address.Address != strings.TrimSpace(i.Email) {
+		return fmt.Errorf("invalid email address %q", i.Email)
+	}
+	i.Email = strings.ToLower(address.Address)
+	return i.Role.Validate()
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Organization is a team that owns meadows together. Its members hold a
+// role in every meadow it owns, on top of any role they have as members of
+// a single meadow.
+type Organization struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Role      Role      `json:"role,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (o *Organization) Validate() error {
+	o.Name = strings.TrimSpace(o.Name)
+	if o.Name == "" {
+		return fmt.Errorf("name must not be empty")
+	}
+	if len(o.Name) > 255 {
+		return fmt.Errorf("name must be at most 255 bytes long")
+	}
+	return nil
+}
+
+// OrganizationMember is a user's membership of an organization.
+type OrganizationMember struct {
+	OrganizationId int       `json:"organizationId"`
+	UserId         int       `json:"userId"`
+	Username       string    `json:"username"`
+	Role           Role      `json:"role"`
+	Since          time.Time `json:"since"`
+}
+
+type ScopeKind string
+
+const (
+	ScopePersonal     ScopeKind = "personal"
+	ScopeOrganization ScopeKind = "organization"
+)
+
+// MeadowScope tells who owns a meadow: its members personally, or an
+// organization.
+type MeadowScope struct {
+	Kind             ScopeKind `json:"kind"`
+	OrganizationId   int       `json:"organizationId,omitempty"`
+	OrganizationName string    `json:"organizationName,omitempty"`
+}
+
+// NewMeadowScope returns the scope of a meadow owned by the organization, or
+// the personal scope for organization ID 0.
+func NewMeadowScope(organizationId int, organizationName string) *MeadowScope {
+	if organizationId == 0 {
+		return &MeadowScope{Kind: ScopePersonal}
+	}
+	return &MeadowScope{Kind: ScopeOrganization, OrganizationId: organizationId, OrganizationName: organizationName}
+}
+
+// Organization returns the ID of the owning organization, or 0 for personal
+// meadows and a nil scope.
+func (s *MeadowScope) Organization() int {
+	if s == nil || s.Kind != ScopeOrganization {
+		return 0
+	}
+	return s.OrganizationId
+}
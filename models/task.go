@@ -0,0 +1,178 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// TaskTypes are the kinds of maintenance work a task can be scheduled for.
+var TaskTypes = []string{
+	"pruning",
+	"fertilizing",
+	"grafting",
+	"mowing",
+	"spraying",
+	"watering",
+	"harvesting",
+	"other",
+}
+
+// Frequencies a task can recur with.
+const (
+	Daily   = "daily"
+	Weekly  = "weekly"
+	Monthly = "monthly"
+	Yearly  = "yearly"
+)
+
+// Task is a piece of maintenance work on either a tree or a meadow. A task
+// with a recurrence is followed by its next occurrence once completed.
+type Task struct {
+	ID          int         `json:"id"`
+	TreeId      *int        `json:"treeId,omitempty"`
+	MeadowId    *int        `json:"meadowId,omitempty"`
+	Type        string      `json:"type"`
+	Title       string      `json:"title"`
+	Notes       string      `json:"notes"`
+	DueDate     time.Time   `json:"dueDate"`
+	Recurrence  *Recurrence `json:"recurrence,omitempty"`
+	Assignee    string      `json:"assignee"`
+	CompletedAt *time.Time  `json:"completedAt,omitempty"`
+}
+
+// Recurrence repeats a task every Interval days, weeks, months or years.
+// Yearly tasks can be pinned to a month, e.g. pruning every February.
+// Monthly and yearly tasks come back on Day of the month, or on the last
+// day of shorter months; it defaults to the day of the first due date.
+type Recurrence struct {
+	Frequency string `json:"frequency"`
+	Interval  int    `json:"interval,omitempty"`
+	Month     int    `json:"month,omitempty"`
+	Day       int    `json:"day,omitempty"`
+}
+
+// DueTasks splits open tasks into the ones already overdue and the ones
+// coming up.
+type DueTasks struct {
+	Overdue  []Task `json:"overdue"`
+	Upcoming []Task `json:"upcoming"`
+}
+
+func (t Task) Validate() error {
+	if (t.TreeId == nil) == (t.MeadowId == nil) {
+		return fmt.Errorf("a task belongs to either a tree or a meadow")
+	}
+	if !slices.Contains(TaskTypes, t.Type) {
+		return fmt.Errorf("unknown task type %q", t.Type)
+	}
+	if t.DueDate.IsZero() {
+		return fmt.Errorf("dueDate is required")
+	}
+	if t.Recurrence != nil {
+		return t.Recurrence.Validate()
+	}
+	return nil
+}
+
+func (r Recurrence) Validate() error {
+	switch r.Frequency {
+	case Daily, Weekly, Monthly, Yearly:
+	default:
+		return fmt.Errorf("frequency must be one of %s, %s, %s or %s", Daily, Weekly, Monthly, Yearly)
+	}
+	if r.Interval < 0 {
+		return fmt.Errorf("interval must not be negative")
+	}
+	if r.Month != 0 && r.Frequency != Yearly {
+		return fmt.Errorf("only yearly tasks can be pinned to a month")
+	}
+	if r.Month < 0 || r.Month > 12 {
+		return fmt.Errorf("month must be between 1 and 12")
+	}
+	if r.Day != 0 && r.Frequency != Monthly && r.Frequency != Yearly {
+		return fmt.Errorf("only monthly and yearly tasks can be pinned to a day")
+	}
+	if r.Day < 0 || r.Day > 31 {
+		return fmt.Errorf("day must be between 1 and 31")
+	}
+	return nil
+}
+
+// Next returns the first due date of the recurrence after the given due
+// date that lies after the given time, so a task completed late is not
+// followed by occurrences that are overdue already. Every step is counted
+// from due, so a day clamped to a shorter month does not carry over.
+func (r Recurrence) Next(due time.Time, after time.Time) time.Time {
+	interval := r.Interval
+	if interval == 0 {
+		interval = 1
+	}
+	day := r.Day
+	if day == 0 {
+		day = due.Day()
+	}
+
+	next := due
+	for k := 1; !next.After(due) || !next.After(after); k++ {
+		switch r.Frequency {
+		case Daily:
+			next = due.AddDate(0, 0, k*interval)
+		case Weekly:
+			next = due.AddDate(0, 0, 7*k*interval)
+		case Monthly:
+			next = addMonths(due, k*interval, day)
+		case Yearly:
+			if r.Month == 0 {
+				next = addMonths(due, 12*k*interval, day)
+				break
+			}
+			// The first step stays in the year of due if the month is still ahead
+			years := k * interval
+			if int(due.Month()) < r.Month {
+				years -= interval
+			}
+			next = addMonths(due, 12*years+r.Month-int(due.Month()), day)
+		default:
+			return next
+		}
+	}
+	return next
+}
+
+// Anchored returns the recurrence pinned to the day of due, unless it is
+// pinned already, so that later occurrences keep the day after one was
+// clamped to a shorter month.
+func (r Recurrence) Anchored(due time.Time) *Recurrence {
+	if r.Day == 0 && (r.Frequency == Monthly || r.Frequency == Yearly) {
+		r.Day = due.Day()
+	}
+	return &r
+}
+
+func (r *Recurrence) Scan(value any) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to convert recurrence to []byte")
+	}
+	return json.Unmarshal(bytes, r)
+}
+
+func (r Recurrence) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+// addMonths adds months and moves to the given day without spilling into
+// the next month, so a task due on the 31st is followed by one on the last
+// day of February.
+func addMonths(t time.Time, months int, day int) time.Time {
+	first := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()).AddDate(0, months, 0)
+	day = min(day, daysIn(first.Year(), first.Month()))
+	return time.Date(first.Year(), first.Month(), day, t.Hour(), t.Minute(), t.Second(), 0, t.Location())
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 9, 30, 0, 0, time.UTC)
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	tests := []struct {
+		name       string
+		recurrence Recurrence
+		due        time.Time
+		after      time.Time
+		want       time.Time
+	}{
+		{"daily", Recurrence{Frequency: Daily}, date(2024, 3, 1), date(2024, 3, 1), date(2024, 3, 2)},
+		{"every third day", Recurrence{Frequency: Daily, Interval: 3}, date(2024, 3, 1), date(2024, 3, 1), date(2024, 3, 4)},
+		{"weekly", Recurrence{Frequency: Weekly}, date(2024, 3, 1), date(2024, 3, 1), date(2024, 3, 8)},
+		{"weekly completed late", Recurrence{Frequency: Weekly, Interval: 2}, date(2024, 3, 1), date(2024, 3, 20), date(2024, 3, 29)},
+		{"monthly", Recurrence{Frequency: Monthly}, date(2024, 1, 15), date(2024, 1, 15), date(2024, 2, 15)},
+		{"monthly clamped to february", Recurrence{Frequency: Monthly}, date(2024, 1, 31), date(2024, 1, 31), date(2024, 2, 29)},
+		{"monthly back to the day after clamping", Recurrence{Frequency: Monthly, Day: 31}, date(2024, 2, 29), date(2024, 2, 29), date(2024, 3, 31)},
+		{"monthly clamped across the year", Recurrence{Frequency: Monthly, Interval: 1}, date(2023, 12, 31), date(2024, 1, 31), date(2024, 2, 29)},
+		{"quarterly completed late", Recurrence{Frequency: Monthly, Interval: 3}, date(2024, 1, 10), date(2024, 5, 1), date(2024, 7, 10)},
+		{"yearly", Recurrence{Frequency: Yearly}, date(2024, 6, 1), date(2024, 6, 1), date(2025, 6, 1)},
+		{"yearly from a leap day", Recurrence{Frequency: Yearly}, date(2024, 2, 29), date(2024, 2, 29), date(2025, 2, 28)},
+		{"yearly back to the leap day", Recurrence{Frequency: Yearly, Day: 29}, date(2025, 2, 28), date(2027, 3, 1), date(2028, 2, 29)},
+		{"yearly pinned to a month still ahead", Recurrence{Frequency: Yearly, Month: 2}, date(2024, 1, 10), date(2024, 1, 10), date(2024, 2, 10)},
+		{"yearly pinned to a month already past", Recurrence{Frequency: Yearly, Month: 2}, date(2024, 6, 10), date(2024, 6, 10), date(2025, 2, 10)},
+		{"yearly pinned to the same month", Recurrence{Frequency: Yearly, Month: 2}, date(2024, 2, 10), date(2024, 2, 10), date(2025, 2, 10)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.recurrence.Next(tt.due, tt.after); !got.Equal(tt.want) {
+				t.Errorf("Next(%s, %s) = %s, want %s", tt.due.Format(time.DateOnly), tt.after.Format(time.DateOnly),
+					got.Format(time.DateOnly), tt.want.Format(time.DateOnly))
+			}
+		})
+	}
+}
+
+func TestRecurrenceNextKeepsTheDay(t *testing.T) {
+	// Following the chain of anchored recurrences, as completing each task
+	// does, must not drift to the 29th after February.
+	recurrence := Recurrence{Frequency: Monthly}.Anchored(date(2024, 1, 31))
+	due := date(2024, 1, 31)
+
+	want := []time.Time{date(2024, 2, 29), date(2024, 3, 31), date(2024, 4, 30), date(2024, 5, 31)}
+	for _, w := range want {
+		due = recurrence.Next(due, due)
+		if !due.Equal(w) {
+			t.Fatalf("got %s, want %s", due.Format(time.DateOnly), w.Format(time.DateOnly))
+		}
+		recurrence = recurrence.Anchored(due)
+	}
+}
+
+func TestRecurrenceValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		recurrence Recurrence
+		valid      bool
+	}{
+		{"daily", Recurrence{Frequency: Daily}, true},
+		{"unknown frequency", Recurrence{Frequency: "hourly"}, false},
+		{"negative interval", Recurrence{Frequency: Weekly, Interval: -1}, false},
+		{"yearly with month", Recurrence{Frequency: Yearly, Month: 12}, true},
+		{"monthly with month", Recurrence{Frequency: Monthly, Month: 3}, false},
+		{"month out of range", Recurrence{Frequency: Yearly, Month: 13}, false},
+		{"monthly with day", Recurrence{Frequency: Monthly, Day: 31}, true},
+		{"weekly with day", Recurrence{Frequency: Weekly, Day: 3}, false},
+		{"day out of range", Recurrence{Frequency: Monthly, Day: 32}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.recurrence.Validate(); (err == nil) != tt.valid {
+				t.Errorf("Validate() = %v, want valid %t", err, tt.valid)
+			}
+		})
+	}
+}
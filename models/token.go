@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// RefreshToken is a stored refresh token, known only by the SHA-256 hash of
+// the token itself. All refresh tokens issued from one login belong to the
+// same family, which access tokens name as their session.
+type RefreshToken struct {
+	Hash      string
+	FamilyId  string
+	UserId    int
+	ExpiresAt time.Time
+}
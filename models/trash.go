@@ -0,0 +1,8 @@
+package models
+
+// Trash lists the soft deleted items of a user that can still be restored.
+type Trash struct {
+	Meadows []Meadow `json:"meadows"`
+	Trees   []Tree   `json:"trees"`
+	Images  []Image  `json:"images"`
+}
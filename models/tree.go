@@ -13,11 +13,26 @@ type Position struct {
 }
 
 type Tree struct {
-	ID        int       `json:"id"`
-	PlantDate time.Time `json:"plantDate"`
-	MeadowId  int       `json:"meadowId"`
-	Position  Position  `json:"position"`
-	Type      string    `json:"type"`
+	ID          int            `json:"id"`
+	PlantDate   time.Time      `json:"plantDate"`
+	MeadowId    int            `json:"meadowId"`
+	Position    Position       `json:"position"`
+	Type        string         `json:"type"`
+	VarietyId   *int           `json:"varietyId,omitempty"`
+	Coordinates *LatLon        `json:"coordinates,omitempty"`
+	Condition   *TreeCondition `json:"condition,omitempty"`
+	DeletedAt   *time.Time     `json:"deletedAt,omitempty"`
+}
+
+// Validate checks the optional coordinates. Whether they lie inside the
+// meadow is up to the store, which knows the meadow's boundary.
+func (t Tree) Validate() error {
+	if t.Coordinates != nil {
+		if err := t.Coordinates.Validate(); err != nil {
+			return fmt.Errorf("coordinates: %w", err)
+		}
+	}
+	return nil
 }
 
 func (p *Position) Scan(value any) error {
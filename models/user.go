@@ -3,10 +3,32 @@ package models
 import "time"
 
 type User struct {
-	ID        int       `json:"user_id"`
-	Username  string    `json:"username"`
-	Password  string    `json:"password"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-	ChangedAt time.Time `json:"changed_at"`
+	ID              int        `json:"user_id"`
+	Username        string     `json:"username"`
+	Password        string     `json:"password"`
+	Email           string     `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	ChangedAt       time.Time  `json:"changed_at"`
+}
+
+// Profile is what users see of their own account, without the password.
+type Profile struct {
+	ID              int        `json:"user_id"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	ChangedAt       time.Time  `json:"changed_at"`
+}
+
+func (u User) Profile() Profile {
+	return Profile{
+		ID:              u.ID,
+		Username:        u.Username,
+		Email:           u.Email,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		CreatedAt:       u.CreatedAt,
+		ChangedAt:       u.ChangedAt,
+	}
 }
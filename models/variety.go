@@ -0,0 +1,89 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+)
+
+// FruitTypes are the kinds of fruit a species can bear.
+var FruitTypes = []string{
+	"pome",
+	"stone",
+	"nut",
+	"berry",
+}
+
+// Species is a fruit species of the variety catalog. Common names are keyed
+// by language code, such as "en" or "de". Months are numbered from 1.
+type Species struct {
+	ID              int               `json:"id"`
+	ScientificName  string            `json:"scientificName"`
+	CommonNames     map[string]string `json:"commonNames"`
+	FruitType       string            `json:"fruitType"`
+	FloweringMonths []int             `json:"floweringMonths"`
+	HarvestMonths   []int             `json:"harvestMonths"`
+	Varieties       []Variety         `json:"varieties,omitempty"`
+}
+
+// Variety is a cultivar of a species. Every species has one generic variety
+// for trees whose cultivar is unknown; it is named after the species. A
+// variety's months override the typical months of its species if set.
+type Variety struct {
+	ID              int      `json:"id"`
+	SpeciesId       int      `json:"speciesId"`
+	Name            string   `json:"name"`
+	Generic         bool     `json:"generic,omitempty"`
+	Synonyms        []string `json:"synonyms,omitempty"`
+	FloweringMonths []int    `json:"floweringMonths,omitempty"`
+	HarvestMonths   []int    `json:"harvestMonths,omitempty"`
+}
+
+// VarietyMatch is a search result of the variety catalog. MatchedName is
+// the name, synonym or species name the query matched, and Score runs from
+// 0 to 1 for an exact match.
+type VarietyMatch struct {
+	Variety     Variety `json:"variety"`
+	Species     Species `json:"species"`
+	MatchedName string  `json:"matchedName"`
+	Score       float64 `json:"score"`
+}
+
+// Validate checks the fruit type and months of the species, not its
+// varieties.
+func (s Species) Validate() error {
+	if s.ScientificName == "" {
+		return fmt.Errorf("species %d has no scientific name", s.ID)
+	}
+	if !slices.Contains(FruitTypes, s.FruitType) {
+		return fmt.Errorf("species %d has the unknown fruit type %q", s.ID, s.FruitType)
+	}
+	if err := validateMonths(s.FloweringMonths); err != nil {
+		return fmt.Errorf("species %d: %w", s.ID, err)
+	}
+	if err := validateMonths(s.HarvestMonths); err != nil {
+		return fmt.Errorf("species %d: %w", s.ID, err)
+	}
+	return nil
+}
+
+func (v Variety) Validate() error {
+	if v.Name == "" {
+		return fmt.Errorf("variety %d has no name", v.ID)
+	}
+	if err := validateMonths(v.FloweringMonths); err != nil {
+		return fmt.Errorf("variety %d: %w", v.ID, err)
+	}
+	if err := validateMonths(v.HarvestMonths); err != nil {
+		return fmt.Errorf("variety %d: %w", v.ID, err)
+	}
+	return nil
+}
+
+func validateMonths(months []int) error {
+	for _, month := range months {
+		if month < 1 || month > 12 {
+			return fmt.Errorf("invalid month %d", month)
+		}
+	}
+	return nil
+}
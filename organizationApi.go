@@ -0,0 +1,226 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Johnhi19/TreeSpotter_backend/handlers"
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *server) getOrganizations(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	organizations, err := s.orgs.FindAllOrganizationsForUser(userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, organizations)
+}
+
+func (s *server) findOrganizationByID(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intOrganizationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	organization, err := s.orgs.FindOneOrganizationForUser(intOrganizationID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, organization)
+}
+
+func (s *server) insertOrganization(c *gin.Context) {
+	var organization models.Organization
+
+	userID := c.GetInt("user_id")
+
+	if err := c.ShouldBindJSON(&organization); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	if err := organization.Validate(); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	insertedID, err := s.orgs.InsertOrganizationForUser(organization, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Organization inserted successfully",
+		"id":      insertedID,
+	})
+}
+
+func (s *server) updateOrganization(c *gin.Context) {
+	var organization models.Organization
+
+	userID := c.GetInt("user_id")
+
+	intOrganizationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	if err := c.ShouldBindJSON(&organization); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+	organization.ID = intOrganizationID
+
+	if err := organization.Validate(); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	if err := s.orgs.UpdateOrganizationForUser(organization, userID); err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Organization updated successfully",
+	})
+}
+
+func (s *server) removeOrganization(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intOrganizationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	// Meadows of the organization in the trash are deleted along with it
+	report, err := s.orgs.DeleteOrganizationForUser(intOrganizationID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	removeBlobs(s.blobs, &report)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Organization deleted successfully",
+		"removed": report,
+	})
+}
+
+func (s *server) getOrganizationMembers(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intOrganizationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	members, err := s.orgs.FindOrganizationMembers(intOrganizationID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, members)
+}
+
+// insertOrganizationMember adds an existing user to the organization. The
+// body is {"username": "...", "role": "..."}.
+func (s *server) insertOrganizationMember(c *gin.Context) {
+	var body struct {
+		Username string      `json:"username" binding:"required"`
+		Role     models.Role `json:"role" binding:"required"`
+	}
+
+	userID := c.GetInt("user_id")
+
+	intOrganizationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+	if err := body.Role.Validate(); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	if err := s.orgs.InsertOrganizationMemberForUser(intOrganizationID, body.Username, body.Role, userID); err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Member added successfully",
+	})
+}
+
+// updateOrganizationMember changes the role of a member. The body is
+// {"role": "..."}.
+func (s *server) updateOrganizationMember(c *gin.Context) {
+	var body struct {
+		Role models.Role `json:"role" binding:"required"`
+	}
+
+	userID := c.GetInt("user_id")
+
+	intOrganizationID, intMemberID, ok := treeChildParams(c, "userId")
+	if !ok {
+		return
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+	if err := body.Role.Validate(); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	if err := s.orgs.UpdateOrganizationMemberForUser(intOrganizationID, intMemberID, body.Role, userID); err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Member updated successfully",
+	})
+}
+
+// removeOrganizationMember removes a member from the organization. Members
+// can also use it to leave an organization with their own ID.
+func (s *server) removeOrganizationMember(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intOrganizationID, intMemberID, ok := treeChildParams(c, "userId")
+	if !ok {
+		return
+	}
+
+	if err := s.orgs.DeleteOrganizationMemberForUser(intOrganizationID, intMemberID, userID); err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Member removed successfully",
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestOrganizationMeadows(t *testing.T) {
+	ts := newTestServer(t)
+	owner, _ := ts.signUp("owner")
+	anna, _ := ts.signUp("anna")
+	outsider, _ := ts.signUp("outsider")
+
+	organization := id(ts.mustRequest("POST", "/organizations", `{"name": "Obstbauverein"}`, owner, http.StatusCreated))
+	ts.mustRequest("POST", fmt.Sprintf("/organizations/%d/members", organization), `{"username": "anna", "role": "viewer"}`, owner, http.StatusCreated)
+	meadow := id(ts.mustRequest("POST", fmt.Sprintf("/organizations/%d/meadows", organization), `{"name": "Orchard", "location": "Hill", "size": [10, 10]}`, owner, http.StatusCreated))
+	ts.mustRequest("POST", "/meadows", `{"name": "Garden", "location": "Home", "size": [5, 5]}`, owner, http.StatusCreated)
+
+	// Members reach the organization's meadows with their role in the
+	// organization
+	ts.mustRequest("GET", fmt.Sprintf("/meadows/%d", meadow), "", anna, http.StatusOK)
+	ts.mustRequest("DELETE", fmt.Sprintf("/meadows/%d", meadow), "", anna, http.StatusForbidden)
+	ts.mustRequest("POST", fmt.Sprintf("/organizations/%d/meadows", organization), `{"name": "Field", "location": "Valley", "size": [5, 5]}`, anna, http.StatusForbidden)
+	ts.mustRequest("GET", fmt.Sprintf("/meadows/%d", meadow), "", outsider, http.StatusNotFound)
+	ts.mustRequest("GET", fmt.Sprintf("/organizations/%d", organization), "", outsider, http.StatusNotFound)
+
+	if meadows := ts.list(fmt.Sprintf("/organizations/%d/meadows", organization), owner); len(meadows) != 1 || id(meadows[0]) != meadow {
+		t.Errorf("meadows of the organization = %v, want only meadow %d", meadows, meadow)
+	}
+	if meadows := ts.list("/meadows", owner); len(meadows) != 2 {
+		t.Errorf("the owner sees %d meadows, want the personal and the organization's", len(meadows))
+	}
+
+	// Once the member leaves, the meadow is out of reach
+	ts.mustRequest("PUT", fmt.Sprintf("/organizations/%d/members/%d", organization, ts.userID(anna)), `{"role": "owner"}`, owner, http.StatusOK)
+	ts.mustRequest("DELETE", fmt.Sprintf("/organizations/%d/members/%d", organization, ts.userID(anna)), "", anna, http.StatusOK)
+	ts.mustRequest("GET", fmt.Sprintf("/meadows/%d", meadow), "", anna, http.StatusNotFound)
+}
+
+func TestDeleteOrganization(t *testing.T) {
+	ts := newTestServer(t)
+	owner, _ := ts.signUp("owner")
+	ts.signUp("anna")
+
+	organization := id(ts.mustRequest("POST", "/organizations", `{"name": "Obstbauverein"}`, owner, http.StatusCreated))
+	ts.mustRequest("POST", fmt.Sprintf("/organizations/%d/members", organization), `{"username": "anna", "role": "editor"}`, owner, http.StatusCreated)
+	meadow := id(ts.mustRequest("POST", fmt.Sprintf("/organizations/%d/meadows", organization), `{"name": "Orchard", "location": "Hill", "size": [10, 10]}`, owner, http.StatusCreated))
+	tree := id(ts.mustRequest("POST", "/trees", fmt.Sprintf(`{"meadowId": %d, "type": "Apple", "plantDate": "2020-03-01T00:00:00Z", "position": {"x": 1, "y": 1}}`, meadow), owner, http.StatusCreated))
+	if status, response := ts.upload(fmt.Sprintf("/trees/%d/uploadImage", tree), "treeImage", owner, testJPEG(t, 100)); status != http.StatusOK {
+		t.Fatalf("uploading an image = %d %v", status, response)
+	}
+
+	ts.mustRequest("DELETE", fmt.Sprintf("/organizations/%d", organization), "", owner, http.StatusConflict)
+	ts.mustRequest("DELETE", fmt.Sprintf("/meadows/%d", meadow), "", owner, http.StatusOK)
+
+	// The meadow in the trash goes with the organization, as nobody could
+	// restore it afterwards
+	response := ts.mustRequest("DELETE", fmt.Sprintf("/organizations/%d", organization), "", owner, http.StatusOK)
+	removed, _ := response["removed"].(map[string]any)
+	if meadows, _ := removed["meadowIds"].([]any); len(meadows) != 1 {
+		t.Errorf("removed meadows = %v, want meadow %d", removed["meadowIds"], meadow)
+	}
+	if files, _ := removed["files"].([]any); len(files) != 3 || removed["failedFiles"] != nil {
+		t.Errorf("removed files = %v, failed %v, want the image with its resized copies", files, removed["failedFiles"])
+	}
+
+	ts.mustRequest("GET", fmt.Sprintf("/organizations/%d", organization), "", owner, http.StatusNotFound)
+	ts.mustRequest("POST", fmt.Sprintf("/trash/meadows/%d/restore", meadow), "", owner, http.StatusNotFound)
+	if trash := ts.mustRequest("GET", "/trash", "", owner, http.StatusOK); len(trash["meadows"].([]any)) != 0 {
+		t.Errorf("the trash still lists %v", trash["meadows"])
+	}
+}
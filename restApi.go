@@ -1,61 +1,132 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/Johnhi19/TreeSpotter_backend/catalog"
 	"github.com/Johnhi19/TreeSpotter_backend/db"
 	"github.com/Johnhi19/TreeSpotter_backend/handlers"
+	"github.com/Johnhi19/TreeSpotter_backend/mail"
 	"github.com/Johnhi19/TreeSpotter_backend/middleware"
+	"github.com/Johnhi19/TreeSpotter_backend/signing"
+	"github.com/Johnhi19/TreeSpotter_backend/storage"
 
 	"github.com/Johnhi19/TreeSpotter_backend/models"
 	"github.com/gin-gonic/gin"
 )
 
-func main() {
-	db.Connect()
-	defer db.Disconnect()
-
-	router := gin.Default()
+// server bundles the stores and the variety catalog the HTTP handlers work
+// on.
+type server struct {
+	meadows     db.MeadowStore
+	trees       db.TreeStore
+	inspections db.InspectionStore
+	harvests    db.HarvestStore
+	tasks       db.TaskStore
+	images      db.ImageStore
+	trash       db.TrashStore
+	users       db.UserStore
+	members     db.MemberStore
+	orgs        db.OrganizationStore
+	tokens      db.TokenStore
+	varieties   *catalog.Catalog
+
+	mailer        mail.Mailer
+	authOptions   handlers.AuthOptions
+	signer        *signing.Signer
+	blobs         storage.BlobStore
+	uploadOptions handlers.UploadOptions
+	imageURLTTL   time.Duration
+}
 
-	// Serve images statically
-	router.Static("/uploads", "./uploads")
+func newServer(meadows db.MeadowStore, trees db.TreeStore, inspections db.InspectionStore, harvests db.HarvestStore, tasks db.TaskStore, images db.ImageStore, trash db.TrashStore, users db.UserStore, members db.MemberStore, orgs db.OrganizationStore, tokens db.TokenStore, varieties *catalog.Catalog, signer *signing.Signer) *server {
+	return &server{
+		meadows:     meadows,
+		trees:       trees,
+		inspections: inspections,
+		harvests:    harvests,
+		tasks:       tasks,
+		images:      images,
+		trash:       trash,
+		users:       users,
+		members:     members,
+		orgs:        orgs,
+		tokens:      tokens,
+		varieties:   varieties,
+
+		mailer:      mail.NewLogMailer(os.Stdout, defaultMailFrom),
+		authOptions: handlers.AuthOptions{Lifetimes: handlers.DefaultTokenLifetimes, AppURL: defaultAppURL},
+		signer:      signer,
+		blobs:       storage.NewLocalStore(".", signer),
+		imageURLTTL: defaultImageURLTTL,
+	}
+}
 
-	// Public (no auth)
-	public := router.Group("/")
-	{
-		public.POST("/login", handlers.Login)
-		public.POST("/register", handlers.Register)
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "uploads" {
+		os.Exit(runUploads(os.Args[2:]))
 	}
 
-	// Protected (requires JWT)
-	protected := router.Group("/")
-	protected.Use(middleware.AuthMiddleware())
-	{
-		protected.DELETE("/trees/:id", removeTree)
-		protected.DELETE("/meadows/:id", removeMeadow)
-		protected.DELETE("/trees/images/:imageId", removeTreeImage)
+	autoMigrate := flag.Bool("auto-migrate", os.Getenv("DB_AUTO_MIGRATE") == "true", "apply pending schema migrations on startup")
+	trashRetention := flag.Duration("trash-retention", envDuration("TRASH_RETENTION", 30*24*time.Hour), "how long deleted items stay restorable")
+	accessTokenTTL := flag.Duration("access-token-ttl", envDuration("ACCESS_TOKEN_TTL", handlers.DefaultTokenLifetimes.Access), "how long an access token is valid")
+	refreshTokenTTL := flag.Duration("refresh-token-ttl", envDuration("REFRESH_TOKEN_TTL", handlers.DefaultTokenLifetimes.Refresh), "how long a session lasts without being refreshed")
+	imageURLTTL := flag.Duration("image-url-ttl", envDuration("IMAGE_URL_TTL", defaultImageURLTTL), "how long signed image URLs are valid")
+	stripImageGPS := flag.Bool("strip-image-gps", os.Getenv("STRIP_IMAGE_GPS") == "true", "remove the location from the EXIF data of stored images")
+	imageVerifyInterval := flag.Duration("image-verify-interval", envDuration("IMAGE_VERIFY_INTERVAL", defaultImageVerifyInterval), "how often image files are checked for missing or changed content, 0 to never")
+	requireVerifiedEmail := flag.Bool("require-verified-email", os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true", "refuse logins until the email address is verified")
+	flag.Parse()
+
+	signer, err := newImageSigner()
+	if err != nil {
+		panic(err)
+	}
+	varieties, err := catalog.Load()
+	if err != nil {
+		panic(err)
+	}
 
-		protected.GET("/meadows/:id", findMeadowByID)
-		protected.GET("/meadows", getBasicInfoOfAllMeadows)
-		protected.GET("/meadows/:id/trees", getTreesOfMeadow)
-		protected.GET("/trees/:id", findTreeByID)
-		protected.GET("/trees/:id/images", getTreeImages)
+	conn := db.Connect(*autoMigrate)
+	defer db.Disconnect(conn)
 
-		protected.POST("/meadows", insertMeadow)
-		protected.POST("/trees", insertTree)
-		protected.POST("trees/:id/uploadImage", uploadImage)
+	store := db.NewMySQLStore(conn)
+	s := newServer(store, store, store, store, store, store, store, store, store, store, store, varieties, signer)
+	s.authOptions = handlers.AuthOptions{
+		Lifetimes:            handlers.TokenLifetimes{Access: *accessTokenTTL, Refresh: *refreshTokenTTL},
+		AppURL:               envString("APP_URL", defaultAppURL),
+		RequireVerifiedEmail: *requireVerifiedEmail,
+	}
+	s.imageURLTTL = *imageURLTTL
+	s.uploadOptions = handlers.UploadOptions{StripGPS: *stripImageGPS}
+	s.blobs, err = newBlobStore(s.signer)
+	if err != nil {
+		panic(err)
+	}
+	s.mailer, err = newMailer()
+	if err != nil {
+		panic(err)
+	}
 
-		protected.PUT("/meadows/:id", updateMeadow)
-		protected.PUT("/trees/:id", updateTree)
-		protected.PUT("/trees/images/:imageId", updateTreeImage)
+	go purgeTrashPeriodically(s.trash, s.blobs, *trashRetention)
+	go purgeTokensPeriodically(s.tokens)
+	if *imageVerifyInterval > 0 {
+		go verifyImagesPeriodically(s.images, s.blobs, *imageVerifyInterval)
 	}
 
+	router := s.routes()
+
 	go func() {
 		if err := router.Run(":8080"); err != nil {
 			panic(err)
@@ -65,73 +136,222 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
+}
+
+func (s *server) routes() *gin.Engine {
+	router := gin.Default()
 
-	db.Disconnect()
+	// Images are served to members of their meadow, who either send their
+	// token or use a signed URL
+	files := router.Group("/uploads")
+	files.Use(middleware.SignedURLOrAuth(s.signer, s.tokens))
+	{
+		files.GET("/:name", s.serveImage)
+	}
+
+	auth := handlers.NewAuthHandler(s.users, s.tokens, s.mailer, s.authOptions)
+
+	// Public (no auth)
+	public := router.Group("/")
+	{
+		public.POST("/login", auth.Login)
+		public.POST("/register", auth.Register)
+		public.POST("/token/refresh", auth.Refresh)
+		public.POST("/password/forgot", auth.ForgotPassword)
+		public.POST("/password/reset", auth.ResetPassword)
+		public.POST("/email/verify", auth.VerifyEmail)
+		public.POST("/email/verify/resend", auth.ResendVerification)
+	}
+
+	// Protected (requires JWT)
+	protected := router.Group("/")
+	protected.Use(middleware.AuthMiddleware(s.tokens), middleware.OrganizationContext())
+	{
+		protected.DELETE("/trees/:id", s.removeTree)
+		protected.DELETE("/meadows/:id", s.removeMeadow)
+		protected.DELETE("/trees/images/:imageId", s.removeTreeImage)
+		protected.DELETE("/trees/:id/inspections/:inspectionId", s.removeInspection)
+		protected.DELETE("/trees/:id/harvests/:harvestId", s.removeHarvest)
+		protected.DELETE("/tasks/:id", s.removeTask)
+		protected.DELETE("/meadows/:id/members/:userId", s.removeMember)
+		protected.DELETE("/meadows/:id/invitations/:invitationId", s.removeInvitation)
+		protected.DELETE("/organizations/:id", s.removeOrganization)
+		protected.DELETE("/organizations/:id/members/:userId", s.removeOrganizationMember)
+		protected.DELETE("/me", s.removeAccount)
+
+		protected.GET("/meadows/:id", s.findMeadowByID)
+		protected.GET("/meadows", s.getBasicInfoOfAllMeadows)
+		protected.GET("/meadows/:id/trees", s.getTreesOfMeadow)
+		protected.GET("/trees/:id", s.findTreeByID)
+		protected.GET("/trees/:id/images", s.getTreeImages)
+		protected.GET("/trees/:id/inspections", s.getInspectionsOfTree)
+		protected.GET("/trees/:id/inspections/:inspectionId", s.findInspectionByID)
+		protected.GET("/trees/:id/harvests", s.getHarvestsOfTree)
+		protected.GET("/trees/:id/harvests/:harvestId", s.findHarvestByID)
+		protected.GET("/yield/:group", s.getYield)
+		protected.GET("/meadows/:id/tasks", s.getTasksOfMeadow)
+		protected.GET("/meadows/:id/grid/coordinates", s.gridToCoordinates)
+		protected.GET("/meadows/:id/export.geojson", s.exportMeadowGeoJSON)
+		protected.GET("/meadows/:id/trees/export.csv", s.exportTreesCSV)
+		protected.GET("/meadows/:id/grid/position", s.coordinatesToGrid)
+		protected.GET("/trees/:id/tasks", s.getTasksOfTree)
+		protected.GET("/tasks/due", s.getDueTasks)
+		protected.GET("/tasks/:id", s.findTaskByID)
+		protected.GET("/trash", s.getTrash)
+		protected.GET("/species", s.getSpecies)
+		protected.GET("/varieties", s.searchVarieties)
+		protected.GET("/varieties/:id", s.findVarietyByID)
+		protected.GET("/meadows/:id/members", s.getMembersOfMeadow)
+		protected.GET("/meadows/:id/invitations", s.getInvitationsOfMeadow)
+		protected.GET("/invitations", s.getMyInvitations)
+		protected.GET("/organizations", s.getOrganizations)
+		protected.GET("/organizations/:id", s.findOrganizationByID)
+		protected.GET("/organizations/:id/members", s.getOrganizationMembers)
+		protected.GET("/organizations/:id/meadows", s.getBasicInfoOfAllMeadows)
+		protected.GET("/me", auth.Me)
+		protected.GET("/me/export", s.exportAccount)
+
+		protected.POST("/meadows", s.insertMeadow)
+		protected.POST("/meadows/import", s.importMeadowGeoJSON)
+		protected.POST("/meadows/:id/trees/import", s.importTreesCSV)
+		protected.POST("/trees", s.insertTree)
+		protected.POST("trees/:id/uploadImage", s.uploadImage)
+		protected.POST("/trees/:id/inspections", s.insertInspection)
+		protected.POST("/trees/:id/harvests", s.insertHarvest)
+		protected.POST("/tasks", s.insertTask)
+		protected.POST("/tasks/:id/complete", s.completeTask)
+		protected.POST("/trash/:kind/:id/restore", s.restoreFromTrash)
+		protected.POST("/meadows/:id/invitations", s.insertInvitation)
+		protected.POST("/invitations/:id/accept", s.acceptInvitation)
+		protected.POST("/invitations/:id/decline", s.declineInvitation)
+		protected.POST("/organizations", s.insertOrganization)
+		protected.POST("/organizations/:id/members", s.insertOrganizationMember)
+		protected.POST("/organizations/:id/meadows", s.insertMeadow)
+		protected.POST("/organizations/:id/meadows/import", s.importMeadowGeoJSON)
+		protected.POST("/logout", auth.Logout)
+		protected.POST("/logout-all", auth.LogoutAll)
+		protected.POST("/me/password", auth.ChangePassword)
+
+		protected.PUT("/meadows/:id", s.updateMeadow)
+		protected.PUT("/trees/:id", s.updateTree)
+		protected.PUT("/trees/images/:imageId", s.updateTreeImage)
+		protected.PUT("/trees/:id/inspections/:inspectionId", s.updateInspection)
+		protected.PUT("/trees/:id/harvests/:harvestId", s.updateHarvest)
+		protected.PUT("/tasks/:id", s.updateTask)
+		protected.PUT("/meadows/:id/members/:userId", s.updateMember)
+		protected.PUT("/organizations/:id", s.updateOrganization)
+		protected.PUT("/organizations/:id/members/:userId", s.updateOrganizationMember)
+		protected.PUT("/me", auth.UpdateMe)
+	}
+
+	return router
 }
 
-func findMeadowByID(c *gin.Context) {
+func (s *server) findMeadowByID(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
 	meadowId := c.Param("id")
 
 	intMeadowID, err := strconv.Atoi(meadowId)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		handlers.RespondInvalidInput(c, "Invalid ID format")
 		return
 	}
 
-	meadow := db.FindOneMeadowByIdForUser(intMeadowID, userID)
+	meadow, err := s.meadows.FindOneMeadowByIdForUser(intMeadowID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
 	c.IndentedJSON(http.StatusOK, meadow)
 }
 
-func findTreeByID(c *gin.Context) {
+func (s *server) findTreeByID(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
 	treeId := c.Param("id")
 
 	intTreeID, err := strconv.Atoi(treeId)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		handlers.RespondInvalidInput(c, "Invalid ID format")
 		return
 	}
 
-	tree := db.FindOneTreeById(intTreeID, userID)
+	tree, err := s.trees.FindOneTreeById(intTreeID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
 	c.IndentedJSON(http.StatusOK, tree)
 }
 
-func getBasicInfoOfAllMeadows(c *gin.Context) {
+// getBasicInfoOfAllMeadows lists the user's personal meadows and those of
+// the user's organizations, or only the meadows of the organization the
+// request acts in.
+func (s *server) getBasicInfoOfAllMeadows(c *gin.Context) {
 	userID := c.GetInt("user_id")
+	organizationID := c.GetInt("organization_id")
 
-	meadows := db.FindAllMeadowsForUser(userID)
+	var meadows []models.Meadow
+	var err error
+	if organizationID != 0 {
+		meadows, err = s.meadows.FindAllMeadowsOfOrganization(organizationID, userID)
+	} else {
+		meadows, err = s.meadows.FindAllMeadowsForUser(userID)
+	}
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
 	c.IndentedJSON(http.StatusOK, meadows)
 }
 
-func getTreesOfMeadow(c *gin.Context) {
+func (s *server) getTreesOfMeadow(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
 	meadowId := c.Param("id")
 
 	intMeadowID, err := strconv.Atoi(meadowId)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		handlers.RespondInvalidInput(c, "Invalid ID format")
 		return
 	}
 
-	trees := db.FindAllTreesForMeadow(intMeadowID, userID)
+	filter, err := parseTreeFilter(c)
+	if err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	trees, err := s.trees.FindAllTreesForMeadow(intMeadowID, userID, filter)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
 	c.IndentedJSON(http.StatusOK, trees)
 }
 
-func insertMeadow(c *gin.Context) {
+func (s *server) insertMeadow(c *gin.Context) {
 	var meadow models.Meadow
 
 	userID := c.GetInt("user_id")
 
 	if err := c.ShouldBindJSON(&meadow); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		handlers.RespondInvalidInput(c, err.Error())
 		return
 	}
 
-	insertedID := db.InsertOneMeadowForUser(meadow, userID)
+	if err := meadow.Validate(); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+	meadow.Scope = models.NewMeadowScope(c.GetInt("organization_id"), "")
+
+	insertedID, err := s.meadows.InsertOneMeadowForUser(meadow, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Meadow inserted successfully",
@@ -139,27 +359,31 @@ func insertMeadow(c *gin.Context) {
 	})
 }
 
-func insertTree(c *gin.Context) {
+func (s *server) insertTree(c *gin.Context) {
 	var tree models.Tree
 
 	userID := c.GetInt("user_id")
 
 	if err := c.ShouldBindJSON(&tree); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		handlers.RespondInvalidInput(c, err.Error())
 		return
 	}
 
-	// Insert the tree
-	insertedID := db.InsertOneTreeForUser(tree, userID)
-
-	// Update the meadow's TreeIds list by adding the tree ID
-	if err := db.UpdateMeadowTreeIdsForUser(tree.MeadowId, insertedID, false, userID); err != nil {
-		fmt.Printf("ERROR executing UPDATE: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Tree inserted but failed to update meadow"})
+	if err := tree.Validate(); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+	if err := s.varieties.ResolveTree(&tree); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
 		return
 	}
 
-	fmt.Printf("Updated Meadow %d with new Tree ID %d\n", tree.MeadowId, insertedID)
+	// Insert the tree, which also makes it show up in the meadow's TreeIds
+	insertedID, err := s.trees.InsertOneTreeForUser(tree, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Tree inserted successfully",
@@ -167,23 +391,23 @@ func insertTree(c *gin.Context) {
 	})
 }
 
-func removeMeadow(c *gin.Context) {
+func (s *server) removeMeadow(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
 	// Get meadow ID from URL parameter
 	meadowId := c.Param("id")
 	intMeadowID, err := strconv.Atoi(meadowId)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		handlers.RespondInvalidInput(c, "Invalid ID format")
 		return
 	}
 
 	fmt.Printf("Attempting to delete meadow with ID: %d\n", intMeadowID)
 
-	// Delete the meadow (which also updates the trees)
-	if err := db.DeleteOneMeadowForUser(intMeadowID, userID); err != nil {
-		fmt.Printf("ERROR deleting meadow: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// Move the meadow together with its trees and their images to the trash
+	report, err := s.meadows.DeleteOneMeadowForUser(intMeadowID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
 		return
 	}
 
@@ -192,26 +416,27 @@ func removeMeadow(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Meadow deleted successfully",
 		"id":      intMeadowID,
+		"removed": report,
 	})
 }
 
-func removeTree(c *gin.Context) {
+func (s *server) removeTree(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
 	// Get tree ID from URL parameter
 	id := c.Param("id")
 	intID, err := strconv.Atoi(id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		handlers.RespondInvalidInput(c, "Invalid ID format")
 		return
 	}
 
 	fmt.Printf("Attempting to delete tree with ID: %d\n", intID)
 
-	// Delete the tree (which also updates the meadow)
-	if err := db.DeleteOneTreeForUser(intID, userID); err != nil {
-		fmt.Printf("ERROR deleting tree: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// Move the tree together with its images to the trash
+	report, err := s.trees.DeleteOneTreeForUser(intID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
 		return
 	}
 
@@ -220,26 +445,26 @@ func removeTree(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Tree deleted successfully",
 		"id":      intID,
+		"removed": report,
 	})
 }
 
-func removeTreeImage(c *gin.Context) {
+func (s *server) removeTreeImage(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
 	// Get tree ID from URL parameter
 	imageId := c.Param("imageId")
 	intID, err := strconv.Atoi(imageId)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Image ID format"})
+		handlers.RespondInvalidInput(c, "Invalid Image ID format")
 		return
 	}
 
 	fmt.Printf("Attempting to delete image with ID: %d\n", intID)
 
-	// Delete the image
-	if err := db.DeleteTreeImage(intID, userID); err != nil {
-		fmt.Printf("ERROR deleting image: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// Move the image to the trash
+	if err := s.images.DeleteTreeImage(intID, userID); err != nil {
+		handlers.RespondError(c, err)
 		return
 	}
 
@@ -251,51 +476,71 @@ func removeTreeImage(c *gin.Context) {
 	})
 }
 
-func updateMeadow(c *gin.Context) {
+func (s *server) updateMeadow(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
 	var meadow models.Meadow
 
 	// Bind the JSON body to the meadow struct
 	if err := c.ShouldBindJSON(&meadow); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	if err := meadow.Validate(); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
 		return
 	}
 
 	// Update the meadow
-	db.UpdateMeadowForUser(meadow, userID)
+	if err := s.meadows.UpdateMeadowForUser(meadow, userID); err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Meadow updated successfully",
 	})
 }
 
-func updateTree(c *gin.Context) {
+func (s *server) updateTree(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
 	var tree models.Tree
 
 	// Bind the JSON body to the tree struct
 	if err := c.ShouldBindJSON(&tree); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	if err := tree.Validate(); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+	if err := s.varieties.ResolveTree(&tree); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
 		return
 	}
 
 	// Update the tree
-	db.UpdateTreeForUser(tree, userID)
+	if err := s.trees.UpdateTreeForUser(tree, userID); err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Tree updated successfully",
 	})
 }
 
-func updateTreeImage(c *gin.Context) {
+func (s *server) updateTreeImage(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
 	imageId := c.Param("imageId")
 	intImageID, err := strconv.Atoi(imageId)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		handlers.RespondInvalidInput(c, "Invalid ID format")
 		return
 	}
 
@@ -306,16 +551,21 @@ func updateTreeImage(c *gin.Context) {
 
 	if newDescription != "" {
 		fmt.Printf("Updating image %d description to: %s\n", intImageID, newDescription)
-		db.UpdateTreeImageDescriptionDb(intImageID, newDescription, userID)
+		err = s.images.UpdateTreeImageDescriptionDb(intImageID, newDescription, userID)
 	} else if newDatetime != "" {
-		parsedTime, err := time.Parse(time.RFC3339, newDatetime)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid datetime format"})
+		parsedTime, parseErr := time.Parse(time.RFC3339, newDatetime)
+		if parseErr != nil {
+			handlers.RespondInvalidInput(c, "Invalid datetime format")
 			return
 		}
-		db.UpdateTreeImageDatetimeDb(intImageID, parsedTime, userID)
+		err = s.images.UpdateTreeImageDatetimeDb(intImageID, parsedTime, userID)
 	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No valid fields to update"})
+		handlers.RespondInvalidInput(c, "No valid fields to update")
+		return
+	}
+
+	if err != nil {
+		handlers.RespondError(c, err)
 		return
 	}
 
@@ -325,18 +575,33 @@ func updateTreeImage(c *gin.Context) {
 
 }
 
-func getTreeImages(c *gin.Context) {
+func (s *server) getTreeImages(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
 	treeId := c.Param("id")
 
 	intTreeID, err := strconv.Atoi(treeId)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		handlers.RespondInvalidInput(c, "Invalid ID format")
 		return
 	}
 
-	images := db.GetTreeImageDb(intTreeID, userID)
+	images, err := s.images.GetTreeImageDb(intTreeID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+	for i := range images {
+		images[i].URLs = map[string]string{}
+		for variant, path := range images[i].Variants() {
+			images[i].URLs[variant], err = s.blobs.SignedURL(strings.TrimPrefix(path, "/"), userID, s.imageURLTTL)
+			if err != nil {
+				handlers.RespondError(c, err)
+				return
+			}
+		}
+		images[i].URL = images[i].URLs[models.VariantOriginal]
+	}
 
 	fmt.Printf("Successfully retrieved %d images for user %d and tree %d\n", len(images), userID, intTreeID)
 
@@ -344,35 +609,145 @@ func getTreeImages(c *gin.Context) {
 
 }
 
-func uploadImage(c *gin.Context) {
+// serveImage sends an uploaded image file to members of its tree's meadow.
+func (s *server) serveImage(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	key := handlers.ImageKey(c.Param("name"))
+	if key == "" {
+		handlers.RespondInvalidInput(c, "Invalid file name")
+		return
+	}
+
+	if _, err := s.images.FindImageByPathForUser(key, userID); err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	handlers.ServeImage(c, s.blobs, key)
+}
+
+func (s *server) uploadImage(c *gin.Context) {
 	userID := c.GetInt("user_id")
 
 	treeId := c.Param("id")
 
 	intTreeID, err := strconv.Atoi(treeId)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		handlers.RespondInvalidInput(c, "Invalid ID format")
 		return
 	}
 
-	description := c.PostForm("description")
+	// Refuse users who may not add images before reading up to
+	// MaxImagesPerUpload files into temporary files
+	if err := s.authorizeTree(intTreeID, userID, models.RoleEditor); err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
 
-	file := handlers.UploadImageHandler(c.Writer, c.Request)
-	if file == nil {
+	// The same photo is stored once per tree, so every file is looked up
+	// as soon as it is hashed
+	existing := func(uploadSHA256 string) (models.Image, bool, error) {
+		return s.images.FindTreeImageByHashForUser(intTreeID, uploadSHA256, userID)
+	}
+	description, uploads, ok := handlers.ReadImageUploads(c, s.uploadOptions, existing)
+	if !ok {
 		return
 	}
 
-	// Optionally, you can store the image info in the database
-	err = db.UploadImageDb(file.Name(), description, userID, intTreeID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image info to database"})
+	// A single file is answered as before several files could be uploaded
+	if len(uploads) == 1 {
+		result, err := s.storeImageUpload(uploads[0], intTreeID, description, userID)
+		if err != nil {
+			handlers.RespondError(c, err)
+			return
+		}
+
+		response := gin.H{"message": "Image uploaded successfully", "id": result.ID, "path": result.Path, "results": []models.ImageUploadResult{result}}
+		if result.Duplicate {
+			response["message"], response["duplicate"] = "Image was already uploaded", true
+		}
+		c.JSON(http.StatusOK, response)
 		return
 	}
 
-	fmt.Printf("User %d uploaded file: %s\n", userID, file.Name())
+	results := make([]models.ImageUploadResult, len(uploads))
+	uploaded := 0
+	for i, upload := range uploads {
+		result, err := s.storeImageUpload(upload, intTreeID, description, userID)
+		if err != nil {
+			result.Status, result.Code, result.Error = handlers.ErrorStatus(err)
+		} else {
+			uploaded++
+		}
+		results[i] = result
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Image uploaded successfully",
-		"path":    file.Name(),
+		"message": fmt.Sprintf("%d of %d images uploaded", uploaded, len(uploads)),
+		"results": results,
 	})
 }
+
+// authorizeTree checks the user's role in the meadow of the tree, for
+// handlers that have to do so before the store does
+func (s *server) authorizeTree(treeID int, userID int, need models.Role) error {
+	tree, err := s.trees.FindOneTreeById(treeID, userID)
+	if err != nil {
+		return err
+	}
+	meadow, err := s.meadows.FindOneMeadowByIdForUser(tree.MeadowId, userID)
+	if err != nil {
+		return err
+	}
+	if !meadow.Role.AtLeast(need) {
+		return fmt.Errorf("tree %d needs the %s role, the user is %s: %w", treeID, need, meadow.Role, db.ErrForbidden)
+	}
+	return nil
+}
+
+// storeImageUpload stores one file of an upload as an image of the tree,
+// unless the tree already has it, and removes its temporary file.
+func (s *server) storeImageUpload(upload handlers.ImageUpload, treeID int, description string, userID int) (models.ImageUploadResult, error) {
+	defer upload.Close()
+	result := models.ImageUploadResult{File: upload.FileName}
+	if upload.Err != nil {
+		return result, upload.Err
+	}
+	if upload.Existing != nil {
+		return duplicateUpload(result, *upload.Existing, userID), nil
+	}
+
+	image, err := handlers.StoreImageUpload(s.blobs, upload)
+	if err != nil {
+		return result, err
+	}
+	image.TreeId = treeID
+	image.Description = description
+
+	// Store the image info in the database, or drop the files again
+	insertedID, err := s.images.UploadImageDb(image, userID)
+	if err != nil {
+		handlers.DeleteImageFiles(s.blobs, image)
+
+		// The same photo was stored in the meantime, by a concurrent upload
+		// or as an earlier file of this one
+		if errors.Is(err, db.ErrConflict) {
+			if existing, found, findErr := s.images.FindTreeImageByHashForUser(treeID, upload.Image.UploadSHA256, userID); findErr == nil && found {
+				return duplicateUpload(result, existing, userID), nil
+			}
+		}
+		return result, err
+	}
+
+	fmt.Printf("User %d uploaded file: %s\n", userID, image.Path)
+	result.Status, result.ID, result.Path = http.StatusOK, int(insertedID), image.Path
+	return result, nil
+}
+
+// duplicateUpload reports an upload the tree already had as existing
+func duplicateUpload(result models.ImageUploadResult, existing models.Image, userID int) models.ImageUploadResult {
+	fmt.Printf("User %d uploaded image %d again\n", userID, existing.ID)
+	result.Status, result.ID, result.Path, result.Duplicate = http.StatusOK, existing.ID, existing.Path, true
+	return result
+}
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Johnhi19/TreeSpotter_backend/catalog"
+	"github.com/Johnhi19/TreeSpotter_backend/db"
+	"github.com/Johnhi19/TreeSpotter_backend/handlers"
+	"github.com/Johnhi19/TreeSpotter_backend/mail"
+	"github.com/Johnhi19/TreeSpotter_backend/signing"
+	"github.com/Johnhi19/TreeSpotter_backend/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// testServer serves the API from a MemoryStore, with uploads in a
+// temporary directory and mails written to a buffer
+type testServer struct {
+	t      *testing.T
+	server *server
+	router *gin.Engine
+	mails  *bytes.Buffer
+	// blobs is the directory uploaded files are stored in
+	blobs string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	varieties, err := catalog.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := db.NewMemoryStore()
+	signer := signing.NewSigner([]byte("test secret"))
+
+	s := newServer(store, store, store, store, store, store, store, store, store, store, store, varieties, signer)
+	mails := &bytes.Buffer{}
+	s.mailer = mail.NewLogMailer(mails, defaultMailFrom)
+	blobs := t.TempDir()
+	s.blobs = storage.NewLocalStore(blobs, signer)
+	return &testServer{t: t, server: s, router: s.routes(), mails: mails, blobs: blobs}
+}
+
+// request sends body as JSON and decodes the JSON response into a map,
+// which is nil for responses that are not an object
+func (ts *testServer) request(method string, path string, body string, token string) (int, map[string]any) {
+	ts.t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	return ts.send(req, token)
+}
+
+// send serves req as the user of token and decodes the JSON response like
+// request
+func (ts *testServer) send(req *http.Request, token string) (int, map[string]any) {
+	ts.t.Helper()
+	w := ts.serve(req, token)
+	var response map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+func (ts *testServer) serve(req *http.Request, token string) *httptest.ResponseRecorder {
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	ts.router.ServeHTTP(w, req)
+	return w
+}
+
+// list gets a JSON array of objects
+func (ts *testServer) list(path string, token string) []map[string]any {
+	ts.t.Helper()
+	w := ts.serve(httptest.NewRequest("GET", path, nil), token)
+	var response []map[string]any
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &response) != nil {
+		ts.t.Fatalf("GET %s = %d %s, want a list", path, w.Code, w.Body)
+	}
+	return response
+}
+
+// upload posts the files as the field of a multipart form
+func (ts *testServer) upload(path string, field string, token string, files ...[]byte) (int, map[string]any) {
+	ts.t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for i, data := range files {
+		part, err := form.CreateFormFile(field, fmt.Sprintf("file%d", i+1))
+		if err != nil {
+			ts.t.Fatal(err)
+		}
+		part.Write(data)
+	}
+	form.Close()
+
+	req := httptest.NewRequest("POST", path, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return ts.send(req, token)
+}
+
+// mustRequest is request for calls the test only prepares with
+func (ts *testServer) mustRequest(method string, path string, body string, token string, want int) map[string]any {
+	ts.t.Helper()
+	status, response := ts.request(method, path, body, token)
+	if status != want {
+		ts.t.Fatalf("%s %s = %d %v, want %d", method, path, status, response, want)
+	}
+	return response
+}
+
+// signUp registers the user and returns their access and refresh token
+func (ts *testServer) signUp(username string) (string, string) {
+	ts.t.Helper()
+	ts.mustRequest("POST", "/register", fmt.Sprintf(`{"username": %q, "password": "secret", "email": "%s@example.com"}`, username, username), "", http.StatusOK)
+	response := ts.mustRequest("POST", "/login", fmt.Sprintf(`{"username": %q, "password": "secret"}`, username), "", http.StatusOK)
+	return response["token"].(string), response["refreshToken"].(string)
+}
+
+// userID returns the ID of the user of token
+func (ts *testServer) userID(token string) int {
+	ts.t.Helper()
+	value, _ := ts.mustRequest("GET", "/me", "", token, http.StatusOK)["user_id"].(float64)
+	return int(value)
+}
+
+// mailToken returns the token of the last link to page that was mailed
+func (ts *testServer) mailToken(page string) string {
+	ts.t.Helper()
+	mails := ts.mails.String()
+	at := strings.LastIndex(mails, "/"+page+"?token=")
+	if at < 0 {
+		ts.t.Fatalf("no link to %s was mailed", page)
+	}
+	link, _, _ := strings.Cut(mails[at:], "\r\n")
+	token, err := url.QueryUnescape(strings.TrimPrefix(link, "/"+page+"?token="))
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	return token
+}
+
+// verifyEmail verifies the address of the user who signed up last
+func (ts *testServer) verifyEmail() {
+	ts.t.Helper()
+	ts.mustRequest("POST", "/email/verify", fmt.Sprintf(`{"token": %q}`, ts.mailToken("verify-email")), "", http.StatusOK)
+}
+
+// newMeadow creates a personal meadow of the user
+func (ts *testServer) newMeadow(token string) int {
+	ts.t.Helper()
+	return id(ts.mustRequest("POST", "/meadows", `{"name": "Orchard", "location": "Hill", "size": [10, 10]}`, token, http.StatusCreated))
+}
+
+// newTree plants an apple tree on the meadow
+func (ts *testServer) newTree(token string, meadow int) int {
+	ts.t.Helper()
+	body := fmt.Sprintf(`{"meadowId": %d, "type": "Apple", "plantDate": "2020-03-01T00:00:00Z", "position": {"x": 1, "y": 1}}`, meadow)
+	return id(ts.mustRequest("POST", "/trees", body, token, http.StatusCreated))
+}
+
+// newImage uploads a photo of the given shade to the tree
+func (ts *testServer) newImage(token string, tree int, shade uint8) int {
+	ts.t.Helper()
+	status, response := ts.upload(fmt.Sprintf("/trees/%d/uploadImage", tree), "treeImage", token, testJPEG(ts.t, shade))
+	if status != http.StatusOK {
+		ts.t.Fatalf("uploading an image to tree %d = %d %v", tree, status, response)
+	}
+	return id(response)
+}
+
+// testJPEG encodes a small photo in one shade of green, so that photos of
+// different shades differ in content
+func testJPEG(t *testing.T, shade uint8) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.RGBA{G: shade, A: 255}}, image.Point{}, draw.Src)
+	var data bytes.Buffer
+	if err := jpeg.Encode(&data, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return data.Bytes()
+}
+
+func id(response map[string]any) int {
+	value, _ := response["id"].(float64)
+	return int(value)
+}
+
+// ints returns the numbers of a JSON array in ascending order
+func ints(value any) []int {
+	values, _ := value.([]any)
+	numbers := []int{}
+	for _, v := range values {
+		number, _ := v.(float64)
+		numbers = append(numbers, int(number))
+	}
+	slices.Sort(numbers)
+	return numbers
+}
+
+func TestLogin(t *testing.T) {
+	ts := newTestServer(t)
+	ts.signUp("alice")
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"valid", `{"username": "alice", "password": "secret"}`, http.StatusOK},
+		{"wrong password", `{"username": "alice", "password": "guess"}`, http.StatusUnauthorized},
+		{"unknown user", `{"username": "mallory", "password": "secret"}`, http.StatusUnauthorized},
+		{"malformed", `{"username": `, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, response := ts.request("POST", "/login", tt.body, ""); status != tt.status {
+				t.Errorf("POST /login = %d %v, want %d", status, response, tt.status)
+			}
+		})
+	}
+}
+
+func TestErrorStatuses(t *testing.T) {
+	ts := newTestServer(t)
+	owner, _ := ts.signUp("owner")
+	viewer, _ := ts.signUp("viewer")
+	ts.verifyEmail()
+	outsider, _ := ts.signUp("outsider")
+
+	meadow := id(ts.mustRequest("POST", "/meadows", `{"name": "Orchard", "location": "Hill", "size": [10, 10]}`, owner, http.StatusCreated))
+	tree := id(ts.mustRequest("POST", "/trees", fmt.Sprintf(`{"meadowId": %d, "type": "Apple", "plantDate": "2020-03-01T00:00:00Z", "position": {"x": 1, "y": 1}}`, meadow), owner, http.StatusCreated))
+
+	invitation := id(ts.mustRequest("POST", fmt.Sprintf("/meadows/%d/invitations", meadow), `{"email": "viewer@example.com", "role": "viewer"}`, owner, http.StatusCreated))
+	ts.mustRequest("POST", fmt.Sprintf("/invitations/%d/accept", invitation), "", viewer, http.StatusOK)
+
+	treeBody := func(id int) string {
+		return fmt.Sprintf(`{"id": %d, "meadowId": %d, "type": "Pear", "plantDate": "2020-03-01T00:00:00Z", "position": {"x": 2, "y": 2}}`, id, meadow)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		token  string
+		status int
+		code   string
+	}{
+		{"owner reads the meadow", "GET", fmt.Sprintf("/meadows/%d", meadow), "", owner, http.StatusOK, ""},
+		{"viewer reads the meadow", "GET", fmt.Sprintf("/meadows/%d", meadow), "", viewer, http.StatusOK, ""},
+		{"viewer reads the tree", "GET", fmt.Sprintf("/trees/%d", tree), "", viewer, http.StatusOK, ""},
+		{"viewer lists the images", "GET", fmt.Sprintf("/trees/%d/images", tree), "", viewer, http.StatusOK, ""},
+		{"viewer changes the tree", "PUT", fmt.Sprintf("/trees/%d", tree), treeBody(tree), viewer, http.StatusForbidden, "FORBIDDEN"},
+		{"viewer adds a tree", "POST", "/trees", treeBody(0), viewer, http.StatusForbidden, "FORBIDDEN"},
+		{"viewer deletes the meadow", "DELETE", fmt.Sprintf("/meadows/%d", meadow), "", viewer, http.StatusForbidden, "FORBIDDEN"},
+		{"viewer invites someone", "POST", fmt.Sprintf("/meadows/%d/invitations", meadow), `{"email": "x@example.com", "role": "viewer"}`, viewer, http.StatusForbidden, "FORBIDDEN"},
+		{"outsider reads the meadow", "GET", fmt.Sprintf("/meadows/%d", meadow), "", outsider, http.StatusNotFound, "NOT_FOUND"},
+		{"outsider reads the tree", "GET", fmt.Sprintf("/trees/%d", tree), "", outsider, http.StatusNotFound, "NOT_FOUND"},
+		{"outsider deletes the tree", "DELETE", fmt.Sprintf("/trees/%d", tree), "", outsider, http.StatusNotFound, "NOT_FOUND"},
+		{"outsider lists the images", "GET", fmt.Sprintf("/trees/%d/images", tree), "", outsider, http.StatusNotFound, "NOT_FOUND"},
+		{"images of a missing tree", "GET", "/trees/9999/images", "", owner, http.StatusNotFound, "NOT_FOUND"},
+		{"missing meadow", "GET", "/meadows/9999", "", owner, http.StatusNotFound, "NOT_FOUND"},
+		{"missing tree", "PUT", "/trees/9999", treeBody(9999), owner, http.StatusNotFound, "NOT_FOUND"},
+		{"invalid ID", "GET", "/trees/apple", "", owner, http.StatusBadRequest, "INVALID_INPUT"},
+		{"invalid body", "POST", "/trees", `{"meadowId": "one"}`, owner, http.StatusBadRequest, "INVALID_INPUT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, response := ts.request(tt.method, tt.path, tt.body, tt.token)
+			if status != tt.status || (tt.code != "" && response["code"] != tt.code) {
+				t.Errorf("%s %s = %d %v, want %d %s", tt.method, tt.path, status, response, tt.status, tt.code)
+			}
+		})
+	}
+
+	// The owner can still do all of it
+	ts.mustRequest("PUT", fmt.Sprintf("/trees/%d", tree), treeBody(tree), owner, http.StatusOK)
+	ts.mustRequest("DELETE", fmt.Sprintf("/trees/%d", tree), "", owner, http.StatusOK)
+	ts.mustRequest("GET", fmt.Sprintf("/trees/%d", tree), "", owner, http.StatusNotFound)
+}
+
+func TestUploadImageAuthorization(t *testing.T) {
+	ts := newTestServer(t)
+	owner, _ := ts.signUp("owner")
+	viewer, _ := ts.signUp("viewer")
+	ts.verifyEmail()
+	outsider, _ := ts.signUp("outsider")
+
+	meadow := id(ts.mustRequest("POST", "/meadows", `{"name": "Orchard", "location": "Hill", "size": [10, 10]}`, owner, http.StatusCreated))
+	tree := id(ts.mustRequest("POST", "/trees", fmt.Sprintf(`{"meadowId": %d, "type": "Apple", "plantDate": "2020-03-01T00:00:00Z", "position": {"x": 1, "y": 1}}`, meadow), owner, http.StatusCreated))
+	invitation := id(ts.mustRequest("POST", fmt.Sprintf("/meadows/%d/invitations", meadow), `{"email": "viewer@example.com", "role": "viewer"}`, owner, http.StatusCreated))
+	ts.mustRequest("POST", fmt.Sprintf("/invitations/%d/accept", invitation), "", viewer, http.StatusOK)
+	path := fmt.Sprintf("/trees/%d/uploadImage", tree)
+
+	// Users who may not add images are refused before the files are read,
+	// so even a request that is too large is answered by the role check
+	tooLarge := make([][]byte, handlers.MaxImagesPerUpload+1)
+	for i := range tooLarge {
+		tooLarge[i] = testJPEG(t, uint8(i))
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		files  [][]byte
+		status int
+	}{
+		{"viewer", path, viewer, [][]byte{testJPEG(t, 1)}, http.StatusForbidden},
+		{"viewer with too many files", path, viewer, tooLarge, http.StatusForbidden},
+		{"outsider", path, outsider, [][]byte{testJPEG(t, 1)}, http.StatusNotFound},
+		{"missing tree", "/trees/9999/uploadImage", owner, [][]byte{testJPEG(t, 1)}, http.StatusNotFound},
+		{"owner with too many files", path, owner, tooLarge, http.StatusBadRequest},
+		{"owner", path, owner, [][]byte{testJPEG(t, 1)}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, response := ts.upload(tt.path, "treeImage", tt.token, tt.files...); status != tt.status {
+				t.Errorf("POST %s = %d %v, want %d", tt.path, status, response, tt.status)
+			}
+		})
+	}
+	if images := ts.list(fmt.Sprintf("/trees/%d/images", tree), owner); len(images) != 1 {
+		t.Errorf("the tree has %d images, want 1", len(images))
+	}
+}
+
+func TestUploadDuplicates(t *testing.T) {
+	ts := newTestServer(t)
+	owner, _ := ts.signUp("owner")
+	meadow := id(ts.mustRequest("POST", "/meadows", `{"name": "Orchard", "location": "Hill", "size": [10, 10]}`, owner, http.StatusCreated))
+	tree := id(ts.mustRequest("POST", "/trees", fmt.Sprintf(`{"meadowId": %d, "type": "Apple", "plantDate": "2020-03-01T00:00:00Z", "position": {"x": 1, "y": 1}}`, meadow), owner, http.StatusCreated))
+	other := id(ts.mustRequest("POST", "/trees", fmt.Sprintf(`{"meadowId": %d, "type": "Pear", "plantDate": "2020-03-01T00:00:00Z", "position": {"x": 2, "y": 2}}`, meadow), owner, http.StatusCreated))
+	path := fmt.Sprintf("/trees/%d/uploadImage", tree)
+	photo, second := testJPEG(t, 10), testJPEG(t, 200)
+
+	upload := func(path string, files ...[]byte) []any {
+		t.Helper()
+		status, response := ts.upload(path, "treeImage", owner, files...)
+		if status != http.StatusOK {
+			t.Fatalf("POST %s = %d %v", path, status, response)
+		}
+		return response["results"].([]any)
+	}
+	result := func(results []any, i int) (int, bool) {
+		r := results[i].(map[string]any)
+		duplicate, _ := r["duplicate"].(bool)
+		return id(r), duplicate
+	}
+
+	first, duplicate := result(upload(path, photo), 0)
+	if duplicate {
+		t.Fatal("the first upload is a duplicate")
+	}
+
+	// The same photo is found again, also next to a new one and twice in
+	// one request
+	results := upload(path, second, photo, second)
+	if got, duplicate := result(results, 1); got != first || !duplicate {
+		t.Errorf("uploading the photo again = %d duplicate %t, want %d as a duplicate", got, duplicate, first)
+	}
+	stored, duplicate := result(results, 0)
+	if duplicate {
+		t.Error("a new photo is a duplicate")
+	}
+	if got, duplicate := result(results, 2); got != stored || !duplicate {
+		t.Errorf("the same photo twice in a request = %d duplicate %t, want %d as a duplicate", got, duplicate, stored)
+	}
+	if images := ts.list(fmt.Sprintf("/trees/%d/images", tree), owner); len(images) != 2 {
+		t.Errorf("the tree has %d images, want 2", len(images))
+	}
+
+	// Other trees and the trash do not count
+	if got, duplicate := result(upload(fmt.Sprintf("/trees/%d/uploadImage", other), photo), 0); got == first || duplicate {
+		t.Errorf("uploading the photo to another tree = %d duplicate %t, want a new image", got, duplicate)
+	}
+	ts.mustRequest("DELETE", fmt.Sprintf("/trees/images/%d", first), "", owner, http.StatusOK)
+	if got, duplicate := result(upload(path, photo), 0); got == first || duplicate {
+		t.Errorf("uploading a photo from the trash = %d duplicate %t, want a new image", got, duplicate)
+	}
+	ts.mustRequest("POST", fmt.Sprintf("/trash/images/%d/restore", first), "", owner, http.StatusConflict)
+}
+
+func TestImageVariants(t *testing.T) {
+	ts := newTestServer(t)
+	owner, _ := ts.signUp("owner")
+	tree := ts.newTree(owner, ts.newMeadow(owner))
+	path := fmt.Sprintf("/trees/%d/uploadImage", tree)
+
+	large := image.NewRGBA(image.Rect(0, 0, 2000, 1000))
+	draw.Draw(large, large.Bounds(), &image.Uniform{color.RGBA{G: 120, A: 255}}, image.Point{}, draw.Src)
+	var photo bytes.Buffer
+	if err := jpeg.Encode(&photo, large, nil); err != nil {
+		t.Fatal(err)
+	}
+	if status, response := ts.upload(path, "treeImage", owner, photo.Bytes(), testJPEG(t, 10)); status != http.StatusOK {
+		t.Fatalf("POST %s = %d %v", path, status, response)
+	}
+
+	// The copies fit in their squares; the small photo is not enlarged
+	want := map[string][]image.Point{
+		"original":  {{2000, 1000}, {64, 48}},
+		"medium":    {{1280, 640}, {64, 48}},
+		"thumbnail": {{256, 128}, {64, 48}},
+	}
+	images := ts.list(fmt.Sprintf("/trees/%d/images", tree), owner)
+	if len(images) != 2 {
+		t.Fatalf("the tree has %d images, want 2", len(images))
+	}
+	slices.SortFunc(images, func(a, b map[string]any) int { return id(a) - id(b) })
+	for i, img := range images {
+		urls, _ := img["urls"].(map[string]any)
+		for variant, sizes := range want {
+			link, _ := urls[variant].(string)
+			if link == "" {
+				t.Errorf("image %d has no %s URL: %v", id(img), variant, urls)
+				continue
+			}
+			w := ts.serve(httptest.NewRequest("GET", link, nil), "")
+			if w.Code != http.StatusOK {
+				t.Errorf("GET %s = %d", link, w.Code)
+				continue
+			}
+			config, format, err := image.DecodeConfig(w.Body)
+			if err != nil || format != "jpeg" {
+				t.Errorf("the %s copy of image %d is not a JPEG: %s %v", variant, id(img), format, err)
+				continue
+			}
+			if got := (image.Point{config.Width, config.Height}); got != sizes[i] {
+				t.Errorf("the %s copy of image %d is %v, want %v", variant, id(img), got, sizes[i])
+			}
+		}
+	}
+}
+
+func TestDeleteCascade(t *testing.T) {
+	ts := newTestServer(t)
+	owner, _ := ts.signUp("owner")
+	meadow := ts.newMeadow(owner)
+	apple, pear := ts.newTree(owner, meadow), ts.newTree(owner, meadow)
+	images := []int{ts.newImage(owner, apple, 10), ts.newImage(owner, apple, 20)}
+	pearImage := ts.newImage(owner, pear, 30)
+
+	// The tree goes with its images
+	removed := ts.mustRequest("DELETE", fmt.Sprintf("/trees/%d", apple), "", owner, http.StatusOK)["removed"].(map[string]any)
+	if got := ints(removed["treeIds"]); !slices.Equal(got, []int{apple}) {
+		t.Errorf("removed trees = %v, want %d", got, apple)
+	}
+	if got := ints(removed["imageIds"]); !slices.Equal(got, images) {
+		t.Errorf("removed images = %v, want %v", got, images)
+	}
+	ts.mustRequest("GET", fmt.Sprintf("/trees/%d/images", apple), "", owner, http.StatusNotFound)
+
+	// The meadow goes with the trees that are left and their images
+	removed = ts.mustRequest("DELETE", fmt.Sprintf("/meadows/%d", meadow), "", owner, http.StatusOK)["removed"].(map[string]any)
+	if got := ints(removed["meadowIds"]); !slices.Equal(got, []int{meadow}) {
+		t.Errorf("removed meadows = %v, want %d", got, meadow)
+	}
+	if got := ints(removed["treeIds"]); !slices.Equal(got, []int{pear}) {
+		t.Errorf("removed trees = %v, want only %d", got, pear)
+	}
+	if got := ints(removed["imageIds"]); !slices.Equal(got, []int{pearImage}) {
+		t.Errorf("removed images = %v, want only %d", got, pearImage)
+	}
+
+	ts.mustRequest("GET", fmt.Sprintf("/meadows/%d", meadow), "", owner, http.StatusNotFound)
+	ts.mustRequest("GET", fmt.Sprintf("/trees/%d", pear), "", owner, http.StatusNotFound)
+	ts.mustRequest("DELETE", fmt.Sprintf("/meadows/%d", meadow), "", owner, http.StatusNotFound)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/db"
+)
+
+// tokenPurgeInterval is how often expired sessions are purged.
+const tokenPurgeInterval = 6 * time.Hour
+
+// purgeTokensPeriodically deletes sessions together with their refresh
+// tokens once they have expired. It never returns.
+func purgeTokensPeriodically(tokens db.TokenStore) {
+	ticker := time.NewTicker(tokenPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := tokens.PurgeExpiredTokens(time.Now())
+		if err != nil {
+			fmt.Printf("ERROR purging expired tokens: %v\n", err)
+		} else if purged > 0 {
+			fmt.Printf("Purged %d expired sessions\n", purged)
+		}
+
+		<-ticker.C
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthentication(t *testing.T) {
+	ts := newTestServer(t)
+	token, _ := ts.signUp("alice")
+	loggedOut, _ := ts.signUp("bob")
+	ts.mustRequest("POST", "/logout", "", loggedOut, http.StatusOK)
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+		code   string
+	}{
+		{"valid token", "Bearer " + token, http.StatusOK, ""},
+		{"no header", "", http.StatusUnauthorized, "MISSING_TOKEN"},
+		{"not a bearer token", "Basic " + token, http.StatusUnauthorized, "INVALID_TOKEN"},
+		{"garbage", "Bearer not.a.token", http.StatusUnauthorized, "INVALID_TOKEN"},
+		{"logged out", "Bearer " + loggedOut, http.StatusUnauthorized, "INVALID_TOKEN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/meadows", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			ts.router.ServeHTTP(w, req)
+
+			var response map[string]any
+			_ = json.Unmarshal(w.Body.Bytes(), &response)
+			if w.Code != tt.status || (tt.code != "" && response["code"] != tt.code) {
+				t.Errorf("GET /meadows = %d %v, want %d %s", w.Code, response, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	ts := newTestServer(t)
+	_, refresh := ts.signUp("alice")
+
+	response := ts.mustRequest("POST", "/token/refresh", fmt.Sprintf(`{"refreshToken": %q}`, refresh), "", http.StatusOK)
+	token, rotated := response["token"].(string), response["refreshToken"].(string)
+	if rotated == refresh {
+		t.Fatal("the refresh token was not rotated")
+	}
+	ts.mustRequest("GET", "/me", "", token, http.StatusOK)
+
+	// Using the first token again revokes the session with all its tokens
+	ts.mustRequest("POST", "/token/refresh", fmt.Sprintf(`{"refreshToken": %q}`, refresh), "", http.StatusUnauthorized)
+	ts.mustRequest("POST", "/token/refresh", fmt.Sprintf(`{"refreshToken": %q}`, rotated), "", http.StatusUnauthorized)
+	ts.mustRequest("GET", "/me", "", token, http.StatusUnauthorized)
+}
@@ -0,0 +1,97 @@
+package signing
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// query returns the query parameters of a signed URL
+func query(t *testing.T, signed string) url.Values {
+	t.Helper()
+	_, rawQuery, ok := strings.Cut(signed, "?")
+	if !ok {
+		t.Fatalf("%q has no query", signed)
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+func TestVerify(t *testing.T) {
+	signer := NewSigner([]byte("secret"))
+	signed := signer.Sign("/uploads/1.jpg", 42, time.Minute)
+
+	if !strings.HasPrefix(signed, "/uploads/1.jpg?") {
+		t.Errorf("Sign() = %q, want the path with a query", signed)
+	}
+	if !Signed(query(t, signed)) {
+		t.Error("Signed() = false for a signed URL")
+	}
+
+	userID, err := signer.Verify("/uploads/1.jpg", query(t, signed))
+	if err != nil || userID != 42 {
+		t.Errorf("Verify() = %d, %v, want 42", userID, err)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	signer := NewSigner([]byte("secret"))
+	valid := func() url.Values {
+		return query(t, signer.Sign("/uploads/1.jpg", 42, time.Minute))
+	}
+
+	tests := []struct {
+		name  string
+		path  string
+		query func() url.Values
+	}{
+		{"not signed", "/uploads/1.jpg", func() url.Values { return url.Values{} }},
+		{"other path", "/uploads/2.jpg", valid},
+		{"other user", "/uploads/1.jpg", func() url.Values {
+			q := valid()
+			q.Set("uid", "43")
+			return q
+		}},
+		{"extended expiry", "/uploads/1.jpg", func() url.Values {
+			q := valid()
+			q.Set("expires", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+			return q
+		}},
+		{"expired", "/uploads/1.jpg", func() url.Values {
+			return query(t, signer.Sign("/uploads/1.jpg", 42, -time.Minute))
+		}},
+		{"other key", "/uploads/1.jpg", func() url.Values {
+			return query(t, NewSigner([]byte("other")).Sign("/uploads/1.jpg", 42, time.Minute))
+		}},
+		{"tampered signature", "/uploads/1.jpg", func() url.Values {
+			q := valid()
+			q.Set("signature", strings.ToUpper(q.Get("signature")))
+			return q
+		}},
+		{"invalid user", "/uploads/1.jpg", func() url.Values {
+			q := valid()
+			q.Set("uid", "me")
+			return q
+		}},
+		{"invalid expiry", "/uploads/1.jpg", func() url.Values {
+			q := valid()
+			q.Set("expires", "tomorrow")
+			return q
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID, err := signer.Verify(tt.path, tt.query())
+			if !errors.Is(err, ErrInvalidSignature) || userID != 0 {
+				t.Errorf("Verify() = %d, %v, want ErrInvalidSignature", userID, err)
+			}
+		})
+	}
+}
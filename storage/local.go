@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/signing"
+)
+
+// LocalStore keeps blobs as files below a directory, the key being the
+// file's path relative to it. Its signed URLs point to the backend itself,
+// at "/" followed by the key, and are checked with the same Signer.
+type LocalStore struct {
+	dir    string
+	signer *signing.Signer
+}
+
+func NewLocalStore(dir string, signer *signing.Signer) *LocalStore {
+	return &LocalStore{dir: dir, signer: signer}
+}
+
+func (s *LocalStore) Delete(key string) error {
+	name, err := s.file(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("blob %s: %w", key, ErrNotFound)
+		}
+		return fmt.Errorf("failed to delete blob %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *LocalStore) Get(key string) (io.ReadCloser, BlobInfo, error) {
+	info, err := s.Stat(key)
+	if err != nil {
+		return nil, info, err
+	}
+	name, _ := s.file(key)
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to open blob %s: %w", key, err)
+	}
+	return f, info, nil
+}
+
+// List walks the directories below the store's directory the prefix points
+// into. Temporary files of unfinished Puts are left out.
+func (s *LocalStore) List(prefix string) ([]BlobInfo, error) {
+	blobs := []BlobInfo{}
+
+	root := s.dir
+	if dir := path.Dir(prefix); prefix != "" && dir != "." {
+		name, err := s.file(dir)
+		if err != nil {
+			return nil, err
+		}
+		root = name
+	}
+
+	err := filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.dir, name)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		fi, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, BlobInfo{Key: key, Size: fi.Size(), ContentType: mime.TypeByExtension(filepath.Ext(name)), ModTime: fi.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blobs %s: %w", prefix, err)
+	}
+	return blobs, nil
+}
+
+// Put writes the blob to a temporary file first, so that readers never see
+// a partly written file.
+func (s *LocalStore) Put(key string, r io.Reader, size int64, contentType string) error {
+	name, err := s.file(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for blob %s: %w", key, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create blob %s: %w", key, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write blob %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write blob %s: %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return fmt.Errorf("failed to store blob %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *LocalStore) SignedURL(key string, userID int, lifetime time.Duration) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	return s.signer.Sign("/"+key, userID, lifetime), nil
+}
+
+// Stat derives the content type from the file extension, as the local disk
+// does not keep the one given to Put.
+func (s *LocalStore) Stat(key string) (BlobInfo, error) {
+	name, err := s.file(key)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	fi, err := os.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && fi.IsDir()) {
+		return BlobInfo{}, fmt.Errorf("blob %s: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return BlobInfo{}, fmt.Errorf("failed to stat blob %s: %w", key, err)
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return BlobInfo{Key: key, Size: fi.Size(), ContentType: contentType, ModTime: fi.ModTime()}, nil
+}
+
+func (s *LocalStore) file(key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
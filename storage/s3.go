@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Options configure an S3Store. PublicEndpoint is the address clients
+// reach the store at, if it differs from Endpoint, as it does when the
+// backend talks to MinIO inside a Docker network; signed URLs are made for
+// it.
+type S3Options struct {
+	Endpoint       string
+	PublicEndpoint string
+	Region         string
+	Bucket         string
+	AccessKey      string
+	SecretKey      string
+	UseSSL         bool
+}
+
+// S3Store keeps blobs as objects of a bucket in an S3-compatible store, the
+// key being the object name. Signed URLs are presigned GET requests, which
+// the store itself checks.
+type S3Store struct {
+	client *minio.Client
+	signer *minio.Client
+	bucket string
+}
+
+// NewS3Store connects to the store and creates the bucket if it does not
+// exist yet.
+func NewS3Store(options S3Options) (*S3Store, error) {
+	newClient := func(endpoint string) (*minio.Client, error) {
+		return minio.New(endpoint, &minio.Options{
+			Creds:  credentials.NewStaticV4(options.AccessKey, options.SecretKey, ""),
+			Secure: options.UseSSL,
+			Region: options.Region,
+		})
+	}
+
+	client, err := newClient(options.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+	s := &S3Store{client: client, signer: client, bucket: options.Bucket}
+	if options.PublicEndpoint != "" {
+		if s.signer, err = newClient(options.PublicEndpoint); err != nil {
+			return nil, fmt.Errorf("failed to create S3 client: %w", err)
+		}
+	}
+
+	ctx := context.Background()
+	exists, err := client.BucketExists(ctx, options.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check bucket %s: %w", options.Bucket, err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, options.Bucket, minio.MakeBucketOptions{Region: options.Region}); err != nil {
+			return nil, fmt.Errorf("failed to create bucket %s: %w", options.Bucket, err)
+		}
+		fmt.Printf("Created bucket %s\n", options.Bucket)
+	}
+	return s, nil
+}
+
+// Delete checks that the object exists first, as S3 reports success for
+// deleting missing objects.
+func (s *S3Store) Delete(key string) error {
+	if _, err := s.Stat(key); err != nil {
+		return err
+	}
+	if err := s.client.RemoveObject(context.Background(), s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete blob %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *S3Store) Get(key string) (io.ReadCloser, BlobInfo, error) {
+	info, err := s.Stat(key)
+	if err != nil {
+		return nil, info, err
+	}
+	obj, err := s.client.GetObject(context.Background(), s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to get blob %s: %w", key, err)
+	}
+	return obj, info, nil
+}
+
+func (s *S3Store) List(prefix string) ([]BlobInfo, error) {
+	blobs := []BlobInfo{}
+	for obj := range s.client.ListObjects(context.Background(), s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("failed to list blobs %s: %w", prefix, obj.Err)
+		}
+		blobs = append(blobs, BlobInfo{Key: obj.Key, Size: obj.Size, ContentType: obj.ContentType, ModTime: obj.LastModified})
+	}
+	return blobs, nil
+}
+
+func (s *S3Store) Put(key string, r io.Reader, size int64, contentType string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	if _, err := s.client.PutObject(context.Background(), s.bucket, key, r, size,
+		minio.PutObjectOptions{ContentType: contentType}); err != nil {
+		return fmt.Errorf("failed to store blob %s: %w", key, err)
+	}
+	return nil
+}
+
+// SignedURL ignores the user, anyone holding the URL can download the
+// object until it expires.
+func (s *S3Store) SignedURL(key string, userID int, lifetime time.Duration) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	u, err := s.signer.PresignedGetObject(context.Background(), s.bucket, key, lifetime, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign URL of blob %s: %w", key, err)
+	}
+	return u.String(), nil
+}
+
+func (s *S3Store) Stat(key string) (BlobInfo, error) {
+	if err := checkKey(key); err != nil {
+		return BlobInfo{}, err
+	}
+	info, err := s.client.StatObject(context.Background(), s.bucket, key, minio.StatObjectOptions{})
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return BlobInfo{}, fmt.Errorf("blob %s: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return BlobInfo{}, fmt.Errorf("failed to stat blob %s: %w", key, err)
+	}
+	return BlobInfo{Key: key, Size: info.Size, ContentType: info.ContentType, ModTime: info.LastModified}, nil
+}
@@ -0,0 +1,49 @@
+// Package storage keeps uploaded files, such as tree images, as blobs in a
+// BlobStore: on the local disk or in an S3-compatible object store like
+// MinIO. Blobs are addressed by slash-separated keys like
+// "uploads/1700000000000000000.jpg", which is what the images table stores
+// as their path.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned for keys that have no blob.
+var ErrNotFound = errors.New("blob not found")
+
+// BlobInfo describes a stored blob.
+type BlobInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// BlobStore stores blobs by key. Put replaces an existing blob; size is -1
+// if it is not known in advance. List returns the blobs whose keys start
+// with the prefix, sorted by key. SignedURL returns a URL that lets the user
+// download the blob for the lifetime without further authentication.
+type BlobStore interface {
+	Delete(key string) error
+	Get(key string) (io.ReadCloser, BlobInfo, error)
+	List(prefix string) ([]BlobInfo, error)
+	Put(key string, r io.Reader, size int64, contentType string) error
+	SignedURL(key string, userID int, lifetime time.Duration) (string, error)
+	Stat(key string) (BlobInfo, error)
+}
+
+// checkKey rejects keys that could escape the store, such as absolute
+// paths or paths with "..".
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") || path.Clean(key) != key ||
+		key == ".." || strings.HasPrefix(key, "../") {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	return nil
+}
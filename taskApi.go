@@ -0,0 +1,220 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/handlers"
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultDueWindow is how far ahead GET /tasks/due looks without a before
+// parameter.
+const defaultDueWindow = 7 * 24 * time.Hour
+
+func (s *server) getTasksOfMeadow(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intMeadowID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	tasks, err := s.tasks.FindTasksForMeadow(intMeadowID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, tasks)
+}
+
+func (s *server) getTasksOfTree(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intTreeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	tasks, err := s.tasks.FindTasksForTree(intTreeID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, tasks)
+}
+
+// getDueTasks returns the open tasks due before the before parameter, given
+// as a date or RFC 3339 time, split into overdue and upcoming ones.
+func (s *server) getDueTasks(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	now := time.Now().UTC()
+	before := now.Add(defaultDueWindow)
+	if value := c.Query("before"); value != "" {
+		parsed, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			parsed, err = time.Parse(time.RFC3339, value)
+		}
+		if err != nil {
+			handlers.RespondInvalidInput(c, "Invalid before, expected a date like 2006-01-02 or an RFC 3339 time")
+			return
+		}
+		before = parsed
+	}
+
+	tasks, err := s.tasks.FindDueTasksForUser(userID, before)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	// Tasks due today are not overdue yet
+	today := now.Truncate(24 * time.Hour)
+	due := models.DueTasks{Overdue: []models.Task{}, Upcoming: []models.Task{}}
+	for _, task := range tasks {
+		if task.DueDate.Before(today) {
+			due.Overdue = append(due.Overdue, task)
+		} else {
+			due.Upcoming = append(due.Upcoming, task)
+		}
+	}
+	c.IndentedJSON(http.StatusOK, due)
+}
+
+func (s *server) findTaskByID(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intTaskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	task, err := s.tasks.FindOneTaskForUser(intTaskID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, task)
+}
+
+func (s *server) insertTask(c *gin.Context) {
+	var task models.Task
+
+	userID := c.GetInt("user_id")
+
+	if err := c.ShouldBindJSON(&task); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	if err := task.Validate(); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	insertedID, err := s.tasks.InsertTaskForUser(task, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Task inserted successfully",
+		"id":      insertedID,
+	})
+}
+
+func (s *server) updateTask(c *gin.Context) {
+	var task models.Task
+
+	userID := c.GetInt("user_id")
+
+	intTaskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	if err := c.ShouldBindJSON(&task); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	// A task stays with its tree or meadow, so the body does not need to
+	// repeat it
+	stored, err := s.tasks.FindOneTaskForUser(intTaskID, userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+	task.ID = intTaskID
+	task.TreeId = stored.TreeId
+	task.MeadowId = stored.MeadowId
+
+	if err := task.Validate(); err != nil {
+		handlers.RespondInvalidInput(c, err.Error())
+		return
+	}
+
+	if err := s.tasks.UpdateTaskForUser(task, userID); err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Task updated successfully",
+	})
+}
+
+// completeTask marks the task as done. For a recurring task the response
+// carries the ID of the next occurrence as nextId.
+func (s *server) completeTask(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intTaskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	nextID, err := s.tasks.CompleteTaskForUser(intTaskID, userID, time.Now())
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	response := gin.H{
+		"message": "Task completed successfully",
+		"id":      intTaskID,
+	}
+	if nextID != 0 {
+		response["nextId"] = nextID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (s *server) removeTask(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	intTaskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	if err := s.tasks.DeleteTaskForUser(intTaskID, userID); err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Task deleted successfully",
+		"id":      intTaskID,
+	})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/db"
+	"github.com/Johnhi19/TreeSpotter_backend/handlers"
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+	"github.com/Johnhi19/TreeSpotter_backend/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// trashPurgeInterval is how often expired items are purged from the trash.
+const trashPurgeInterval = time.Hour
+
+func (s *server) getTrash(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	trash, err := s.trash.FindTrashForUser(userID)
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, trash)
+}
+
+func (s *server) restoreFromTrash(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	kind := c.Param("kind")
+	intID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	switch kind {
+	case "meadows":
+		err = s.trash.RestoreMeadowForUser(intID, userID)
+	case "trees":
+		err = s.trash.RestoreTreeForUser(intID, userID)
+	case "images":
+		err = s.trash.RestoreImageForUser(intID, userID)
+	default:
+		handlers.RespondInvalidInput(c, "Kind must be one of meadows, trees or images")
+		return
+	}
+	if err != nil {
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Restored successfully",
+		"kind":    kind,
+		"id":      intID,
+	})
+}
+
+// purgeTrashPeriodically permanently deletes everything that has been in
+// the trash for longer than the retention period, together with the image
+// files. It never returns.
+func purgeTrashPeriodically(trash db.TrashStore, blobs storage.BlobStore, retention time.Duration) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		report, err := trash.PurgeTrash(time.Now().Add(-retention))
+		if err != nil {
+			fmt.Printf("ERROR purging trash: %v\n", err)
+		} else if len(report.MeadowIds)+len(report.TreeIds)+len(report.ImageIds) > 0 {
+			removeBlobs(blobs, &report)
+			fmt.Printf("Purged trash: %d meadows, %d trees, %d images, %d files\n",
+				len(report.MeadowIds), len(report.TreeIds), len(report.ImageIds), len(report.Files))
+		}
+
+		<-ticker.C
+	}
+}
+
+// removeBlobs deletes the files listed in the report and keeps in Files
+// those that were removed, in FailedFiles those that could not be. Files
+// that are already gone are skipped.
+func removeBlobs(blobs storage.BlobStore, report *models.DeletionReport) {
+	removed := []string{}
+	var failed []string
+
+	for _, key := range report.Files {
+		if err := blobs.Delete(key); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				fmt.Printf("Warning: file already gone: %s\n", key)
+				continue
+			}
+			fmt.Printf("Warning: failed to delete file %s: %v\n", key, err)
+			failed = append(failed, key)
+			continue
+		}
+		removed = append(removed, key)
+	}
+	report.Files, report.FailedFiles = removed, failed
+}
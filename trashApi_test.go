@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestTrash(t *testing.T) {
+	ts := newTestServer(t)
+	owner, _ := ts.signUp("owner")
+	outsider, _ := ts.signUp("outsider")
+	meadow := ts.newMeadow(owner)
+	apple, pear := ts.newTree(owner, meadow), ts.newTree(owner, meadow)
+	appleImage, pearImage := ts.newImage(owner, apple, 10), ts.newImage(owner, pear, 20)
+
+	trash := func() map[string]any {
+		t.Helper()
+		return ts.mustRequest("GET", "/trash", "", owner, http.StatusOK)
+	}
+	restore := func(kind string, id int, token string, want int) {
+		t.Helper()
+		ts.mustRequest("POST", fmt.Sprintf("/trash/%s/%d/restore", kind, id), "", token, want)
+	}
+	imagesOf := func(tree int) int {
+		t.Helper()
+		return len(ts.list(fmt.Sprintf("/trees/%d/images", tree), owner))
+	}
+
+	// An image comes back on its own
+	ts.mustRequest("DELETE", fmt.Sprintf("/trees/images/%d", pearImage), "", owner, http.StatusOK)
+	if images := trash()["images"].([]any); len(images) != 1 || id(images[0].(map[string]any)) != pearImage {
+		t.Errorf("trashed images = %v, want image %d", images, pearImage)
+	}
+	restore("images", pearImage, outsider, http.StatusNotFound)
+	restore("images", pearImage, owner, http.StatusOK)
+	restore("images", pearImage, owner, http.StatusNotFound)
+	if imagesOf(pear) != 1 {
+		t.Error("the restored image is missing")
+	}
+
+	// A tree comes back with the images trashed with it, which are not
+	// listed on their own
+	ts.mustRequest("DELETE", fmt.Sprintf("/trees/%d", apple), "", owner, http.StatusOK)
+	current := trash()
+	if trees := current["trees"].([]any); len(trees) != 1 || id(trees[0].(map[string]any)) != apple {
+		t.Errorf("trashed trees = %v, want tree %d", trees, apple)
+	}
+	if images := current["images"].([]any); len(images) != 0 {
+		t.Errorf("trashed images = %v, want none besides the tree", images)
+	}
+	restore("images", appleImage, owner, http.StatusConflict)
+	restore("trees", apple, owner, http.StatusOK)
+	if imagesOf(apple) != 1 {
+		t.Error("the image of the restored tree is missing")
+	}
+
+	// A meadow comes back with its trees, which cannot come back alone
+	ts.mustRequest("DELETE", fmt.Sprintf("/meadows/%d", meadow), "", owner, http.StatusOK)
+	meadows := trash()["meadows"].([]any)
+	if len(meadows) != 1 || !slices.Equal(ints(meadows[0].(map[string]any)["treeIds"]), []int{apple, pear}) {
+		t.Fatalf("trashed meadows = %v, want meadow %d with both trees", meadows, meadow)
+	}
+	restore("trees", pear, owner, http.StatusConflict)
+	restore("meadows", meadow, outsider, http.StatusNotFound)
+	restore("meadows", meadow, owner, http.StatusOK)
+	if trees := ts.list(fmt.Sprintf("/meadows/%d/trees", meadow), owner); len(trees) != 2 || imagesOf(apple) != 1 || imagesOf(pear) != 1 {
+		t.Errorf("the restored meadow has %d trees, want both with their images", len(trees))
+	}
+
+	restore("forests", meadow, owner, http.StatusBadRequest)
+	restore("meadows", meadow, owner, http.StatusNotFound)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/db"
+	"github.com/Johnhi19/TreeSpotter_backend/handlers"
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+	"github.com/Johnhi19/TreeSpotter_backend/storage"
+)
+
+const uploadsUsage = `usage: treespotter-backend uploads <command>
+
+commands:
+  reconcile [-repair delete|quarantine] [-min-age <duration>]
+           list files without an image, images without their file or
+           resized copies and images whose tree no longer exists; only
+           reports unless -repair is given
+  verify   check that image files still have their recorded SHA-256`
+
+// quarantinePrefix is put in front of the keys of quarantined files, which
+// keeps them out of the served uploads.
+const quarantinePrefix = "quarantine/"
+
+// runUploads implements the `uploads` subcommand and returns the exit code.
+// It uses the blob store the server is configured with.
+func runUploads(args []string) int {
+	if len(args) == 0 || (args[0] != "reconcile" && args[0] != "verify") {
+		fmt.Fprintln(os.Stderr, uploadsUsage)
+		return 2
+	}
+
+	flags := flag.NewFlagSet("uploads "+args[0], flag.ContinueOnError)
+	var repair *string
+	var minAge *time.Duration
+	if args[0] == "reconcile" {
+		repair = flags.String("repair", "", "fix what was found: delete or quarantine the files, deleting rows either way")
+		minAge = flags.Duration("min-age", time.Hour, "ignore files younger than this, which may belong to uploads in progress")
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if repair != nil && *repair != "" && *repair != "delete" && *repair != "quarantine" {
+		fmt.Fprintln(os.Stderr, "-repair expects delete or quarantine")
+		return 2
+	}
+
+	signer, err := newImageSigner()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		return 1
+	}
+	blobs, err := newBlobStore(signer)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		return 1
+	}
+
+	conn := db.Connect(false)
+	defer db.Disconnect(conn)
+	store := db.NewMySQLStore(conn)
+
+	if args[0] == "verify" {
+		return runImageVerification(store, blobs)
+	}
+	return reconcileUploads(store, blobs, *repair, *minAge)
+}
+
+func runImageVerification(images db.ImageStore, blobs storage.BlobStore) int {
+	checked, problems, err := verifyImages(images, blobs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		return 1
+	}
+
+	for _, problem := range problems {
+		fmt.Printf("image %d: %s: %s\n", problem.ImageId, problem.File, problem.Problem)
+	}
+	fmt.Printf("\nVerified %d images, found %d problems\n", checked, len(problems))
+	if len(problems) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// reconcileUploads compares the uploaded files with the images table. With
+// repair set to "delete" or "quarantine" it removes or quarantines files
+// without an image, deletes the rows of images without their file or tree,
+// removing or quarantining their remaining files, and generates missing
+// resized copies anew from their original.
+func reconcileUploads(images db.ImageStore, blobs storage.BlobStore, repair string, minAge time.Duration) int {
+	all, err := images.FindAllImages()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		return 1
+	}
+	withoutTree, err := images.FindImagesWithoutTree()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		return 1
+	}
+	files, err := blobs.List(handlers.UploadPrefix)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		return 1
+	}
+
+	known := map[string]bool{}
+	for _, image := range all {
+		for _, path := range image.Variants() {
+			known[path] = true
+		}
+	}
+
+	var orphans []storage.BlobInfo
+	for _, file := range files {
+		if !known[file.Key] && time.Since(file.ModTime) >= minAge {
+			orphans = append(orphans, file)
+		}
+	}
+
+	var missing, missingVariants []models.Image
+	for _, image := range all {
+		variants := map[string]bool{}
+		for variant, path := range image.Variants() {
+			_, err := blobs.Stat(path)
+			if errors.Is(err, storage.ErrNotFound) {
+				variants[variant] = true
+			} else if err != nil {
+				fmt.Fprintln(os.Stderr, "ERROR:", err)
+				return 1
+			}
+		}
+		if variants[models.VariantOriginal] {
+			missing = append(missing, image)
+		} else if len(variants) > 0 {
+			missingVariants = append(missingVariants, image)
+		}
+	}
+
+	fmt.Printf("Files without an image (%d):\n", len(orphans))
+	for _, file := range orphans {
+		fmt.Printf("  %s (%d bytes, %s)\n", file.Key, file.Size, file.ModTime.Format(time.RFC3339))
+	}
+	fmt.Printf("Images without their file (%d):\n", len(missing))
+	for _, image := range missing {
+		fmt.Printf("  image %d of tree %d: %s\n", image.ID, image.TreeId, image.Path)
+	}
+	fmt.Printf("Images without all of their resized copies (%d):\n", len(missingVariants))
+	for _, image := range missingVariants {
+		fmt.Printf("  image %d of tree %d: %s\n", image.ID, image.TreeId, image.Path)
+	}
+	fmt.Printf("Images whose tree no longer exists (%d):\n", len(withoutTree))
+	for _, image := range withoutTree {
+		fmt.Printf("  image %d of tree %d: %s\n", image.ID, image.TreeId, image.Path)
+	}
+
+	if repair == "" {
+		fmt.Println("\nDry run, nothing was changed. Use -repair delete or -repair quarantine to fix the above.")
+		return 0
+	}
+
+	repaired, failed := 0, 0
+	for _, file := range orphans {
+		if err := repairBlob(blobs, file.Key, repair); err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR:", err)
+			failed++
+			continue
+		}
+		repaired++
+	}
+
+	purged := map[int]bool{}
+	for _, image := range append(missing, withoutTree...) {
+		if purged[image.ID] {
+			continue
+		}
+		if err := images.PurgeImage(image.ID); err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR:", err)
+			failed++
+			continue
+		}
+		purged[image.ID] = true
+
+		for _, path := range image.Variants() {
+			err := repairBlob(blobs, path, repair)
+			if err != nil && !errors.Is(err, storage.ErrNotFound) {
+				fmt.Fprintln(os.Stderr, "ERROR:", err)
+				failed++
+			}
+		}
+	}
+
+	rebuilt := 0
+	for _, image := range missingVariants {
+		if purged[image.ID] {
+			continue
+		}
+		if err := handlers.RebuildImageVariants(blobs, image); err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR:", err)
+			failed++
+			continue
+		}
+		rebuilt++
+	}
+
+	verb := "Deleted"
+	if repair == "quarantine" {
+		verb = "Quarantined"
+	}
+	fmt.Printf("\n%s %d files without an image, deleted %d images and rebuilt the resized copies of %d, %d failures\n",
+		verb, repaired, len(purged), rebuilt, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// repairBlob deletes the blob, or moves it below quarantinePrefix
+func repairBlob(blobs storage.BlobStore, key string, repair string) error {
+	if repair == "delete" {
+		return blobs.Delete(key)
+	}
+
+	blob, info, err := blobs.Get(key)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	if err := blobs.Put(quarantinePrefix+key, blob, info.Size, info.ContentType); err != nil {
+		return err
+	}
+	return blobs.Delete(key)
+}
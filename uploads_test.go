@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Johnhi19/TreeSpotter_backend/models"
+	"github.com/Johnhi19/TreeSpotter_backend/storage"
+)
+
+func TestReconcileUploads(t *testing.T) {
+	ts := newTestServer(t)
+	owner, _ := ts.signUp("owner")
+	tree := ts.newTree(owner, ts.newMeadow(owner))
+	for _, shade := range []uint8{10, 100, 200} {
+		ts.newImage(owner, tree, shade)
+	}
+	images, blobs := ts.server.images, ts.server.blobs
+
+	all, err := images.FindAllImages()
+	if err != nil || len(all) != 3 {
+		t.Fatalf("FindAllImages() = %d images, %v", len(all), err)
+	}
+	withoutFile, withoutThumbnail, intact := all[0], all[1], all[2]
+	for _, key := range []string{withoutFile.Variants()[models.VariantOriginal], withoutThumbnail.Variants()[models.VariantThumbnail]} {
+		if err := blobs.Delete(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only files older than the minimum age count as without an image
+	orphan := func(key string, age time.Duration) {
+		t.Helper()
+		if err := blobs.Put(key, strings.NewReader("x"), 1, "image/jpeg"); err != nil {
+			t.Fatal(err)
+		}
+		modified := time.Now().Add(-age)
+		if err := os.Chtimes(filepath.Join(ts.blobs, filepath.FromSlash(key)), modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+	orphan("uploads/orphan.jpg", 2*time.Hour)
+	orphan("uploads/fresh.jpg", time.Minute)
+
+	exists := func(key string) bool {
+		t.Helper()
+		_, err := blobs.Stat(key)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+	imageIds := func() []int {
+		t.Helper()
+		all, err := images.FindAllImages()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []int{}
+		for _, image := range all {
+			ids = append(ids, image.ID)
+		}
+		return ids
+	}
+
+	// A dry run changes nothing
+	if code := reconcileUploads(images, blobs, "", time.Hour); code != 0 {
+		t.Fatalf("reconcileUploads() dry run = %d, want 0", code)
+	}
+	if !exists("uploads/orphan.jpg") || len(imageIds()) != 3 || exists(withoutThumbnail.Variants()[models.VariantThumbnail]) {
+		t.Fatal("the dry run changed the uploads")
+	}
+
+	if code := reconcileUploads(images, blobs, "quarantine", time.Hour); code != 0 {
+		t.Fatalf("reconcileUploads() quarantine = %d, want 0", code)
+	}
+	if exists("uploads/orphan.jpg") || !exists(quarantinePrefix+"uploads/orphan.jpg") {
+		t.Error("the file without an image was not quarantined")
+	}
+	if !exists("uploads/fresh.jpg") {
+		t.Error("a file younger than the minimum age was repaired")
+	}
+	if got := imageIds(); !slices.Equal(got, []int{withoutThumbnail.ID, intact.ID}) {
+		t.Errorf("images after the repair = %v, want %d and %d", got, withoutThumbnail.ID, intact.ID)
+	}
+	for variant, key := range withoutFile.Variants() {
+		if variant != models.VariantOriginal && (exists(key) || !exists(quarantinePrefix+key)) {
+			t.Errorf("the %s copy of the image without its file was not quarantined", variant)
+		}
+	}
+	for _, image := range []models.Image{withoutThumbnail, intact} {
+		for variant, key := range image.Variants() {
+			if !exists(key) {
+				t.Errorf("image %d has no %s copy after the repair", image.ID, variant)
+			}
+		}
+	}
+
+	// Deleting removes files without quarantining them
+	orphan("uploads/orphan2.jpg", 2*time.Hour)
+	if code := reconcileUploads(images, blobs, "delete", time.Hour); code != 0 {
+		t.Fatalf("reconcileUploads() delete = %d, want 0", code)
+	}
+	if exists("uploads/orphan2.jpg") || exists(quarantinePrefix+"uploads/orphan2.jpg") {
+		t.Error("the file without an image was not deleted")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Johnhi19/TreeSpotter_backend/db"
+	"github.com/Johnhi19/TreeSpotter_backend/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+// Default and maximum number of variety search results.
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+)
+
+func (s *server) getSpecies(c *gin.Context) {
+	c.IndentedJSON(http.StatusOK, s.varieties.Species())
+}
+
+func (s *server) findVarietyByID(c *gin.Context) {
+	intVarietyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handlers.RespondInvalidInput(c, "Invalid ID format")
+		return
+	}
+
+	variety, species, ok := s.varieties.Variety(intVarietyID)
+	if !ok {
+		handlers.RespondError(c, fmt.Errorf("variety %d: %w", intVarietyID, db.ErrNotFound))
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"variety": variety,
+		"species": species,
+	})
+}
+
+// searchVarieties finds varieties by name, synonym or species name given
+// in the q query parameter, tolerating misspellings. The best limit matches
+// are returned, best first.
+func (s *server) searchVarieties(c *gin.Context) {
+	query := c.Query("q")
+	if query == "" {
+		handlers.RespondInvalidInput(c, "Missing search query q")
+		return
+	}
+
+	limit := defaultSearchLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxSearchLimit {
+			handlers.RespondInvalidInput(c, fmt.Sprintf("limit must be a whole number from 1 to %d", maxSearchLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	c.IndentedJSON(http.StatusOK, s.varieties.Search(query, limit))
+}